@@ -1,10 +1,78 @@
 package cmd
 
 import (
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 var K8Cmd = &cobra.Command{
 	Use:   "k8",
 	Short: "k8 utilities",
+	Long: `k8 utilities
+
+Kubernetes Secrets are also available to the secret commands as the k8
+provider. Stores are written as [<context>:]<namespace>, every secret is an
+Opaque Secret of its own whose name must be a valid Secret name. Only Secrets
+labelled app.kubernetes.io/managed-by=hazyctl, which hazyctl sets on the
+Secrets it creates, are listed, so other Secrets of the namespace are never
+pruned. [<context>:]<namespace>/<secret> keeps every secret as a key of that
+one Secret instead.
+
+	example:
+	1. list the contexts of the kubeconfig
+		hazyctl k8 contexts
+	2. copy Key Vault secrets into a namespace of the prod context
+		hazyctl secret migrate --from azure://vault1 --to k8://prod:apps
+	3. collect Key Vault secrets as the keys of a single Secret
+		hazyctl secret migrate --from azure://vault1 --to k8://prod:apps/app-secrets
+	`,
+}
+
+func init() {
+	K8Cmd.PersistentFlags().String("kubeconfig", "", "path of the kubeconfig, defaults to KUBECONFIG or ~/.kube/config")
+	viper.BindPFlag("k8.kubeconfig", K8Cmd.PersistentFlags().Lookup("kubeconfig"))
+	K8Cmd.AddCommand(newContextsCmd())
+}
+
+func newContextsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "contexts",
+		Short: "List the contexts of the kubeconfig",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rules := clientcmd.NewDefaultClientConfigLoadingRules()
+			rules.ExplicitPath = viper.GetString("k8.kubeconfig")
+			config, err := rules.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load kubeconfig: %w", err)
+			}
+			names := make([]string, 0, len(config.Contexts))
+			for name := range config.Contexts {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			w := tabwriter.NewWriter(redact.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "CURRENT\tNAME\tCLUSTER\tNAMESPACE")
+			for _, name := range names {
+				current := ""
+				if name == config.CurrentContext {
+					current = "*"
+				}
+				kctx := config.Contexts[name]
+				namespace := kctx.Namespace
+				if namespace == "" {
+					namespace = "default"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", current, name, kctx.Cluster, namespace)
+			}
+			return w.Flush()
+		},
+	}
 }
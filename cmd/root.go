@@ -4,14 +4,16 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/hazyforge/hazyctl/cmd/secret"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+	"github.com/hazyforge/hazyctl/pkg/utils"
 	"gopkg.in/yaml.v3"
 
-	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -24,8 +26,18 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() {
+	// errors are printed here so secret values can be scrubbed from them
+	rootCmd.SilenceErrors = true
 	err := rootCmd.Execute()
 	if err != nil {
+		var exitErr *utils.ExitError
+		if errors.As(err, &exitErr) {
+			if exitErr.Err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", redact.Error(exitErr.Err))
+			}
+			os.Exit(exitErr.Code)
+		}
+		fmt.Fprintln(os.Stderr, "Error:", redact.Error(err))
 		os.Exit(1)
 	}
 }
@@ -33,14 +45,15 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.AddCommand(secret.SecretCmd)
+	rootCmd.AddCommand(K8Cmd)
 }
 func getConfigDir() string {
-	home, err := homedir.Dir()
+	dir, err := utils.ConfigDir()
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-	return filepath.Join(home, ".local", "share", "hazyctl")
+	return dir
 }
 
 var cfgFile = ""
@@ -1,50 +1,122 @@
 package azure
 
 import (
+	"errors"
 	"fmt"
-	azureUtils "github.com/hazyforge/hazyctl/pkg/utils"
+	"os"
+	"path/filepath"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/crypt"
+	"github.com/hazyforge/hazyctl/internal/secret/export"
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	azureProvider "github.com/hazyforge/hazyctl/internal/secret/providers/azure"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+const providerName = "azure"
+
 var AzureCmd = &cobra.Command{
 	Use:   "azure",
 	Short: "Azure Key Vault and secrets management utilities",
 }
 
-
 func init() {
 	AzureCmd.AddCommand(newMigrateCmd())
 	AzureCmd.AddCommand(newExportCmd())
+	AzureCmd.AddCommand(newBackupCmd())
+	AzureCmd.AddCommand(newRestoreCmd())
+	AzureCmd.AddCommand(newImportCmd())
 	AzureCmd.PersistentFlags().StringP("subscription", "s", "", "Azure subscription ID")
 	AzureCmd.MarkPersistentFlagRequired("subscription")
 	viper.BindPFlag("azure.subscription", AzureCmd.PersistentFlags().Lookup("subscription"))
 }
 
+func newProvider() (*azureProvider.AzureSecretProvider, error) {
+	fmt.Fprintln(os.Stderr, "Using subscription ", viper.GetString("azure.subscription"))
+	provider, err := providers.GetProvider(providerName, providers.LoadConfig(providerName))
+	if err != nil {
+		return nil, err
+	}
+	return provider.(*azureProvider.AzureSecretProvider), nil
+}
+
+// addKindsFlag registers the --kinds flag selecting which Key Vault objects a command handles
+func addKindsFlag(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("kinds", []string{string(azureProvider.KindSecrets)}, "Key Vault object kinds to handle: secrets, certificates and keys")
+}
+
+func kindsFromFlags(cmd *cobra.Command) (map[azureProvider.Kind]bool, error) {
+	values, err := cmd.Flags().GetStringSlice("kinds")
+	if err != nil {
+		return nil, err
+	}
+	return azureProvider.ParseKinds(values)
+}
+
 func newMigrateCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "migrate",
-		Short: "Migrate secrets between Key Vaults",
+		Short: "Migrate secrets, certificates and keys between Key Vaults",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
- 			sourceVaultName := viper.GetString("azure.migrate.source")
+			sourceVaultName := viper.GetString("azure.migrate.source")
 			destVaultName := viper.GetString("azure.migrate.destination")
-			subscriptionID := viper.GetString("azure.subscription")
-			fmt.Println("Using subscription ", subscriptionID)
-			client, err := azureUtils.NewAzureClient(subscriptionID)
+			provider, err := newProvider()
+			if err != nil {
+				return err
+			}
+			opts, err := migrate.OptionsFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			kinds, err := kindsFromFlags(cmd)
 			if err != nil {
-				return fmt.Errorf("failed to create Azure client: %w", err)
+				return err
+			}
+			if opts.Format == "json" && (kinds[azureProvider.KindCertificates] || kinds[azureProvider.KindKeys]) {
+				return errors.New("--format json only supports --kinds secrets")
+			}
+
+			var errs []error
+			if kinds[azureProvider.KindSecrets] {
+				errs = append(errs, migrate.Execute(ctx,
+					migrate.Endpoint{Provider: provider, Store: sourceVaultName},
+					migrate.Endpoint{Provider: provider, Store: destVaultName},
+					opts, redact.Stdout,
+				))
+			}
+			if kinds[azureProvider.KindCertificates] {
+				redact.Println("Importing certificates from", sourceVaultName, "to", destVaultName)
+				results, err := migrateCertificates(ctx, provider, sourceVaultName, destVaultName, opts)
+				if failed := writeResults(redact.Stdout, results, !opts.DryRun); failed > 0 {
+					err = errors.Join(err, fmt.Errorf("%d of %d certificates failed to migrate", failed, len(results)))
+				}
+				errs = append(errs, err)
+			}
+			if kinds[azureProvider.KindKeys] {
+				redact.Println("Restoring keys from", sourceVaultName, "to", destVaultName)
+				results, err := migrateKeys(ctx, provider, sourceVaultName, destVaultName, opts)
+				if failed := writeResults(redact.Stdout, results, !opts.DryRun); failed > 0 {
+					err = errors.Join(err, fmt.Errorf("%d of %d keys failed to migrate", failed, len(results)))
+				}
+				errs = append(errs, err)
 			}
-			fmt.Println("Copying secrets from ", sourceVaultName, " to ", destVaultName)
-			return client.MigrateSecrets(ctx, sourceVaultName, destVaultName)
+			return errors.Join(errs...)
 		},
 	}
 
-	cmd.Flags().String("source", "", "Source Key Vault URL (e.g. https://src-vault.vault.azure.net)")
-	cmd.Flags().String("destination", "", "Destination Key Vault URL (e.g. https://dst-vault.vault.azure.net)")
+	cmd.Flags().String("source", "", "Source Key Vault name or URL (e.g. https://src-vault.vault.azure.net)")
+	cmd.Flags().String("destination", "", "Destination Key Vault name or URL (e.g. https://dst-vault.vault.azure.net)")
 	cmd.MarkFlagRequired("source")
 	cmd.MarkFlagRequired("destination")
+	migrate.AddFlags(cmd.Flags())
+	addKindsFlag(cmd)
 
 	viper.BindPFlag("azure.migrate.source", cmd.Flags().Lookup("source"))
 	viper.BindPFlag("azure.migrate.destination", cmd.Flags().Lookup("destination"))
@@ -52,42 +124,105 @@ func newMigrateCmd() *cobra.Command {
 	return cmd
 }
 
-
 func newExportCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "export",
-		Short: "Export Key Vault secrets and certificates",
+		Short: "Export Key Vault secrets, certificates and keys",
+		Long: `Export Key Vault secrets, certificates and keys.
+
+Secrets are written to the output file. Certificates are written as .pfx or .pem
+files and keys as backup blobs into the <output>.objects directory, described by
+a manifest.json. These files are not encrypted, so exporting certificates or keys
+requires --plaintext.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
-			outputPath := viper.GetString("output")
-			fmt.Println(outputPath)
-			subscriptionID, _ := cmd.Flags().GetString("subscription")
-			vaultName, _ := cmd.Flags().GetString("name")
-			client, err := azureUtils.NewAzureClient(subscriptionID)
+			outputPath := viper.GetString("azure.export.output")
+			vaultName := viper.GetString("azure.export.name")
+			provider, err := newProvider()
 			if err != nil {
-				return fmt.Errorf("failed to create Azure client: %w", err)
+				return err
 			}
-			secrets, err := client.ExportSecrets(ctx, vaultName)
-
+			concurrency, retry, err := batch.FromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			states, err := filter.StateRulesFromFlags(cmd.Flags())
 			if err != nil {
-				return fmt.Errorf("failed to export secrets: %w", err)
+				return err
 			}
+			format, formatOpts, err := export.FormatFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			encryption, err := crypt.EncryptFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			kinds, err := kindsFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			objects := kinds[azureProvider.KindCertificates] || kinds[azureProvider.KindKeys]
+			if objects && encryption != nil {
+				// the object files hold private keys and are never encrypted, so do not let --encrypt suggest otherwise
+				return errors.New("certificates and keys are written unencrypted, export them with --plaintext and without --encrypt")
+			}
+			var errs []error
+			if objects {
+				if outputPath == "" {
+					return errors.New("--output is required to export certificates and keys")
+				}
+				dir := outputPath + ".objects"
+				manifest, err := exportObjects(ctx, provider, vaultName, dir, kinds, migrate.Options{Concurrency: concurrency, Retry: retry})
+				if manifest != nil {
+					redact.Printf("%d certificates and %d keys written to %s\n", len(manifest.Certificates), len(manifest.Keys), filepath.Join(dir, "manifest.json"))
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("failed to export certificates and keys: %w", err))
+				}
+			}
+			if !kinds[azureProvider.KindSecrets] {
+				return errors.Join(errs...)
+			}
+
+			result, exportErr := export.Export(ctx, provider, vaultName, export.Options{
+				Concurrency: concurrency,
+				Retry:       retry,
+				States:      states,
+				SkipManaged: kinds[azureProvider.KindCertificates],
+			})
+			if result == nil {
+				return errors.Join(append(errs, fmt.Errorf("failed to export secrets: %w", exportErr))...)
+			}
+			secrets := result.Secrets
 			for _, secret := range secrets {
-				fmt.Printf("Name: %s, Value: %s\n", secret.Name, secret.Value)
+				redact.Printf("Name: %s, Value: %s\n", secret.Name, redact.Value(secret.Value))
 			}
-			
+
 			if outputPath != "" {
-				azureUtils.WriteToJSONFile(secrets, outputPath)
-			}		
-			return nil
+				if err := export.WriteFile(outputPath, format, secrets, formatOpts, encryption); err != nil {
+					return err
+				}
+				redact.Println("Secrets written to", outputPath)
+			}
+			redact.Println(result.Summary())
+			if exportErr != nil {
+				errs = append(errs, fmt.Errorf("failed to export secrets: %w", exportErr))
+			}
+			return errors.Join(errs...)
 		},
 	}
 
 	cmd.Flags().StringP("name", "n", "", "Name of the vault")
 	cmd.Flags().StringP("output", "o", "secrets.json", "Output file path")
+	batch.AddFlags(cmd.Flags())
+	filter.AddStateFlags(cmd.Flags())
+	export.AddFormatFlags(cmd.Flags())
+	crypt.AddEncryptFlags(cmd.Flags())
+	addKindsFlag(cmd)
 	cmd.MarkFlagRequired("name")
-	viper.BindPFlag("azure.export.name", AzureCmd.PersistentFlags().Lookup("name"))
-	viper.BindPFlag("azure.export.output", AzureCmd.PersistentFlags().Lookup("output"))
+	viper.BindPFlag("azure.export.name", cmd.Flags().Lookup("name"))
+	viper.BindPFlag("azure.export.output", cmd.Flags().Lookup("output"))
 
 	return cmd
 }
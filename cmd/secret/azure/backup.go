@@ -0,0 +1,297 @@
+package azure
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	azureProvider "github.com/hazyforge/hazyctl/internal/secret/providers/azure"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func newBackupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Back up Key Vault objects as native backup blobs",
+		Long: `Back up Key Vault secrets, certificates and keys as native backup blobs.
+
+Blobs keep every version with its id, dates and attributes. They are written to
+the output directory together with a manifest.json and can only be restored into
+a vault of the same subscription and geography.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			vaultName := viper.GetString("azure.backup.name")
+			outputDir := viper.GetString("azure.backup.output")
+			provider, err := newProvider()
+			if err != nil {
+				return err
+			}
+			kinds, err := kindsFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			opts, err := objectOptionsFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			manifest, err := backup(ctx, provider, vaultName, outputDir, kinds, opts)
+			if manifest != nil {
+				redact.Printf("%d objects backed up to %s\n", len(manifest.Entries), outputDir)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringP("name", "n", "", "Name of the vault")
+	cmd.Flags().StringP("output", "o", "backup", "Output directory")
+	cmd.MarkFlagRequired("name")
+	addKindsFlag(cmd)
+	addObjectFlags(cmd)
+	viper.BindPFlag("azure.backup.name", cmd.Flags().Lookup("name"))
+	viper.BindPFlag("azure.backup.output", cmd.Flags().Lookup("output"))
+
+	return cmd
+}
+
+func newRestoreCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore Key Vault backup blobs into a vault",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			vaultName := viper.GetString("azure.restore.name")
+			inputDir := viper.GetString("azure.restore.input")
+			provider, err := newProvider()
+			if err != nil {
+				return err
+			}
+			opts, err := objectOptionsFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			if opts.DryRun, err = cmd.Flags().GetBool("dry-run"); err != nil {
+				return err
+			}
+			onConflict, err := cmd.Flags().GetString("on-conflict")
+			if err != nil {
+				return err
+			}
+			if opts.OnConflict, err = migrate.ParseConflictPolicy(onConflict); err != nil {
+				return err
+			}
+			if opts.OnConflict != migrate.ConflictSkip && opts.OnConflict != migrate.ConflictFail {
+				return fmt.Errorf("restore only supports --on-conflict %s or %s", migrate.ConflictSkip, migrate.ConflictFail)
+			}
+
+			redact.Println("Restoring", inputDir, "into", vaultName)
+			results, err := restore(ctx, provider, inputDir, vaultName, opts)
+			if failed := writeResults(redact.Stdout, results, !opts.DryRun); failed > 0 {
+				err = errors.Join(err, fmt.Errorf("%d of %d objects failed to restore", failed, len(results)))
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringP("name", "n", "", "Name of the vault to restore into")
+	cmd.Flags().StringP("input", "i", "backup", "Backup directory")
+	cmd.Flags().Bool("dry-run", false, "Print what would be restored without writing to the vault")
+	cmd.Flags().String("on-conflict", string(migrate.ConflictSkip), "What to do with objects that already exist in the vault: skip or fail")
+	cmd.MarkFlagRequired("name")
+	addObjectFlags(cmd)
+	viper.BindPFlag("azure.restore.name", cmd.Flags().Lookup("name"))
+	viper.BindPFlag("azure.restore.input", cmd.Flags().Lookup("input"))
+
+	return cmd
+}
+
+// addObjectFlags registers the batching and selection flags of the backup commands
+func addObjectFlags(cmd *cobra.Command) {
+	batch.AddFlags(cmd.Flags())
+	filter.AddSelectorFlags(cmd.Flags())
+}
+
+func objectOptionsFromFlags(cmd *cobra.Command) (migrate.Options, error) {
+	var opts migrate.Options
+	var err error
+	if opts.Concurrency, opts.Retry, err = batch.FromFlags(cmd.Flags()); err != nil {
+		return opts, err
+	}
+	if opts.Selector, err = filter.SelectorFromFlags(cmd.Flags()); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// backupManifestFile is the name of the manifest written into a backup directory
+const backupManifestFile = "manifest.json"
+
+// backupEntry is a single backup blob, File is relative to the backup directory
+type backupEntry struct {
+	Kind   azureProvider.Kind `json:"kind"`
+	Name   string             `json:"name"`
+	File   string             `json:"file"`
+	SHA256 string             `json:"sha256"`
+	// Tags are kept so restores can select blobs by tag
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// backupManifest describes the blobs of a backup directory
+type backupManifest struct {
+	Vault   string        `json:"vault"`
+	Created time.Time     `json:"created"`
+	Entries []backupEntry `json:"entries"`
+}
+
+// backup writes a Key Vault backup blob for every selected object of store into dir, together with
+// a manifest. Blobs keep every version with its id, dates and attributes but can only be restored into
+// a vault of the same subscription and geography.
+func backup(ctx context.Context, provider *azureProvider.AzureSecretProvider, store, dir string, kinds map[azureProvider.Kind]bool, opts migrate.Options) (*backupManifest, error) {
+	var entries []*backupEntry
+	for _, kind := range azureProvider.Kinds {
+		if !kinds[kind] {
+			continue
+		}
+		objects, err := batch.Retry(ctx, opts.Retry, func() ([]providers.SecretProperties, error) {
+			return provider.ListObjects(ctx, kind, store)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", kind, err)
+		}
+		sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+		for _, object := range objects {
+			if !opts.Selector.Match(object) {
+				continue
+			}
+			entries = append(entries, &backupEntry{
+				Kind: kind,
+				Name: object.Name,
+				File: filepath.Join(string(kind), object.Name+".blob"),
+				Tags: object.Tags,
+			})
+		}
+	}
+
+	errs := make([]error, len(entries))
+	batch.ForEach(ctx, len(entries), opts.Concurrency, func(ctx context.Context, i int) {
+		entry := entries[i]
+		blob, err := batch.Retry(ctx, opts.Retry, func() ([]byte, error) {
+			return provider.BackupObject(ctx, entry.Kind, store, entry.Name)
+		})
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to back up %s %s: %w", entry.Kind, entry.Name, err)
+			return
+		}
+		sum := sha256.Sum256(blob)
+		entry.SHA256 = hex.EncodeToString(sum[:])
+		errs[i] = writePrivateFile(filepath.Join(dir, entry.File), blob)
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	manifest := &backupManifest{Vault: store, Created: time.Now().UTC(), Entries: []backupEntry{}}
+	var failed []error
+	for i, entry := range entries {
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+			continue
+		}
+		manifest.Entries = append(manifest.Entries, *entry)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := writePrivateFile(filepath.Join(dir, backupManifestFile), data); err != nil {
+		return nil, err
+	}
+	if len(failed) > 0 {
+		return manifest, fmt.Errorf("%d of %d objects failed to back up:\n%w", len(failed), len(entries), errors.Join(failed...))
+	}
+	return manifest, nil
+}
+
+// readBackupManifest reads the manifest of a backup directory
+func readBackupManifest(dir string) (*backupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, backupManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+	var manifest backupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse backup manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// restore restores the selected blobs of a backup directory into store. Objects that already exist
+// are skipped, or fail under ConflictFail, since Key Vault cannot restore over an existing object.
+func restore(ctx context.Context, provider *azureProvider.AzureSecretProvider, dir, store string, opts migrate.Options) ([]*objectResult, error) {
+	manifest, err := readBackupManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []backupEntry
+	for _, entry := range manifest.Entries {
+		if opts.Selector.Match(providers.SecretProperties{Name: entry.Name, Tags: entry.Tags}) {
+			entries = append(entries, entry)
+		}
+	}
+
+	results := make([]*objectResult, len(entries))
+	batch.ForEach(ctx, len(entries), opts.Concurrency, func(ctx context.Context, i int) {
+		entry := entries[i]
+		result := &objectResult{Kind: entry.Kind, Name: entry.Name}
+		results[i] = result
+
+		blob, err := os.ReadFile(filepath.Join(dir, entry.File))
+		if err != nil {
+			result.Err = fmt.Errorf("failed to read backup blob: %w", err)
+			return
+		}
+		if sum := sha256.Sum256(blob); hex.EncodeToString(sum[:]) != entry.SHA256 {
+			result.Err = fmt.Errorf("backup blob %s does not match its checksum", entry.File)
+			return
+		}
+
+		found, err := batch.Retry(ctx, opts.Retry, func() (bool, error) {
+			return provider.ObjectExists(ctx, entry.Kind, store, entry.Name)
+		})
+		switch {
+		case err != nil:
+			result.Err = fmt.Errorf("failed to read destination: %w", err)
+			return
+		case found && opts.OnConflict == migrate.ConflictFail:
+			result.Err = fmt.Errorf("%w: %s %s", migrate.ErrConflict, entry.Kind, entry.Name)
+			return
+		case found:
+			result.Action, result.Reason = migrate.ActionSkip, "exists in destination, backups cannot overwrite"
+			return
+		}
+		result.Action = migrate.ActionCreate
+		if opts.DryRun {
+			return
+		}
+		_, err = batch.Retry(ctx, opts.Retry, func() (struct{}, error) {
+			return struct{}{}, provider.RestoreObject(ctx, entry.Kind, store, blob)
+		})
+		if err != nil {
+			result.Err = fmt.Errorf("failed to restore, the vaults must share a subscription and geography: %w", err)
+		}
+	})
+	return results, ctx.Err()
+}
@@ -0,0 +1,83 @@
+package azure
+
+import (
+	"github.com/hazyforge/hazyctl/internal/secret/crypt"
+	"github.com/hazyforge/hazyctl/internal/secret/export"
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func newImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import an export file into a Key Vault",
+		Long: `Import an export file into a Key Vault.
+
+The file may be in any export format and encrypted. Names are validated against
+the Key Vault naming rules, use the rename flags to map names of formats such as
+dotenv, e.g. --rename-match _ --rename-replace -. Tags, content types and
+attributes are restored, existing secrets are handled like in migrate. Secrets
+exported without their value, tagged ` + migrate.TagValueUnavailable + `, are skipped.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			vaultName := viper.GetString("azure.import.name")
+			inputPath := viper.GetString("azure.import.input")
+			inputFormat, _ := cmd.Flags().GetString("input-format")
+			identities, err := crypt.IdentitiesFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			opts, err := migrate.OptionsFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+
+			secrets, err := export.ReadFile(inputPath, inputFormat, identities)
+			if err != nil {
+				return err
+			}
+			secrets = skipUnavailable(secrets)
+			source, err := export.NewFileProvider(secrets)
+			if err != nil {
+				return err
+			}
+			provider, err := newProvider()
+			if err != nil {
+				return err
+			}
+			return migrate.Execute(ctx,
+				migrate.Endpoint{Provider: source, Store: inputPath},
+				migrate.Endpoint{Provider: provider, Store: vaultName},
+				opts, redact.Stdout,
+			)
+		},
+	}
+
+	cmd.Flags().StringP("input", "i", "secrets.json", "Export file to import")
+	cmd.Flags().StringP("name", "n", "", "Name of the vault to import into")
+	cmd.Flags().String("input-format", "", "Format of the input file, detected from the extension and content when empty")
+	cmd.MarkFlagRequired("name")
+	crypt.AddDecryptFlags(cmd.Flags())
+	migrate.AddFlags(cmd.Flags())
+	viper.BindPFlag("azure.import.name", cmd.Flags().Lookup("name"))
+	viper.BindPFlag("azure.import.input", cmd.Flags().Lookup("input"))
+
+	return cmd
+}
+
+// skipUnavailable leaves out the secrets exported without their value so their empty value
+// is never written over a real one
+func skipUnavailable(secrets []export.ExportSecret) []export.ExportSecret {
+	kept := secrets[:0]
+	for _, secret := range secrets {
+		if _, ok := secret.Tags[migrate.TagValueUnavailable]; ok {
+			redact.Printf("Skipping %s, it was exported without its value\n", secret.Name)
+			continue
+		}
+		kept = append(kept, secret)
+	}
+	return kept
+}
@@ -0,0 +1,326 @@
+package azure
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	azureProvider "github.com/hazyforge/hazyctl/internal/secret/providers/azure"
+	"github.com/hazyforge/hazyctl/pkg/utils"
+)
+
+// objectResult is the outcome of copying a single certificate or key
+type objectResult struct {
+	Kind   azureProvider.Kind
+	Name   string
+	Target string
+	Action migrate.Action
+	Reason string
+	Err    error
+}
+
+// migrateCertificates imports every selected certificate of src into dst with its policy.
+// Existing certificates are handled according to opts.OnConflict, where overwrite-if-different
+// compares thumbprints and rename-with-suffix is not supported.
+func migrateCertificates(ctx context.Context, provider *azureProvider.AzureSecretProvider, src, dst string, opts migrate.Options) ([]*objectResult, error) {
+	certificates, err := batch.Retry(ctx, opts.Retry, func() ([]azureProvider.CertificateProperties, error) {
+		return provider.ListCertificates(ctx, src)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list certificates: %w", err)
+	}
+	sort.Slice(certificates, func(i, j int) bool { return certificates[i].Name < certificates[j].Name })
+
+	var selected []azureProvider.CertificateProperties
+	for _, cert := range certificates {
+		if opts.Selector.Match(providers.SecretProperties{Name: cert.Name, Tags: cert.Tags}) {
+			selected = append(selected, cert)
+		}
+	}
+	results := make([]*objectResult, len(selected))
+	batch.ForEach(ctx, len(selected), opts.Concurrency, func(ctx context.Context, i int) {
+		result := &objectResult{Kind: azureProvider.KindCertificates, Name: selected[i].Name}
+		results[i] = result
+		target := opts.Renamer.Rename(result.Name)
+		if target != result.Name {
+			result.Target = target
+		}
+
+		current, err := batch.Retry(ctx, opts.Retry, func() (string, error) {
+			return provider.GetCertificateThumbprint(ctx, dst, target)
+		})
+		found := err == nil
+		if err != nil && !errors.Is(err, providers.ErrNotFound) {
+			result.Err = fmt.Errorf("failed to read destination certificate: %w", err)
+			return
+		}
+		result.Action = migrate.ActionCreate
+		if found {
+			switch opts.OnConflict {
+			case migrate.ConflictSkip:
+				result.Action, result.Reason = migrate.ActionSkip, "exists in destination"
+				return
+			case migrate.ConflictFail:
+				result.Err = fmt.Errorf("%w: certificate %s", migrate.ErrConflict, target)
+				return
+			case migrate.ConflictRename:
+				result.Err = fmt.Errorf("%s is not supported for certificates", migrate.ConflictRename)
+				return
+			case migrate.ConflictOverwriteIfDifferent:
+				if current == selected[i].Thumbprint {
+					result.Action, result.Reason = migrate.ActionSkip, "unchanged"
+					return
+				}
+			}
+			result.Action = migrate.ActionUpdate
+		}
+		if opts.DryRun {
+			return
+		}
+
+		cert, err := batch.Retry(ctx, opts.Retry, func() (*azureProvider.Certificate, error) {
+			return provider.GetCertificate(ctx, src, result.Name)
+		})
+		if err != nil {
+			result.Err = fmt.Errorf("failed to get certificate: %w", err)
+			return
+		}
+		cert.Name = target
+		_, err = batch.Retry(ctx, opts.Retry, func() (struct{}, error) {
+			return struct{}{}, provider.ImportCertificate(ctx, dst, *cert)
+		})
+		if err != nil {
+			result.Err = fmt.Errorf("failed to import certificate: %w", err)
+		}
+	})
+	return results, ctx.Err()
+}
+
+// migrateKeys copies every selected key of src into dst through a backup and restore,
+// which only works between vaults of the same subscription and geography.
+// Keys that already exist in dst are skipped since a restore cannot overwrite them.
+func migrateKeys(ctx context.Context, provider *azureProvider.AzureSecretProvider, src, dst string, opts migrate.Options) ([]*objectResult, error) {
+	keys, err := batch.Retry(ctx, opts.Retry, func() ([]azureProvider.KeyProperties, error) {
+		return provider.ListKeys(ctx, src)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+
+	var selected []azureProvider.KeyProperties
+	for _, key := range keys {
+		if opts.Selector.Match(providers.SecretProperties{Name: key.Name, Tags: key.Tags}) {
+			selected = append(selected, key)
+		}
+	}
+	results := make([]*objectResult, len(selected))
+	batch.ForEach(ctx, len(selected), opts.Concurrency, func(ctx context.Context, i int) {
+		result := &objectResult{Kind: azureProvider.KindKeys, Name: selected[i].Name}
+		results[i] = result
+		if opts.Renamer.Rename(result.Name) != result.Name {
+			result.Err = errors.New("keys cannot be renamed, a restored key keeps its name")
+			return
+		}
+
+		found, err := batch.Retry(ctx, opts.Retry, func() (bool, error) {
+			return provider.KeyExists(ctx, dst, result.Name)
+		})
+		switch {
+		case err != nil:
+			result.Err = fmt.Errorf("failed to read destination key: %w", err)
+			return
+		case found && opts.OnConflict == migrate.ConflictFail:
+			result.Err = fmt.Errorf("%w: key %s", migrate.ErrConflict, result.Name)
+			return
+		case found:
+			result.Action, result.Reason = migrate.ActionSkip, "exists in destination, keys are never overwritten"
+			return
+		}
+		result.Action = migrate.ActionCreate
+		if opts.DryRun {
+			return
+		}
+
+		backup, err := batch.Retry(ctx, opts.Retry, func() ([]byte, error) {
+			return provider.BackupKey(ctx, src, result.Name)
+		})
+		if err != nil {
+			result.Err = fmt.Errorf("failed to back up key: %w", err)
+			return
+		}
+		_, err = batch.Retry(ctx, opts.Retry, func() (struct{}, error) {
+			return struct{}{}, provider.RestoreKey(ctx, dst, backup)
+		})
+		if err != nil {
+			result.Err = fmt.Errorf("failed to restore key, the vaults must share a subscription and geography: %w", err)
+		}
+	})
+	return results, ctx.Err()
+}
+
+// writeResults prints the outcome of copying certificates or keys in the format of a migration plan.
+// Objects left unprocessed by a cancelled run have no result and are not printed.
+func writeResults(w io.Writer, results []*objectResult, applied bool) (failed int) {
+	verbs := map[migrate.Action]string{migrate.ActionCreate: "created", migrate.ActionUpdate: "updated", migrate.ActionSkip: "skipped"}
+	if !applied {
+		verbs = map[migrate.Action]string{migrate.ActionCreate: "would create", migrate.ActionUpdate: "would update", migrate.ActionSkip: "would skip"}
+	}
+	symbols := map[migrate.Action]string{migrate.ActionCreate: "+", migrate.ActionUpdate: "~", migrate.ActionSkip: "="}
+	for _, result := range results {
+		switch {
+		case result == nil:
+			continue
+		case result.Err != nil:
+			failed++
+			fmt.Fprintf(w, "! %s %s: %v\n", result.Kind, result.Name, result.Err)
+			continue
+		}
+		name := result.Name
+		if result.Target != "" {
+			name += " -> " + result.Target
+		}
+		line := fmt.Sprintf("%s %s %s: %s", symbols[result.Action], result.Kind, name, verbs[result.Action])
+		if result.Reason != "" {
+			line += " (" + result.Reason + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+	return failed
+}
+
+// objectManifest lists the certificate and key files written by exportObjects
+type objectManifest struct {
+	Store        string             `json:"store"`
+	Certificates []certificateEntry `json:"certificates,omitempty"`
+	Keys         []keyEntry         `json:"keys,omitempty"`
+}
+
+// certificateEntry is an exported certificate, File is relative to the manifest
+type certificateEntry struct {
+	azureProvider.Certificate
+	File string `json:"file"`
+}
+
+// keyEntry is an exported key backup blob, File is relative to the manifest
+type keyEntry struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+}
+
+// exportObjects writes the certificates of store as .pfx or .pem files and its keys as backup blobs
+// into dir, together with a manifest.json describing them. Files are only readable by the owner
+// since they contain private keys.
+func exportObjects(ctx context.Context, provider *azureProvider.AzureSecretProvider, store, dir string, kinds map[azureProvider.Kind]bool, opts migrate.Options) (*objectManifest, error) {
+	manifest := &objectManifest{Store: store}
+	var errs []error
+	if kinds[azureProvider.KindCertificates] {
+		certificates, err := batch.Retry(ctx, opts.Retry, func() ([]azureProvider.CertificateProperties, error) {
+			return provider.ListCertificates(ctx, store)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list certificates: %w", err)
+		}
+		sort.Slice(certificates, func(i, j int) bool { return certificates[i].Name < certificates[j].Name })
+		entries := make([]*certificateEntry, len(certificates))
+		certErrs := make([]error, len(certificates))
+		batch.ForEach(ctx, len(certificates), opts.Concurrency, func(ctx context.Context, i int) {
+			entries[i], certErrs[i] = exportCertificate(ctx, provider, store, dir, certificates[i].Name, opts)
+		})
+		for i, entry := range entries {
+			if certErrs[i] != nil {
+				errs = append(errs, certErrs[i])
+			} else if entry != nil {
+				manifest.Certificates = append(manifest.Certificates, *entry)
+			}
+		}
+	}
+	if kinds[azureProvider.KindKeys] {
+		keys, err := batch.Retry(ctx, opts.Retry, func() ([]azureProvider.KeyProperties, error) {
+			return provider.ListKeys(ctx, store)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list keys: %w", err)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+		entries := make([]*keyEntry, len(keys))
+		keyErrs := make([]error, len(keys))
+		batch.ForEach(ctx, len(keys), opts.Concurrency, func(ctx context.Context, i int) {
+			entries[i], keyErrs[i] = exportKey(ctx, provider, store, dir, keys[i].Name, opts)
+		})
+		for i, entry := range entries {
+			if keyErrs[i] != nil {
+				errs = append(errs, keyErrs[i])
+			} else if entry != nil {
+				manifest.Keys = append(manifest.Keys, *entry)
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := writePrivateFile(filepath.Join(dir, "manifest.json"), data); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return manifest, errors.Join(errs...)
+}
+
+func exportCertificate(ctx context.Context, provider *azureProvider.AzureSecretProvider, store, dir, name string, opts migrate.Options) (*certificateEntry, error) {
+	cert, err := batch.Retry(ctx, opts.Retry, func() (*azureProvider.Certificate, error) {
+		return provider.GetCertificate(ctx, store, name)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificate %s: %w", name, err)
+	}
+	file := filepath.Join("certificates", name+".pem")
+	data := []byte(cert.Content)
+	if cert.ContentType == azureProvider.ContentTypePKCS12 {
+		file = filepath.Join("certificates", name+".pfx")
+		if data, err = base64.StdEncoding.DecodeString(cert.Content); err != nil {
+			return nil, fmt.Errorf("failed to decode certificate %s: %w", name, err)
+		}
+	}
+	if err := writePrivateFile(filepath.Join(dir, file), data); err != nil {
+		return nil, err
+	}
+	return &certificateEntry{Certificate: *cert, File: file}, nil
+}
+
+func exportKey(ctx context.Context, provider *azureProvider.AzureSecretProvider, store, dir, name string, opts migrate.Options) (*keyEntry, error) {
+	backup, err := batch.Retry(ctx, opts.Retry, func() ([]byte, error) {
+		return provider.BackupKey(ctx, store, name)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up key %s: %w", name, err)
+	}
+	file := filepath.Join("keys", name+".keybackup")
+	if err := writePrivateFile(filepath.Join(dir, file), backup); err != nil {
+		return nil, err
+	}
+	return &keyEntry{Name: name, File: file}, nil
+}
+
+// writePrivateFile writes a file only the current user can read, creating its directory
+func writePrivateFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := utils.WritePrivateFile(path, data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package azure
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	azureProvider "github.com/hazyforge/hazyctl/internal/secret/providers/azure"
+)
+
+func TestWriteResults(t *testing.T) {
+	// a cancelled run leaves the slots of unprocessed objects empty
+	results := []*objectResult{
+		{Kind: azureProvider.KindCertificates, Name: "web", Target: "web-dr", Action: migrate.ActionCreate},
+		nil,
+		{Kind: azureProvider.KindKeys, Name: "sign", Err: errors.New("forbidden")},
+		{Kind: azureProvider.KindKeys, Name: "wrap", Action: migrate.ActionSkip, Reason: "exists in destination"},
+	}
+	var out bytes.Buffer
+	if failed := writeResults(&out, results, false); failed != 1 {
+		t.Errorf("%d failed, want 1", failed)
+	}
+	want := `+ certificates web -> web-dr: would create
+! keys sign: forbidden
+= keys wrap: would skip (exists in destination)
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
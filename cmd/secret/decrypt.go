@@ -0,0 +1,54 @@
+package secret
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hazyforge/hazyctl/internal/secret/crypt"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+	"github.com/hazyforge/hazyctl/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+func newDecryptCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "decrypt",
+		Short: "Decrypt an encrypted export file",
+		Long: `Decrypt an export file written with --encrypt.
+
+Files encrypted for age recipients are decrypted with --identity files, files
+encrypted with a passphrase read it from ` + crypt.PassphraseEnv + ` or prompt for it.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			input, _ := cmd.Flags().GetString("input")
+			output, _ := cmd.Flags().GetString("output")
+			identities, err := crypt.IdentitiesFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(input)
+			if err != nil {
+				return fmt.Errorf("failed to read file: %w", err)
+			}
+			if !crypt.IsEncrypted(data) {
+				return fmt.Errorf("%s is not encrypted", input)
+			}
+			plaintext, err := crypt.Decrypt(data, identities)
+			if err != nil {
+				return err
+			}
+			if err := utils.WritePrivateFile(output, plaintext); err != nil {
+				return err
+			}
+			redact.Println("Decrypted", input, "to", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("input", "i", "", "Encrypted export file")
+	cmd.Flags().StringP("output", "o", "", "Path of the decrypted file")
+	crypt.AddDecryptFlags(cmd.Flags())
+	cmd.MarkFlagRequired("input")
+	cmd.MarkFlagRequired("output")
+
+	return cmd
+}
@@ -0,0 +1,90 @@
+package secret
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+	"github.com/spf13/cobra"
+)
+
+func newDeletedCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "deleted",
+		Short: "List and recover soft-deleted secrets",
+	}
+	cmd.PersistentFlags().String("store", "", "Name of the store, e.g. the Key Vault name")
+	cmd.MarkPersistentFlagRequired("store")
+	cmd.AddCommand(newDeletedListCmd())
+	cmd.AddCommand(newDeletedRecoverCmd())
+	return cmd
+}
+
+// newDeletedProvider returns the selected provider if it supports soft-deleted secrets
+func newDeletedProvider() (providers.DeletedSecretProvider, error) {
+	provider, err := newProvider()
+	if err != nil {
+		return nil, err
+	}
+	deleted, ok := provider.(providers.DeletedSecretProvider)
+	if !ok {
+		return nil, fmt.Errorf("provider does not support soft-deleted secrets")
+	}
+	return deleted, nil
+}
+
+func newDeletedListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List soft-deleted secrets",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, _ := cmd.Flags().GetString("store")
+			provider, err := newDeletedProvider()
+			if err != nil {
+				return err
+			}
+			deleted, err := provider.ListDeletedSecrets(cmd.Context(), store)
+			if err != nil {
+				return fmt.Errorf("failed to list deleted secrets: %w", err)
+			}
+
+			w := tabwriter.NewWriter(redact.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tDELETED\tPURGE SCHEDULED")
+			for _, secret := range deleted {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", secret.Name, formatTime(secret.DeletedDate), formatTime(secret.ScheduledPurgeDate))
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newDeletedRecoverCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "recover <name>...",
+		Short: "Recover soft-deleted secrets with all their versions",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, _ := cmd.Flags().GetString("store")
+			provider, err := newDeletedProvider()
+			if err != nil {
+				return err
+			}
+			for _, name := range args {
+				if err := provider.RecoverDeletedSecret(cmd.Context(), store, name); err != nil {
+					return fmt.Errorf("failed to recover secret %s: %w", name, err)
+				}
+				redact.Printf("Recovered secret: %s\n", name)
+			}
+			return nil
+		},
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
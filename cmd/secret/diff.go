@@ -0,0 +1,103 @@
+package secret
+
+import (
+	"strings"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/crypt"
+	"github.com/hazyforge/hazyctl/internal/secret/diff"
+	"github.com/hazyforge/hazyctl/internal/secret/export"
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+	"github.com/hazyforge/hazyctl/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+func newDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff <a> <b>",
+		Short: "Compare the secrets of two stores or export files",
+		Long: `Compare the secrets of two stores or export files.
+Stores are addressed as <provider>://<store>, anything else is read as an export file.
+
+Values are compared by hash and never printed. Like diff(1), the command exits
+with 0 when both sides match, 1 when they differ and 2 on errors.`,
+		Example: `  hazyctl secret diff azure://vault1 azure://vault2
+  hazyctl secret diff azure://vault1 secrets.json --format json`,
+		// usage errors exit with 2 like any other error, 1 only means the sides differ
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+				return &utils.ExitError{Code: 2, Err: err}
+			}
+			return nil
+		},
+		// differences are reported through the exit code, not as a usage error
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := runDiff(cmd, args)
+			if err != nil {
+				return &utils.ExitError{Code: 2, Err: err}
+			}
+			if len(result.Entries) > 0 {
+				return &utils.ExitError{Code: 1}
+			}
+			return nil
+		},
+	}
+
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &utils.ExitError{Code: 2, Err: err}
+	})
+	cmd.Flags().String("format", "text", "Output format, text or json")
+	batch.AddFlags(cmd.Flags())
+	filter.AddSelectorFlags(cmd.Flags())
+	crypt.AddDecryptFlags(cmd.Flags())
+
+	return cmd
+}
+
+func runDiff(cmd *cobra.Command, args []string) (*diff.Result, error) {
+	format, _ := cmd.Flags().GetString("format")
+	concurrency, retry, err := batch.FromFlags(cmd.Flags())
+	if err != nil {
+		return nil, err
+	}
+	selector, err := filter.SelectorFromFlags(cmd.Flags())
+	if err != nil {
+		return nil, err
+	}
+	identities, err := crypt.IdentitiesFromFlags(cmd.Flags())
+	if err != nil {
+		return nil, err
+	}
+	a, err := openSource(args[0], identities)
+	if err != nil {
+		return nil, err
+	}
+	b, err := openSource(args[1], identities)
+	if err != nil {
+		return nil, err
+	}
+	result, err := diff.Compare(cmd.Context(), a, b, diff.Options{Concurrency: concurrency, Retry: retry, Selector: selector})
+	if err != nil {
+		return nil, err
+	}
+	return result, result.Write(redact.Stdout, format)
+}
+
+// openSource opens a <provider>://<store> address, or an export file for any other argument
+func openSource(arg string, identities []string) (migrate.Endpoint, error) {
+	if strings.Contains(arg, "://") {
+		return openEndpoint(arg)
+	}
+	secrets, err := export.ReadFile(arg, "", identities)
+	if err != nil {
+		return migrate.Endpoint{}, err
+	}
+	provider, err := export.NewFileProvider(secrets)
+	if err != nil {
+		return migrate.Endpoint{}, err
+	}
+	return migrate.Endpoint{Provider: provider, Store: arg}, nil
+}
@@ -0,0 +1,74 @@
+package secret
+
+import (
+	"fmt"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/crypt"
+	"github.com/hazyforge/hazyctl/internal/secret/export"
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+	"github.com/spf13/cobra"
+)
+
+func newExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export secrets from a store of the selected provider",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			store, _ := cmd.Flags().GetString("store")
+			outputPath, _ := cmd.Flags().GetString("output")
+			provider, err := newProvider()
+			if err != nil {
+				return err
+			}
+			concurrency, retry, err := batch.FromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			states, err := filter.StateRulesFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			format, formatOpts, err := export.FormatFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			encryption, err := crypt.EncryptFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			result, exportErr := export.Export(ctx, provider, store, export.Options{Concurrency: concurrency, Retry: retry, States: states})
+			if result == nil {
+				return fmt.Errorf("failed to export secrets: %w", exportErr)
+			}
+			secrets := result.Secrets
+			for _, secret := range secrets {
+				redact.Printf("Name: %s, Value: %s\n", secret.Name, redact.Value(secret.Value))
+			}
+
+			if outputPath != "" {
+				if err := export.WriteFile(outputPath, format, secrets, formatOpts, encryption); err != nil {
+					return err
+				}
+				redact.Println("Secrets written to", outputPath)
+			}
+			redact.Println(result.Summary())
+			if exportErr != nil {
+				return fmt.Errorf("failed to export secrets: %w", exportErr)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().String("store", "", "Name of the store to export, e.g. the Key Vault name")
+	cmd.Flags().StringP("output", "o", "secrets.json", "Output file path")
+	batch.AddFlags(cmd.Flags())
+	filter.AddStateFlags(cmd.Flags())
+	export.AddFormatFlags(cmd.Flags())
+	crypt.AddEncryptFlags(cmd.Flags())
+	cmd.MarkFlagRequired("store")
+
+	return cmd
+}
@@ -0,0 +1,81 @@
+package secret
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+	"github.com/hazyforge/hazyctl/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+func newGetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get <name>",
+		Short: "Show a secret of the selected provider",
+		Long: `Show a secret of the selected provider.
+
+The value is masked unless --show-values is set. Use --output to write the raw
+value to a file instead, created with 0600 permissions.`,
+		Example: `  hazyctl secret get --store vault1 db-password
+  hazyctl secret get --store vault1 tls-cert --version 1a2b3c --output cert.pem`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, _ := cmd.Flags().GetString("store")
+			version, _ := cmd.Flags().GetString("version")
+			outputPath, _ := cmd.Flags().GetString("output")
+			provider, err := newProvider()
+			if err != nil {
+				return err
+			}
+			secret, err := provider.GetSecret(cmd.Context(), store, args[0], version)
+			if err != nil {
+				return fmt.Errorf("failed to get secret %s: %w", args[0], err)
+			}
+			redact.Register(secret.Value)
+
+			if outputPath != "" {
+				if err := utils.WritePrivateFile(outputPath, []byte(secret.Value)); err != nil {
+					return err
+				}
+				redact.Printf("Secret %s version %s written to %s\n", secret.Name, secret.Version, outputPath)
+				return nil
+			}
+			redact.Printf("Name:         %s\n", secret.Name)
+			redact.Printf("Version:      %s\n", secret.Version)
+			redact.Printf("Content type: %s\n", secret.ContentType)
+			redact.Printf("Enabled:      %t\n", secret.Enabled)
+			redact.Printf("Expires:      %s\n", formatTime(secret.Expires))
+			redact.Printf("Not before:   %s\n", formatTime(secret.NotBefore))
+			redact.Printf("Updated:      %s\n", formatTime(secret.Updated))
+			redact.Printf("Tags:         %s\n", formatTags(secret.Tags))
+			redact.Printf("Value:        %s\n", redact.Value(secret.Value))
+			return nil
+		},
+	}
+
+	addStoreFlag(cmd)
+	cmd.Flags().String("version", "", "Version to read, the current one when empty")
+	cmd.Flags().StringP("output", "o", "", "Write the raw value to this file instead of printing it")
+
+	return cmd
+}
+
+// addStoreFlag registers the required --store flag of the single secret commands
+func addStoreFlag(cmd *cobra.Command) {
+	cmd.Flags().String("store", "", "Name of the store, e.g. the Key Vault name")
+	cmd.MarkFlagRequired("store")
+}
+
+func formatTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return "-"
+	}
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,145 @@
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+	"github.com/spf13/cobra"
+)
+
+func newListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the secrets of a store of the selected provider",
+		Example: `  hazyctl secret list --store vault1
+  hazyctl secret list --store vault1 --include 'db-*' --format json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, _ := cmd.Flags().GetString("store")
+			format, _ := cmd.Flags().GetString("format")
+			selector, err := filter.SelectorFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			provider, err := newProvider()
+			if err != nil {
+				return err
+			}
+			items, err := provider.ListSecrets(cmd.Context(), store)
+			if err != nil {
+				return fmt.Errorf("failed to list secrets: %w", err)
+			}
+			selected := make([]providers.SecretProperties, 0, len(items))
+			for _, item := range items {
+				if selector.Match(item) {
+					selected = append(selected, item)
+				}
+			}
+			sort.Slice(selected, func(i, j int) bool { return selected[i].Name < selected[j].Name })
+			return writeProperties(selected, format, "NAME", func(p providers.SecretProperties) string { return p.Name })
+		},
+	}
+
+	addStoreFlag(cmd)
+	cmd.Flags().String("format", "table", "Output format, table or json")
+	filter.AddSelectorFlags(cmd.Flags())
+
+	return cmd
+}
+
+func newVersionsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "versions <name>",
+		Short: "List the versions of a secret, oldest first",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, _ := cmd.Flags().GetString("store")
+			format, _ := cmd.Flags().GetString("format")
+			provider, err := newProvider()
+			if err != nil {
+				return err
+			}
+			versions, err := provider.ListVersions(cmd.Context(), store, args[0])
+			if err != nil {
+				return fmt.Errorf("failed to list versions of %s: %w", args[0], err)
+			}
+			sort.SliceStable(versions, func(i, j int) bool {
+				return timeOrZero(versions[i].Created).Before(timeOrZero(versions[j].Created))
+			})
+			return writeProperties(versions, format, "VERSION", func(p providers.SecretProperties) string {
+				if len(p.Labels) == 0 {
+					return p.Version
+				}
+				return p.Version + " (" + strings.Join(p.Labels, ",") + ")"
+			})
+		},
+	}
+
+	addStoreFlag(cmd)
+	cmd.Flags().String("format", "table", "Output format, table or json")
+
+	return cmd
+}
+
+func newDeleteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete <name>...",
+		Short: "Delete secrets with all their versions",
+		Long: `Delete secrets with all their versions.
+
+Providers with soft-delete, such as Key Vault, keep deleted secrets recoverable
+with "secret deleted recover" until they are purged.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, _ := cmd.Flags().GetString("store")
+			provider, err := newProvider()
+			if err != nil {
+				return err
+			}
+			for _, name := range args {
+				if err := provider.DeleteSecret(cmd.Context(), store, name); err != nil {
+					return fmt.Errorf("failed to delete secret %s: %w", name, err)
+				}
+				redact.Printf("Deleted secret: %s\n", name)
+			}
+			return nil
+		},
+	}
+
+	addStoreFlag(cmd)
+
+	return cmd
+}
+
+// writeProperties prints secret properties as a table, keyed by the given column, or as json
+func writeProperties(items []providers.SecretProperties, format, column string, key func(providers.SecretProperties) string) error {
+	switch format {
+	case "", "table":
+		w := tabwriter.NewWriter(redact.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "%s\tENABLED\tEXPIRES\tUPDATED\tTAGS\n", column)
+		for _, item := range items {
+			fmt.Fprintf(w, "%s\t%t\t%s\t%s\t%s\n", key(item), item.Enabled, formatTime(item.Expires), formatTime(item.Updated), formatTags(item.Tags))
+		}
+		return w.Flush()
+	case "json":
+		encoder := json.NewEncoder(redact.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(items)
+	default:
+		return fmt.Errorf("unknown format %q, expected table or json", format)
+	}
+}
+
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
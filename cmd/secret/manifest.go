@@ -0,0 +1,105 @@
+package secret
+
+import (
+	"errors"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/manifest"
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+	"github.com/spf13/cobra"
+)
+
+const manifestHelp = `The manifest names the store it describes and every desired secret with its
+tags, content type and expiry. Values never live in the manifest, each secret
+reads its value from another store, an environment variable, a file or a
+generator. Generated values are created once and kept on later runs.
+
+  store: azure://app-vault
+  secrets:
+    - name: db-password
+      tags: {team: platform}
+      expires: 2027-01-01T00:00:00Z
+      source:
+        store: {address: azure://shared-vault, name: postgres-password}
+    - name: api-key
+      source: {env: API_KEY}
+    - name: tls-ca
+      contentType: application/x-pem-file
+      source: {file: certs/ca.pem}
+    - name: session-key
+      source:
+        generate: {length: 48, charset: alphanumeric}
+
+Secrets in the store that are not in the manifest are left alone unless --prune is set.`
+
+func newPlanCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Show the changes needed to converge a store to a manifest",
+		Long:  "Show the changes needed to converge a store to a manifest, without writing anything.\n\n" + manifestHelp,
+		Example: `  hazyctl secret plan -f secrets.yaml
+  hazyctl secret plan -f secrets.yaml --format json > plan.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runManifest(cmd, true)
+		},
+	}
+	addManifestFlags(cmd)
+	return cmd
+}
+
+func newApplyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Converge a store to a manifest",
+		Long:  "Converge a store to a manifest, creating and updating secrets that differ.\n\n" + manifestHelp,
+		Example: `  hazyctl secret apply -f secrets.yaml
+  hazyctl secret apply -f secrets.yaml --prune --max-deletions 3`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runManifest(cmd, false)
+		},
+	}
+	addManifestFlags(cmd)
+	return cmd
+}
+
+func addManifestFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("file", "f", "", "Manifest file")
+	cmd.Flags().String("to", "", "Store address overriding the store of the manifest, <provider>://<store>")
+	cmd.Flags().String("format", "text", "Plan output format, text or json")
+	cmd.Flags().Bool("strict-metadata", false, "Fail secrets whose metadata the store cannot store")
+	cmd.Flags().Bool("prune", false, "Delete secrets of the store that are not in the manifest")
+	cmd.Flags().Int("max-deletions", 10, "Abort when pruning would delete more secrets than this, -1 for no limit")
+	batch.AddFlags(cmd.Flags())
+	cmd.MarkFlagRequired("file")
+}
+
+func runManifest(cmd *cobra.Command, dryRun bool) error {
+	file, _ := cmd.Flags().GetString("file")
+	to, _ := cmd.Flags().GetString("to")
+	opts := migrate.Options{DryRun: dryRun, OnConflict: migrate.ConflictOverwriteIfDifferent}
+	opts.Format, _ = cmd.Flags().GetString("format")
+	opts.StrictMetadata, _ = cmd.Flags().GetBool("strict-metadata")
+	opts.Prune, _ = cmd.Flags().GetBool("prune")
+	opts.MaxDeletions, _ = cmd.Flags().GetInt("max-deletions")
+	var err error
+	if opts.Concurrency, opts.Retry, err = batch.FromFlags(cmd.Flags()); err != nil {
+		return err
+	}
+
+	m, err := manifest.Load(file)
+	if err != nil {
+		return err
+	}
+	if to == "" {
+		to = m.Store
+	}
+	if to == "" {
+		return errors.New("the manifest names no store, set one with --to")
+	}
+	dst, err := openEndpoint(to)
+	if err != nil {
+		return err
+	}
+	return manifest.Execute(cmd.Context(), m, dst, opts, redact.Stdout)
+}
@@ -0,0 +1,67 @@
+package secret
+
+import (
+	"fmt"
+
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+	"github.com/spf13/cobra"
+)
+
+func newMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate secrets between stores of any registered providers",
+		Long: `Migrate secrets between stores of any registered providers.
+Stores are addressed as <provider>://<store>, e.g. azure://my-vault.
+
+Metadata the destination cannot store is moved into tags where possible
+and reported otherwise. Use --strict-metadata to fail those secrets instead.
+Use --dry-run to print the plan without writing anything.`,
+		Example: `  hazyctl secret migrate --from azure://vault1 --to azure://vault2
+  hazyctl secret migrate --from azure://vault1 --to azure://vault2 --dry-run --format json > plan.json
+  hazyctl secret migrate --from azure://shared --to azure://team-a --include 'team-a-*' --strip-prefix team-a-`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			from, _ := cmd.Flags().GetString("from")
+			to, _ := cmd.Flags().GetString("to")
+			opts, err := migrate.OptionsFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+
+			src, err := openEndpoint(from)
+			if err != nil {
+				return err
+			}
+			dst, err := openEndpoint(to)
+			if err != nil {
+				return err
+			}
+
+			return migrate.Execute(ctx, src, dst, opts, redact.Stdout)
+		},
+	}
+
+	cmd.Flags().String("from", "", "Source store address, <provider>://<store>")
+	cmd.Flags().String("to", "", "Destination store address, <provider>://<store>")
+	migrate.AddFlags(cmd.Flags())
+	cmd.MarkFlagRequired("from")
+	cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+// openEndpoint creates the provider for a <provider>://<store> address
+func openEndpoint(uri string) (migrate.Endpoint, error) {
+	ref, err := providers.ParseStoreRef(uri)
+	if err != nil {
+		return migrate.Endpoint{}, err
+	}
+	provider, err := ref.Open()
+	if err != nil {
+		return migrate.Endpoint{}, fmt.Errorf("failed to create %s provider: %w", ref.Provider, err)
+	}
+	return migrate.Endpoint{Provider: provider, Store: ref.Store}, nil
+}
@@ -0,0 +1,68 @@
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+	"github.com/hazyforge/hazyctl/internal/secret/render"
+	"github.com/hazyforge/hazyctl/pkg/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func newRenderCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "Render a Go template with secret references into a file",
+		Long: `Render a Go text/template with secret references into a file.
+
+Templates reference secrets as [<provider>://]<store>/<name>, the provider
+defaults to --provider:
+
+  {{ secret "vault1/db-password" }}
+  {{ secretVersion "aws://eu-west-1/api-key" "v2" }}
+  {{ secret "vault1/tls-key" | base64 }}
+  "password": "{{ secret "vault1/db-password" | jsonEscape }}"
+
+Every referenced secret is read once. References below {{ if }} or {{ range }}
+on a secret value are only found once that value is read, each such level takes
+another batch of reads. When references are invalid or cannot be resolved all of
+them are reported and no output is written. The output file is created with
+0600 permissions.`,
+		Example: `  hazyctl secret render -f app.conf.tmpl -o app.conf`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, _ := cmd.Flags().GetString("file")
+			output, _ := cmd.Flags().GetString("output")
+			concurrency, retry, err := batch.FromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			text, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read template: %w", err)
+			}
+			var out bytes.Buffer
+			opts := render.Options{DefaultProvider: viper.GetString("secret.provider"), Concurrency: concurrency, Retry: retry}
+			if err := render.Render(cmd.Context(), file, string(text), &out, opts); err != nil {
+				return err
+			}
+			if err := utils.WritePrivateFile(output, out.Bytes()); err != nil {
+				return err
+			}
+			redact.Println("Rendered", file, "to", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("file", "f", "", "Template file")
+	cmd.Flags().StringP("output", "o", "", "Path of the rendered file")
+	batch.AddFlags(cmd.Flags())
+	cmd.MarkFlagRequired("file")
+	cmd.MarkFlagRequired("output")
+
+	return cmd
+}
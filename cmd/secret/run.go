@@ -0,0 +1,229 @@
+package secret
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+	"github.com/hazyforge/hazyctl/pkg/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// forwardedSignals are passed on to the child process, they are usually sent to hazyctl alone
+var forwardedSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP}
+
+// terminalSignals are sent by the terminal to its whole foreground process group, the child
+// receives them directly. hazyctl ignores them so it outlives the child and reports its exit code.
+var terminalSignals = []os.Signal{os.Interrupt, syscall.SIGQUIT}
+
+func newRunCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run [flags] -- <command> [args...]",
+		Short: "Run a command with secrets in its environment",
+		Long: `Run a command with secrets in its environment.
+
+Each --map ENV=name sets the environment variable ENV of the command to the
+current value of the secret name. Mappings can also be read from --map-file,
+one ENV=name per line, with # comments. The values are only passed to the
+command, never printed or written to disk. --vault is an alias of --store.
+
+SIGTERM and SIGHUP are forwarded to the command, Ctrl-C reaches it from the
+terminal directly, and hazyctl exits with its exit code.`,
+		Example: `  hazyctl secret run --store vault1 --map DB_PASS=db-password -- ./app
+  hazyctl secret run --vault vault1 --map DB_PASS=db-password -- ./app
+  hazyctl secret run --store vault1 --map-file app.secrets -- npm start`,
+		Args: cobra.MinimumNArgs(1),
+		// the exit code of the command is passed on as is, it is not a usage error
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, _ := cmd.Flags().GetString("store")
+			maps, _ := cmd.Flags().GetStringArray("map")
+			mapFiles, _ := cmd.Flags().GetStringArray("map-file")
+			concurrency, retry, err := batch.FromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			mappings, err := loadMappings(maps, mapFiles)
+			if err != nil {
+				return err
+			}
+			if len(mappings) == 0 {
+				return errors.New("no secrets mapped, use --map or --map-file")
+			}
+			provider, err := newProvider()
+			if err != nil {
+				return err
+			}
+			env, err := resolveMappings(cmd.Context(), provider, store, mappings, concurrency, retry)
+			if err != nil {
+				return err
+			}
+			return runChild(args, env)
+		},
+	}
+
+	addStoreFlag(cmd)
+	// --vault is accepted for --store, the name Key Vault users reach for
+	cmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "vault" {
+			name = "store"
+		}
+		return pflag.NormalizedName(name)
+	})
+	cmd.Flags().StringArray("map", nil, "Environment variable and secret as ENV=name, may be repeated")
+	cmd.Flags().StringArray("map-file", nil, "File with one ENV=name mapping per line, may be repeated")
+	batch.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+// mapping sets an environment variable to the value of a secret
+type mapping struct {
+	env    string
+	secret string
+}
+
+// loadMappings parses the --map flags and --map-file files, later mappings of a variable win
+func loadMappings(maps, files []string) ([]mapping, error) {
+	var mappings []mapping
+	for _, path := range files {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read map file: %w", err)
+		}
+		scanner := bufio.NewScanner(file)
+		for n := 1; scanner.Scan(); n++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			m, err := parseMapping(line)
+			if err != nil {
+				file.Close()
+				return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			mappings = append(mappings, m)
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read map file: %w", err)
+		}
+	}
+	for _, s := range maps {
+		m, err := parseMapping(s)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+func parseMapping(s string) (mapping, error) {
+	env, name, ok := strings.Cut(s, "=")
+	env, name = strings.TrimSpace(env), strings.TrimSpace(name)
+	if !ok || name == "" {
+		return mapping{}, fmt.Errorf("invalid mapping %q, expected ENV=name", s)
+	}
+	if !envNamePattern.MatchString(env) {
+		return mapping{}, fmt.Errorf("invalid environment variable name %q", env)
+	}
+	return mapping{env: env, secret: name}, nil
+}
+
+// resolveMappings reads every referenced secret once and returns the environment entries.
+// Every secret that cannot be read is reported at once.
+func resolveMappings(ctx context.Context, provider providers.SecretProvider, store string, mappings []mapping, concurrency int, retry batch.RetryPolicy) ([]string, error) {
+	var names []string
+	index := make(map[string]int)
+	for _, m := range mappings {
+		if _, ok := index[m.secret]; !ok {
+			index[m.secret] = len(names)
+			names = append(names, m.secret)
+		}
+	}
+	values := make([]string, len(names))
+	errs := make([]error, len(names))
+	batch.ForEach(ctx, len(names), concurrency, func(ctx context.Context, i int) {
+		secret, err := batch.Retry(ctx, retry, func() (*providers.Secret, error) {
+			return provider.GetSecret(ctx, store, names[i], "")
+		})
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to get secret %s: %w", names[i], err)
+			return
+		}
+		redact.Register(secret.Value)
+		values[i] = secret.Value
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	env := make([]string, 0, len(mappings))
+	for _, m := range mappings {
+		env = append(env, m.env+"="+values[index[m.secret]])
+	}
+	return env, nil
+}
+
+// runChild runs the command with env added to the environment of hazyctl, forwarding
+// termination signals to it, and returns its exit code as an ExitError
+func runChild(args, env []string) error {
+	child := exec.Command(args[0], args[1:]...)
+	child.Env = append(os.Environ(), env...)
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+	// caught rather than ignored, the child would inherit ignored signals
+	ignored := make(chan os.Signal, 1)
+	signal.Notify(ignored, terminalSignals...)
+	defer signal.Stop(ignored)
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", args[0], err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				child.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := child.Wait()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		if err != nil {
+			return fmt.Errorf("failed to run %s: %w", args[0], err)
+		}
+		return nil
+	}
+	code := exitErr.ExitCode()
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		// like a shell, report a command killed by a signal as 128 plus the signal number
+		code = 128 + int(status.Signal())
+	}
+	return &utils.ExitError{Code: code}
+}
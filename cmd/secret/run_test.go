@@ -0,0 +1,40 @@
+package secret
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hazyforge/hazyctl/pkg/utils"
+)
+
+func TestRunChildSignals(t *testing.T) {
+	tests := []struct {
+		name   string
+		signal syscall.Signal
+		want   int
+	}{
+		// the terminal delivers SIGINT to the child itself, hazyctl must not send a second one
+		{"interrupt is not forwarded", syscall.SIGINT, 0},
+		{"terminate is forwarded", syscall.SIGTERM, 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			go func() {
+				// signals hazyctl once the child had time to install its traps
+				time.Sleep(300 * time.Millisecond)
+				syscall.Kill(syscall.Getpid(), tt.signal)
+			}()
+			script := `trap 'exit 2' INT; trap 'exit 15' TERM; i=0; while [ $i -lt 10 ]; do sleep 0.1; i=$((i+1)); done`
+			err := runChild([]string{"sh", "-c", script}, nil)
+			var exitErr *utils.ExitError
+			switch {
+			case tt.want == 0 && err != nil:
+				t.Errorf("got %v, want the child to finish", err)
+			case tt.want != 0 && (!errors.As(err, &exitErr) || exitErr.Code != tt.want):
+				t.Errorf("got %v, want exit code %d", err, tt.want)
+			}
+		})
+	}
+}
@@ -2,32 +2,71 @@ package secret
 
 import (
 	"github.com/hazyforge/hazyctl/cmd/secret/azure"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	_ "github.com/hazyforge/hazyctl/internal/secret/providers/aws"
+	_ "github.com/hazyforge/hazyctl/internal/secret/providers/azure"
+	_ "github.com/hazyforge/hazyctl/internal/secret/providers/gcp"
+	_ "github.com/hazyforge/hazyctl/internal/secret/providers/k8"
+	_ "github.com/hazyforge/hazyctl/internal/secret/providers/local"
+	_ "github.com/hazyforge/hazyctl/internal/secret/providers/vault"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-type SecretProvider interface {
-	Migrate(sourceVaultURL, destinationVaultURL string) error
-}
-
 var SecretCmd = &cobra.Command{
 	Use:   "secret",
 	Short: "secrets utilities",
 	Long: `secrets utilities
     hazyctl secret [provider] <flags> [command] <flags>
+    hazyctl secret --provider <provider> [command] <flags>
 
 	example:
 	1. migrate secrets from one vault to another on azure key vault
 		hazyctl secret azure migrate --source vault1 --destination vault2 -s 1234567890
 	2. export secrets to a local file
-		hazyctl secret azure export --vault vault1 --output secrets.json
+		hazyctl secret azure export --name vault1 --output secrets.json
+	3. export secrets from any registered provider
+		hazyctl secret export --provider azure --store vault1 --output secrets.json
 	`,
 }
 
 func init() {
-	SecretCmd.PersistentFlags().StringP("provider", "p", "", "the provider to use")
+	SecretCmd.PersistentFlags().StringP("provider", "p", "azure", "the provider to use")
 	viper.BindPFlag("secret.provider", SecretCmd.PersistentFlags().Lookup("provider"))
+	SecretCmd.PersistentFlags().Bool("show-values", false, "print secret values in clear text instead of masking them")
+	viper.BindPFlag("secret.show-values", SecretCmd.PersistentFlags().Lookup("show-values"))
+	SecretCmd.PersistentFlags().String("aws-endpoint", "", "AWS Secrets Manager endpoint override, e.g. http://localhost:4566 for LocalStack")
+	viper.BindPFlag("aws.endpoint", SecretCmd.PersistentFlags().Lookup("aws-endpoint"))
+	// --endpoint is the original name of --aws-endpoint and kept for existing scripts
+	SecretCmd.PersistentFlags().String("endpoint", "", "alias of --aws-endpoint")
+	cobra.OnInitialize(func() {
+		redact.SetShowValues(viper.GetBool("secret.show-values"))
+		flags := SecretCmd.PersistentFlags()
+		if endpoint := flags.Lookup("endpoint"); endpoint.Changed && !flags.Changed("aws-endpoint") {
+			viper.Set("aws.endpoint", endpoint.Value.String())
+		}
+	})
 	SecretCmd.AddCommand(azure.AzureCmd)
+	SecretCmd.AddCommand(newExportCmd())
+	SecretCmd.AddCommand(newMigrateCmd())
+	SecretCmd.AddCommand(newDeletedCmd())
+	SecretCmd.AddCommand(newDecryptCmd())
+	SecretCmd.AddCommand(newDiffCmd())
+	SecretCmd.AddCommand(newSyncCmd())
+	SecretCmd.AddCommand(newPlanCmd())
+	SecretCmd.AddCommand(newApplyCmd())
+	SecretCmd.AddCommand(newGetCmd())
+	SecretCmd.AddCommand(newSetCmd())
+	SecretCmd.AddCommand(newListCmd())
+	SecretCmd.AddCommand(newVersionsCmd())
+	SecretCmd.AddCommand(newDeleteCmd())
+	SecretCmd.AddCommand(newRunCmd())
+	SecretCmd.AddCommand(newRenderCmd())
 }
 
-
+// newProvider returns the provider selected with --provider, configured from its config section
+func newProvider() (providers.SecretProvider, error) {
+	name := viper.GetString("secret.provider")
+	return providers.GetProvider(name, providers.LoadConfig(name))
+}
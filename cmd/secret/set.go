@@ -0,0 +1,159 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func newSetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set <name>",
+		Short: "Write a new version of a secret of the selected provider",
+		Long: `Write a new version of a secret of the selected provider.
+
+Values are never taken from the command line, where they would end up in the
+shell history and process list. The value is read from --file as is, or from
+standard input when it is not a terminal, or else prompted for. A single
+trailing newline is removed from values read from standard input.`,
+		Example: `  hazyctl secret set --store vault1 db-password
+  printf %s "$PASSWORD" | hazyctl secret set --store vault1 db-password --tag team=platform
+  hazyctl secret set --store vault1 tls-cert --file cert.pem --content-type application/x-pem-file`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			store, _ := cmd.Flags().GetString("store")
+			provider, err := newProvider()
+			if err != nil {
+				return err
+			}
+			if err := providers.ValidateName(provider, store, name); err != nil {
+				return err
+			}
+			secret, err := secretFromFlags(cmd, name)
+			if err != nil {
+				return err
+			}
+			if secret.Value, err = readValue(cmd); err != nil {
+				return err
+			}
+			redact.Register(secret.Value)
+
+			props, err := provider.PutSecret(cmd.Context(), store, secret)
+			if err != nil {
+				return fmt.Errorf("failed to set secret %s: %w", name, err)
+			}
+			redact.Printf("Set secret %s, version %s\n", props.Name, props.Version)
+			return nil
+		},
+	}
+
+	addStoreFlag(cmd)
+	cmd.Flags().StringP("file", "f", "", "Read the value from this file")
+	cmd.Flags().String("content-type", "", "Content type of the secret")
+	cmd.Flags().StringArray("tag", nil, "Tag as key=value, may be repeated")
+	cmd.Flags().String("expires", "", "Expiry time, RFC 3339")
+	cmd.Flags().String("not-before", "", "Time the secret becomes valid, RFC 3339")
+	cmd.Flags().Bool("disabled", false, "Create the version disabled")
+
+	return cmd
+}
+
+// secretFromFlags builds the properties of the new version
+func secretFromFlags(cmd *cobra.Command, name string) (providers.Secret, error) {
+	secret := providers.Secret{SecretProperties: providers.SecretProperties{Name: name, Enabled: true}}
+	secret.ContentType, _ = cmd.Flags().GetString("content-type")
+	disabled, _ := cmd.Flags().GetBool("disabled")
+	secret.Enabled = !disabled
+
+	tags, _ := cmd.Flags().GetStringArray("tag")
+	for _, tag := range tags {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok || key == "" {
+			return secret, fmt.Errorf("invalid tag %q, expected key=value", tag)
+		}
+		if secret.Tags == nil {
+			secret.Tags = make(map[string]string, len(tags))
+		}
+		secret.Tags[key] = value
+	}
+
+	var err error
+	if secret.Expires, err = timeFlag(cmd, "expires"); err != nil {
+		return secret, err
+	}
+	if secret.NotBefore, err = timeFlag(cmd, "not-before"); err != nil {
+		return secret, err
+	}
+	return secret, nil
+}
+
+func timeFlag(cmd *cobra.Command, name string) (*time.Time, error) {
+	value, _ := cmd.Flags().GetString(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s %q, expected RFC 3339 such as 2027-01-01T00:00:00Z", name, value)
+	}
+	return &t, nil
+}
+
+// readValue reads the secret value from --file, standard input or a prompt
+func readValue(cmd *cobra.Command) (string, error) {
+	if path, _ := cmd.Flags().GetString("file"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read value file: %w", err)
+		}
+		return string(data), nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read value from standard input: %w", err)
+		}
+		if len(data) == 0 {
+			return "", errors.New("no value on standard input")
+		}
+		return trimNewline(string(data)), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Value: ")
+	value, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read value: %w", err)
+	}
+	fmt.Fprint(os.Stderr, "Confirm value: ")
+	again, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read value: %w", err)
+	}
+	if string(again) != string(value) {
+		return "", errors.New("values do not match")
+	}
+	if len(value) == 0 {
+		return "", errors.New("value must not be empty")
+	}
+	return string(value), nil
+}
+
+func trimNewline(s string) string {
+	if s, ok := strings.CutSuffix(s, "\n"); ok {
+		return strings.TrimSuffix(s, "\r")
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+package secret
+
+import (
+	"fmt"
+
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+	"github.com/spf13/cobra"
+)
+
+func newSyncCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Reconcile a destination store to match a source store",
+		Long: `Reconcile a destination store to match a source store.
+Stores are addressed as <provider>://<store>, e.g. azure://my-vault.
+
+Secrets missing from the destination are created and secrets that differ are
+updated, unchanged secrets are left alone so repeated runs write nothing. With
+--prune, destination secrets that no longer exist in the source are deleted,
+soft-deleted where the provider supports it. A run that would delete more than
+--max-deletions secrets is aborted before anything is written.`,
+		Example: `  hazyctl secret sync --from azure://primary --to azure://dr --dry-run
+  hazyctl secret sync --from azure://primary --to azure://dr --prune --max-deletions 5`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			from, _ := cmd.Flags().GetString("from")
+			to, _ := cmd.Flags().GetString("to")
+			opts, err := migrate.OptionsFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if opts.OnConflict == migrate.ConflictRename {
+				return fmt.Errorf("--on-conflict %s is not supported by sync, it would copy changed secrets again on every run", migrate.ConflictRename)
+			}
+			opts.Prune, _ = cmd.Flags().GetBool("prune")
+			opts.MaxDeletions, _ = cmd.Flags().GetInt("max-deletions")
+
+			src, err := openEndpoint(from)
+			if err != nil {
+				return err
+			}
+			dst, err := openEndpoint(to)
+			if err != nil {
+				return err
+			}
+
+			return migrate.Execute(ctx, src, dst, opts, redact.Stdout)
+		},
+	}
+
+	cmd.Flags().String("from", "", "Source store address, <provider>://<store>")
+	cmd.Flags().String("to", "", "Destination store address, <provider>://<store>")
+	cmd.Flags().Bool("prune", false, "Delete destination secrets that no longer exist in the source")
+	cmd.Flags().Int("max-deletions", 10, "Abort when pruning would delete more secrets than this, -1 for no limit")
+	migrate.AddFlags(cmd.Flags())
+	cmd.MarkFlagRequired("from")
+	cmd.MarkFlagRequired("to")
+
+	return cmd
+}
@@ -0,0 +1,39 @@
+package batch
+
+import (
+	"context"
+	"sync"
+)
+
+// ForEach calls fn for every index in [0, n) using at most concurrency goroutines.
+// Callers write results into index addressed slots so output order stays deterministic.
+// Indexes not yet started when ctx is cancelled are skipped.
+func ForEach(ctx context.Context, n, concurrency int, fn func(ctx context.Context, i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(ctx, i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
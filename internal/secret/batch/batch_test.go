@@ -0,0 +1,154 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/spf13/pflag"
+)
+
+func TestForEach(t *testing.T) {
+	for _, concurrency := range []int{0, 1, 3, 100} {
+		var running, peak int32
+		seen := make([]int32, 10)
+		ForEach(context.Background(), len(seen), concurrency, func(ctx context.Context, i int) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&seen[i], 1)
+			atomic.AddInt32(&running, -1)
+		})
+		for i, n := range seen {
+			if n != 1 {
+				t.Errorf("concurrency %d: index %d ran %d times", concurrency, i, n)
+			}
+		}
+		limit := int32(concurrency)
+		if limit < 1 {
+			limit = 1
+		}
+		if peak > limit {
+			t.Errorf("concurrency %d: %d calls ran at once", concurrency, peak)
+		}
+	}
+}
+
+func TestForEachCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	var ran []int
+	ForEach(ctx, 100, 1, func(ctx context.Context, i int) {
+		mu.Lock()
+		ran = append(ran, i)
+		mu.Unlock()
+		if i == 2 {
+			cancel()
+		}
+	})
+	// the index handed over while cancelling may still run
+	if len(ran) < 3 || len(ran) > 4 {
+		t.Errorf("ran %v, want the indexes after the cancellation skipped", ran)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+	throttled := &providers.ThrottledError{Err: errors.New("429")}
+	failed := errors.New("denied")
+
+	tests := []struct {
+		name     string
+		errs     []error
+		wantErr  error
+		attempts int
+	}{
+		{name: "success", errs: []error{nil}, attempts: 1},
+		{name: "throttled then success", errs: []error{throttled, throttled, nil}, attempts: 3},
+		{name: "other errors are not retried", errs: []error{failed}, wantErr: failed, attempts: 1},
+		{name: "retries exhausted", errs: []error{throttled, throttled, throttled, throttled, nil}, wantErr: throttled, attempts: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			got, err := Retry(context.Background(), policy, func() (int, error) {
+				err := tt.errs[attempts]
+				attempts++
+				return attempts, err
+			})
+			if !errors.Is(err, tt.wantErr) || attempts != tt.attempts {
+				t.Errorf("got %v after %d attempts, want %v after %d", err, attempts, tt.wantErr, tt.attempts)
+			}
+			if err == nil && got != attempts {
+				t.Errorf("got result %d, want the one of the last attempt", got)
+			}
+		})
+	}
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 1, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	start := time.Now()
+	attempts := 0
+	_, err := Retry(context.Background(), policy, func() (struct{}, error) {
+		attempts++
+		if attempts == 1 {
+			return struct{}{}, &providers.ThrottledError{RetryAfter: 10 * time.Millisecond, Err: errors.New("429")}
+		}
+		return struct{}{}, nil
+	})
+	if err != nil || attempts != 2 {
+		t.Fatalf("got %v after %d attempts", err, attempts)
+	}
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Errorf("waited %v, want the delay the provider asked for", elapsed)
+	}
+}
+
+func TestRetryCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	policy := RetryPolicy{MaxRetries: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	_, err := Retry(ctx, policy, func() (struct{}, error) {
+		return struct{}{}, &providers.ThrottledError{Err: errors.New("429")}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want the cancellation", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := policy.backoff(attempt); d <= 0 || d > max {
+				t.Errorf("attempt %d waited %v, want up to %v", attempt, d, max)
+			}
+		}
+	}
+	// shifting far enough overflows, the delay stays capped
+	if d := policy.backoff(70); d <= 0 || d > time.Second {
+		t.Errorf("attempt 70 waited %v", d)
+	}
+}
+
+func TestFromFlags(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	AddFlags(flags)
+	if err := flags.Parse([]string{"--concurrency", "3", "--max-retries", "7"}); err != nil {
+		t.Fatal(err)
+	}
+	concurrency, policy, err := FromFlags(flags)
+	if err != nil || concurrency != 3 || policy.MaxRetries != 7 || policy.BaseDelay != DefaultRetryPolicy.BaseDelay {
+		t.Errorf("got %d, %+v, %v", concurrency, policy, err)
+	}
+}
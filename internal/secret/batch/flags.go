@@ -0,0 +1,24 @@
+package batch
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// AddFlags registers the concurrency and retry flags shared by bulk commands
+func AddFlags(flags *pflag.FlagSet) {
+	flags.Int("concurrency", 8, "Number of secrets processed in parallel")
+	flags.Int("max-retries", DefaultRetryPolicy.MaxRetries, "Retries for throttled provider requests")
+}
+
+// FromFlags reads the flags registered by AddFlags
+func FromFlags(flags *pflag.FlagSet) (int, RetryPolicy, error) {
+	policy := DefaultRetryPolicy
+	concurrency, err := flags.GetInt("concurrency")
+	if err != nil {
+		return 0, policy, err
+	}
+	if policy.MaxRetries, err = flags.GetInt("max-retries"); err != nil {
+		return 0, policy, err
+	}
+	return concurrency, policy, nil
+}
@@ -0,0 +1,52 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+// RetryPolicy controls how throttled provider calls are retried
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultRetryPolicy is used when no policy is configured
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 5, BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second}
+
+// Retry calls fn until it succeeds, fails with an error that is not a providers.ThrottledError,
+// or the retries are exhausted. Waits back off exponentially with jitter unless the provider
+// asked for a specific delay.
+func Retry[T any](ctx context.Context, policy RetryPolicy, fn func() (T, error)) (T, error) {
+	for attempt := 0; ; attempt++ {
+		result, err := fn()
+		var throttled *providers.ThrottledError
+		if err == nil || !errors.As(err, &throttled) || attempt >= policy.MaxRetries {
+			return result, err
+		}
+
+		delay := throttled.RetryAfter
+		if delay <= 0 {
+			delay = policy.backoff(attempt)
+		}
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << attempt
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	// full jitter keeps concurrent workers from retrying in lockstep
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
@@ -0,0 +1,186 @@
+// Package crypt encrypts export files as ASCII armored age files, either for age
+// recipients or with a passphrase-derived scrypt key.
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"golang.org/x/term"
+)
+
+// PassphraseEnv is read for the passphrase before prompting on the terminal
+const PassphraseEnv = "HAZYCTL_PASSPHRASE"
+
+// Options selects how a file is encrypted
+type Options struct {
+	// Recipients are age public keys, a passphrase is used when there are none
+	Recipients []string
+	// RecipientsFiles hold one age public key per line
+	RecipientsFiles []string
+}
+
+func (o Options) recipients() ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	for _, value := range o.Recipients {
+		recipient, err := age.ParseX25519Recipient(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", value, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	for _, path := range o.RecipientsFiles {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open recipients file: %w", err)
+		}
+		parsed, err := age.ParseRecipients(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse recipients file %s: %w", path, err)
+		}
+		recipients = append(recipients, parsed...)
+	}
+	if len(recipients) > 0 {
+		return recipients, nil
+	}
+
+	passphrase, err := readPassphrase(true)
+	if err != nil {
+		return nil, err
+	}
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Recipient{recipient}, nil
+}
+
+type encryptWriter struct {
+	age, armor io.WriteCloser
+}
+
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	return w.age.Write(p)
+}
+
+func (w *encryptWriter) Close() error {
+	if err := w.age.Close(); err != nil {
+		return err
+	}
+	return w.armor.Close()
+}
+
+// Encrypt returns a writer that encrypts everything written to it into w.
+// The writer must be closed to flush the encrypted file.
+func Encrypt(w io.Writer, opts Options) (io.WriteCloser, error) {
+	recipients, err := opts.recipients()
+	if err != nil {
+		return nil, err
+	}
+	armored := armor.NewWriter(w)
+	encrypted, err := age.Encrypt(armored, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return &encryptWriter{age: encrypted, armor: armored}, nil
+}
+
+// IsEncrypted reports whether data is an armored or binary age file
+func IsEncrypted(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return bytes.HasPrefix(data, []byte(armor.Header)) || bytes.HasPrefix(data, []byte("age-encryption.org/"))
+}
+
+// Decrypt decrypts an age file with the identities in identityFiles, or with a passphrase when there are none
+func Decrypt(data []byte, identityFiles []string) ([]byte, error) {
+	var identities []age.Identity
+	for _, path := range identityFiles {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open identity file: %w", err)
+		}
+		parsed, err := age.ParseIdentities(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse identity file %s: %w", path, err)
+		}
+		identities = append(identities, parsed...)
+	}
+	if len(identities) == 0 {
+		passphrase, err := readPassphrase(false)
+		if err != nil {
+			return nil, err
+		}
+		identity, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+
+	var src io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte(armor.Header)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimLeft(data, " \t\r\n")))
+	}
+	decrypted, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	plaintext, err := io.ReadAll(decrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// ReadFile reads a file and decrypts it when it is an age file
+func ReadFile(path string, identityFiles []string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	return Decrypt(data, identityFiles)
+}
+
+// readPassphrase reads the passphrase from PassphraseEnv or prompts for it on the terminal,
+// asking twice when confirm is set
+func readPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no passphrase given, set %s or run in a terminal", PassphraseEnv)
+	}
+	fmt.Fprint(os.Stderr, "Passphrase: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	if strings.TrimSpace(string(passphrase)) == "" {
+		return "", errors.New("passphrase must not be empty")
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		if string(again) != string(passphrase) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return string(passphrase), nil
+}
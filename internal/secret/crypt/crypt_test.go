@@ -0,0 +1,99 @@
+package crypt
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+// encrypt encrypts plaintext with opts
+func encrypt(t *testing.T, plaintext string, opts Options) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := Encrypt(&out, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(plaintext)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identityFile := filepath.Join(dir, "key.txt")
+	recipientsFile := filepath.Join(dir, "recipients.txt")
+	if err := os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(recipientsFile, []byte("# backup key\n"+identity.Recipient().String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(PassphraseEnv, "correct horse")
+
+	tests := []struct {
+		name       string
+		opts       Options
+		identities []string
+	}{
+		{"recipient", Options{Recipients: []string{identity.Recipient().String()}}, []string{identityFile}},
+		{"recipients file", Options{RecipientsFiles: []string{recipientsFile}}, []string{identityFile}},
+		{"passphrase", Options{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encrypt(t, `{"db":"s3cret"}`, tt.opts)
+			if !IsEncrypted(data) || !strings.HasPrefix(string(data), "-----BEGIN AGE ENCRYPTED FILE-----") {
+				t.Fatalf("not an armored age file:\n%s", data)
+			}
+			path := filepath.Join(t.TempDir(), "secrets.json")
+			if err := os.WriteFile(path, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadFile(path, tt.identities)
+			if err != nil || string(got) != `{"db":"s3cret"}` {
+				t.Errorf("got %q, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestDecryptFailures(t *testing.T) {
+	t.Setenv(PassphraseEnv, "correct horse")
+	data := encrypt(t, "plaintext", Options{})
+
+	t.Setenv(PassphraseEnv, "wrong")
+	if _, err := Decrypt(data, nil); err == nil {
+		t.Error("decrypted with the wrong passphrase")
+	}
+	// without a passphrase or a terminal to prompt on
+	t.Setenv(PassphraseEnv, "")
+	if _, err := Decrypt(data, nil); err == nil || !strings.Contains(err.Error(), PassphraseEnv) {
+		t.Errorf("got %v, want the passphrase variable named", err)
+	}
+	if _, err := Encrypt(&bytes.Buffer{}, Options{Recipients: []string{"age1invalid"}}); err == nil {
+		t.Error("encrypted for an invalid recipient")
+	}
+}
+
+func TestReadFilePlaintext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if err := os.WriteFile(path, []byte(`[{"name":"db"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ReadFile(path, nil); err != nil || string(got) != `[{"name":"db"}]` {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,54 @@
+package crypt
+
+import (
+	"errors"
+
+	"github.com/spf13/pflag"
+)
+
+// AddEncryptFlags registers the flags choosing between encrypted and plaintext output
+func AddEncryptFlags(flags *pflag.FlagSet) {
+	flags.Bool("encrypt", false, "Encrypt the output file with age, for --recipient keys or a passphrase")
+	flags.StringArray("recipient", nil, "age public key to encrypt for (repeatable), implies --encrypt")
+	flags.StringArray("recipients-file", nil, "File with age public keys to encrypt for (repeatable), implies --encrypt")
+	flags.Bool("plaintext", false, "Write secret values unencrypted")
+}
+
+// EncryptFromFlags returns the encryption options, or nil for an explicitly requested plaintext file
+func EncryptFromFlags(flags *pflag.FlagSet) (*Options, error) {
+	encrypt, err := flags.GetBool("encrypt")
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := flags.GetBool("plaintext")
+	if err != nil {
+		return nil, err
+	}
+	var opts Options
+	if opts.Recipients, err = flags.GetStringArray("recipient"); err != nil {
+		return nil, err
+	}
+	if opts.RecipientsFiles, err = flags.GetStringArray("recipients-file"); err != nil {
+		return nil, err
+	}
+	encrypt = encrypt || len(opts.Recipients) > 0 || len(opts.RecipientsFiles) > 0
+	switch {
+	case encrypt && plaintext:
+		return nil, errors.New("--plaintext cannot be combined with encryption")
+	case plaintext:
+		return nil, nil
+	case !encrypt:
+		return nil, errors.New("refusing to write secret values unencrypted, pass --encrypt, --recipient or --plaintext")
+	}
+	return &opts, nil
+}
+
+// AddDecryptFlags registers the flags used to decrypt encrypted input files
+func AddDecryptFlags(flags *pflag.FlagSet) {
+	flags.StringArray("identity", nil, "age identity file to decrypt with (repeatable), the passphrase is used when none is given")
+}
+
+// IdentitiesFromFlags reads the identity files registered by AddDecryptFlags
+func IdentitiesFromFlags(flags *pflag.FlagSet) ([]string, error) {
+	return flags.GetStringArray("identity")
+}
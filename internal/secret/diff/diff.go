@@ -0,0 +1,248 @@
+package diff
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+)
+
+// Change is how a secret differs between two stores
+type Change string
+
+const (
+	ChangeAdded           Change = "added"
+	ChangeRemoved         Change = "removed"
+	ChangeValueChanged    Change = "value-changed"
+	ChangeMetadataChanged Change = "metadata-changed"
+)
+
+// valueUnavailable stands in for the hash of a value that cannot be read, e.g. of a disabled secret
+const valueUnavailable = "unavailable"
+
+// Entry is a secret that differs between the two stores. Values are only reported as hashes.
+type Entry struct {
+	Name   string `json:"name"`
+	Change Change `json:"change"`
+	// Metadata lists the metadata fields that differ
+	Metadata []string `json:"metadata,omitempty"`
+	HashA    string   `json:"hashA,omitempty"`
+	HashB    string   `json:"hashB,omitempty"`
+}
+
+// Result is the difference from store A to store B
+type Result struct {
+	A         string   `json:"a"`
+	B         string   `json:"b"`
+	Entries   []*Entry `json:"entries"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// Options controls how stores are compared
+type Options struct {
+	Concurrency int
+	Retry       batch.RetryPolicy
+	// Selector picks the secrets to compare by name and tags, nil compares all of them
+	Selector *filter.Selector
+}
+
+// Compare reads the current version of every secret in both stores and reports the differences, ordered by name
+func Compare(ctx context.Context, a, b migrate.Endpoint, opts Options) (*Result, error) {
+	propsA, err := list(ctx, a, opts)
+	if err != nil {
+		return nil, err
+	}
+	propsB, err := list(ctx, b, opts)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(propsA)+len(propsB))
+	for name := range propsA {
+		names = append(names, name)
+	}
+	for name := range propsB {
+		if _, ok := propsA[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	entries := make([]*Entry, len(names))
+	errs := make([]error, len(names))
+	batch.ForEach(ctx, len(names), opts.Concurrency, func(ctx context.Context, i int) {
+		name := names[i]
+		pa, inA := propsA[name]
+		pb, inB := propsB[name]
+		switch {
+		case !inB:
+			entries[i] = &Entry{Name: name, Change: ChangeRemoved}
+			return
+		case !inA:
+			entries[i] = &Entry{Name: name, Change: ChangeAdded}
+			return
+		}
+		valueA, err := readValue(ctx, a, name, opts)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		valueB, err := readValue(ctx, b, name, opts)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		entry := &Entry{Name: name, Metadata: metadataChanges(pa, pb)}
+		switch {
+		case valueA.digest != valueB.digest:
+			entry.Change, entry.HashA, entry.HashB = ChangeValueChanged, valueA.hash, valueB.hash
+		case len(entry.Metadata) > 0:
+			entry.Change = ChangeMetadataChanged
+		default:
+			return
+		}
+		entries[i] = entry
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	result := &Result{A: a.Store, B: b.Store, Entries: []*Entry{}}
+	for _, entry := range entries {
+		if entry == nil {
+			result.Unchanged++
+			continue
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+	return result, nil
+}
+
+func list(ctx context.Context, ep migrate.Endpoint, opts Options) (map[string]providers.SecretProperties, error) {
+	items, err := batch.Retry(ctx, opts.Retry, func() ([]providers.SecretProperties, error) {
+		return ep.Provider.ListSecrets(ctx, ep.Store)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets of %s: %w", ep.Store, err)
+	}
+	props := make(map[string]providers.SecretProperties, len(items))
+	for _, item := range items {
+		if opts.Selector.Match(item) {
+			props[item.Name] = item
+		}
+	}
+	return props, nil
+}
+
+// value is how a secret value is compared and reported: by its full sha256 digest, since the short
+// hash printed for it can collide, and by the keyed hash of redact.Hash
+type value struct {
+	digest [sha256.Size]byte
+	hash   string
+}
+
+// readValue reads the current value of a secret, a value that cannot be read compares as unavailable
+func readValue(ctx context.Context, ep migrate.Endpoint, name string, opts Options) (value, error) {
+	secret, err := batch.Retry(ctx, opts.Retry, func() (*providers.Secret, error) {
+		return ep.Provider.GetSecret(ctx, ep.Store, name, "")
+	})
+	switch {
+	case errors.Is(err, providers.ErrDisabled):
+		return value{hash: valueUnavailable}, nil
+	case err != nil:
+		return value{}, fmt.Errorf("failed to read %s from %s: %w", name, ep.Store, err)
+	}
+	redact.Register(secret.Value)
+	return value{digest: sha256.Sum256([]byte(secret.Value)), hash: redact.Hash(secret.Value)}, nil
+}
+
+// metadataChanges lists the metadata fields that differ between a and b
+func metadataChanges(a, b providers.SecretProperties) []string {
+	var changes []string
+	if a.ContentType != b.ContentType {
+		changes = append(changes, "content type")
+	}
+	if a.Enabled != b.Enabled {
+		changes = append(changes, "enabled")
+	}
+	if !sameTime(a.Expires, b.Expires) {
+		changes = append(changes, "expires")
+	}
+	if !sameTime(a.NotBefore, b.NotBefore) {
+		changes = append(changes, "not before")
+	}
+	var tags []string
+	for k, v := range a.Tags {
+		if bv, ok := b.Tags[k]; !ok || bv != v {
+			tags = append(tags, k)
+		}
+	}
+	for k := range b.Tags {
+		if _, ok := a.Tags[k]; !ok {
+			tags = append(tags, k)
+		}
+	}
+	if len(tags) > 0 {
+		sort.Strings(tags)
+		changes = append(changes, "tags "+strings.Join(tags, ","))
+	}
+	return changes
+}
+
+// Write prints the result as a unified text view or as json
+func (r *Result) Write(w io.Writer, format string) error {
+	switch format {
+	case "", "text":
+		r.writeText(w)
+		return nil
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	default:
+		return fmt.Errorf("unknown diff format %q, expected text or json", format)
+	}
+}
+
+func (r *Result) writeText(w io.Writer) {
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", r.A, r.B)
+	counts := make(map[Change]int)
+	for _, entry := range r.Entries {
+		counts[entry.Change]++
+		switch entry.Change {
+		case ChangeAdded:
+			fmt.Fprintf(w, "+ %s\n", entry.Name)
+		case ChangeRemoved:
+			fmt.Fprintf(w, "- %s\n", entry.Name)
+		case ChangeValueChanged:
+			fmt.Fprintf(w, "~ %s: value %s -> %s\n", entry.Name, entry.HashA, entry.HashB)
+		case ChangeMetadataChanged:
+			fmt.Fprintf(w, "~ %s: metadata\n", entry.Name)
+		}
+		if len(entry.Metadata) > 0 {
+			fmt.Fprintf(w, "    metadata: %s\n", strings.Join(entry.Metadata, "; "))
+		}
+	}
+	fmt.Fprintf(w, "%d added, %d removed, %d value changed, %d metadata changed, %d unchanged\n",
+		counts[ChangeAdded], counts[ChangeRemoved], counts[ChangeValueChanged], counts[ChangeMetadataChanged], r.Unchanged)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,72 @@
+package diff
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/providers/providertest"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+)
+
+func TestCompare(t *testing.T) {
+	p := providertest.NewMemory()
+	secret := providertest.NewSecret
+	for _, s := range []providers.Secret{
+		secret("same", "v1", map[string]string{"team": "a"}),
+		secret("value", "old", nil),
+		secret("tags", "v1", map[string]string{"team": "a"}),
+		secret("removed", "v1", nil),
+	} {
+		providertest.Put(t, p, "a", s)
+	}
+	for _, s := range []providers.Secret{
+		secret("same", "v1", map[string]string{"team": "a"}),
+		secret("value", "new", nil),
+		secret("tags", "v1", map[string]string{"team": "b"}),
+		secret("added", "v1", nil),
+	} {
+		providertest.Put(t, p, "b", s)
+	}
+
+	result, err := Compare(context.Background(), migrate.Endpoint{Provider: p, Store: "a"}, migrate.Endpoint{Provider: p, Store: "b"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Entry{
+		{Name: "added", Change: ChangeAdded},
+		{Name: "removed", Change: ChangeRemoved},
+		{Name: "tags", Change: ChangeMetadataChanged, Metadata: []string{"tags team"}},
+		{Name: "value", Change: ChangeValueChanged, HashA: redact.Hash("old"), HashB: redact.Hash("new")},
+	}
+	if !reflect.DeepEqual(result.Entries, want) || result.Unchanged != 1 {
+		t.Errorf("got %+v with %d unchanged", result.Entries, result.Unchanged)
+	}
+
+	var out strings.Builder
+	if err := result.Write(&out, "text"); err != nil {
+		t.Fatal(err)
+	}
+	if text := out.String(); strings.Contains(text, "old") || strings.Contains(text, "new") {
+		t.Errorf("values printed in\n%s", text)
+	}
+}
+
+func TestCompareDisabled(t *testing.T) {
+	p := providertest.NewMemory()
+	for _, store := range []string{"a", "b"} {
+		s := providertest.NewSecret("db", "v1", nil)
+		s.Enabled = store == "a"
+		providertest.Put(t, p, store, s)
+	}
+	result, err := Compare(context.Background(), migrate.Endpoint{Provider: p, Store: "a"}, migrate.Endpoint{Provider: p, Store: "b"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 1 || result.Entries[0].HashB != valueUnavailable {
+		t.Errorf("got %+v, want the disabled value reported as unavailable", result.Entries)
+	}
+}
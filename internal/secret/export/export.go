@@ -0,0 +1,253 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+)
+
+// Attributes holds the lifecycle attributes of an exported secret
+type Attributes struct {
+	Enabled   *bool      `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Expires   *time.Time `json:"exp,omitempty" yaml:"exp,omitempty"`
+	NotBefore *time.Time `json:"nbf,omitempty" yaml:"nbf,omitempty"`
+	Created   *time.Time `json:"created,omitempty" yaml:"created,omitempty"`
+	Updated   *time.Time `json:"updated,omitempty" yaml:"updated,omitempty"`
+}
+
+// attributesJSON is the JSON encoding of Attributes, times are Unix seconds like in the export
+// files of the Key Vault SDK
+type attributesJSON struct {
+	Enabled   *bool           `json:"enabled,omitempty"`
+	Expires   json.RawMessage `json:"exp,omitempty"`
+	NotBefore json.RawMessage `json:"nbf,omitempty"`
+	Created   json.RawMessage `json:"created,omitempty"`
+	Updated   json.RawMessage `json:"updated,omitempty"`
+}
+
+// MarshalJSON writes times as Unix seconds so export files stay readable by older versions
+func (a Attributes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(attributesJSON{
+		Enabled:   a.Enabled,
+		Expires:   unixTime(a.Expires),
+		NotBefore: unixTime(a.NotBefore),
+		Created:   unixTime(a.Created),
+		Updated:   unixTime(a.Updated),
+	})
+}
+
+// UnmarshalJSON reads times as Unix seconds or RFC3339 strings
+func (a *Attributes) UnmarshalJSON(data []byte) error {
+	var raw attributesJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*a = Attributes{Enabled: raw.Enabled}
+	for _, field := range []struct {
+		name string
+		raw  json.RawMessage
+		dst  **time.Time
+	}{
+		{"exp", raw.Expires, &a.Expires},
+		{"nbf", raw.NotBefore, &a.NotBefore},
+		{"created", raw.Created, &a.Created},
+		{"updated", raw.Updated, &a.Updated},
+	} {
+		t, err := parseTime(field.raw)
+		if err != nil {
+			return fmt.Errorf("invalid attribute %s: %w", field.name, err)
+		}
+		*field.dst = t
+	}
+	return nil
+}
+
+func unixTime(t *time.Time) json.RawMessage {
+	if t == nil {
+		return nil
+	}
+	return json.RawMessage(strconv.FormatInt(t.Unix(), 10))
+}
+
+func parseTime(raw json.RawMessage) (*time.Time, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if raw[0] == '"' {
+		var t time.Time
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return nil, err
+		}
+		return &t, nil
+	}
+	seconds, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("expected Unix seconds or an RFC3339 time, got %s", raw)
+	}
+	t := time.Unix(seconds, 0).UTC()
+	return &t, nil
+}
+
+// ExportSecret is a single secret as written to an export file
+type ExportSecret struct {
+	Name        string            `yaml:"name"`
+	Value       string            `yaml:"value"`
+	ContentType string            `yaml:"contentType,omitempty"`
+	Attributes  *Attributes       `yaml:"attributes,omitempty"`
+	Tags        map[string]string `yaml:"tags,omitempty"`
+	ID          string            `yaml:"id,omitempty"`
+	Version     string            `yaml:"version,omitempty"`
+	VaultURL    string            `yaml:"vaultUrl,omitempty"`
+}
+
+// FromSecret converts a provider secret read from store into an ExportSecret
+func FromSecret(store string, secret providers.Secret) ExportSecret {
+	redact.Register(secret.Value)
+	enabled := secret.Enabled
+	return ExportSecret{
+		Name:        secret.Name,
+		Value:       secret.Value,
+		ContentType: secret.ContentType,
+		Attributes: &Attributes{
+			Enabled:   &enabled,
+			Expires:   secret.Expires,
+			NotBefore: secret.NotBefore,
+			Created:   secret.Created,
+			Updated:   secret.Updated,
+		},
+		Tags:     secret.Tags,
+		ID:       secret.Name,
+		Version:  secret.Version,
+		VaultURL: store,
+	}
+}
+
+// Secret converts an ExportSecret back into a provider secret
+func (e ExportSecret) Secret() providers.Secret {
+	secret := providers.Secret{
+		SecretProperties: providers.SecretProperties{
+			Name:        e.Name,
+			Version:     e.Version,
+			ContentType: e.ContentType,
+			Tags:        e.Tags,
+			Enabled:     true,
+		},
+		Value: e.Value,
+	}
+	if e.Attributes != nil {
+		if e.Attributes.Enabled != nil {
+			secret.Enabled = *e.Attributes.Enabled
+		}
+		secret.Expires = e.Attributes.Expires
+		secret.NotBefore = e.Attributes.NotBefore
+		secret.Created = e.Attributes.Created
+		secret.Updated = e.Attributes.Updated
+	}
+	return secret
+}
+
+// Options controls how secrets are read during an export
+type Options struct {
+	// Concurrency is the number of secrets read in parallel
+	Concurrency int
+	// Retry controls how throttled provider calls are retried
+	Retry batch.RetryPolicy
+	// States decides how disabled and expired secrets are handled
+	States filter.StateRules
+	// SkipManaged leaves out secrets backing a certificate, which are exported with their certificate instead
+	SkipManaged bool
+}
+
+// Result holds the exported secrets and how many secrets fell into each state
+type Result struct {
+	Secrets []ExportSecret
+	States  map[filter.State]int
+	Skipped int
+}
+
+// Summary describes the states of the exported secrets in one line
+func (r *Result) Summary() string {
+	var parts []string
+	for _, state := range []filter.State{filter.StateActive, filter.StateDisabled, filter.StateExpired} {
+		if n := r.States[state]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, state))
+		}
+	}
+	return fmt.Sprintf("%d secrets exported, %d skipped (%s)", len(r.Secrets), r.Skipped, strings.Join(parts, ", "))
+}
+
+// Export reads the current value of every secret in store, ordered by name.
+// Secrets that cannot be read are left out and reported together in the returned error.
+func Export(ctx context.Context, provider providers.SecretProvider, store string, opts Options) (*Result, error) {
+	items, err := batch.Retry(ctx, opts.Retry, func() ([]providers.SecretProperties, error) {
+		return provider.ListSecrets(ctx, store)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+
+	now := time.Now()
+	secrets := make([]*providers.Secret, len(items))
+	errs := make([]error, len(items))
+	batch.ForEach(ctx, len(items), opts.Concurrency, func(ctx context.Context, i int) {
+		if opts.SkipManaged && items[i].Managed {
+			return
+		}
+		state := filter.StateOf(items[i], now)
+		switch opts.States.For(state) {
+		case filter.StateSkip:
+			return
+		case filter.StateFail:
+			errs[i] = fmt.Errorf("secret %s is %s", items[i].Name, state)
+			return
+		case filter.StateMetadataOnly:
+			// tagged like migrate does so imports do not write the empty value over a real one
+			secrets[i] = &providers.Secret{SecretProperties: items[i]}
+			secrets[i].Tags = make(map[string]string, len(items[i].Tags)+1)
+			for k, v := range items[i].Tags {
+				secrets[i].Tags[k] = v
+			}
+			secrets[i].Tags[migrate.TagValueUnavailable] = "true"
+			return
+		}
+		secrets[i], errs[i] = batch.Retry(ctx, opts.Retry, func() (*providers.Secret, error) {
+			return provider.GetSecret(ctx, store, items[i].Name, "")
+		})
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("failed to get secret value for %s: %w", items[i].Name, errs[i])
+		}
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &Result{States: map[filter.State]int{}}
+	var failed []error
+	for i, secret := range secrets {
+		result.States[filter.StateOf(items[i], now)]++
+		switch {
+		case errs[i] != nil:
+			failed = append(failed, errs[i])
+		case secret == nil:
+			result.Skipped++
+		default:
+			result.Secrets = append(result.Secrets, FromSecret(store, *secret))
+		}
+	}
+	if len(failed) > 0 {
+		return result, fmt.Errorf("%d of %d secrets failed to export:\n%w", len(failed), len(items), errors.Join(failed...))
+	}
+	return result, nil
+}
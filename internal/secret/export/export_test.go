@@ -0,0 +1,223 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+func TestAttributesJSON(t *testing.T) {
+	want := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for name, data := range map[string]string{
+		"unix":    `{"enabled":false,"exp":1740830400,"nbf":1740830400,"created":1740830400,"updated":1740830400,"recoverableDays":90}`,
+		"rfc3339": `{"enabled":false,"exp":"2025-03-01T12:00:00Z","nbf":"2025-03-01T12:00:00Z","created":"2025-03-01T12:00:00Z","updated":"2025-03-01T12:00:00Z"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var a Attributes
+			if err := json.Unmarshal([]byte(data), &a); err != nil {
+				t.Fatal(err)
+			}
+			if a.Enabled == nil || *a.Enabled {
+				t.Errorf("enabled = %v, want false", a.Enabled)
+			}
+			for field, got := range map[string]*time.Time{"exp": a.Expires, "nbf": a.NotBefore, "created": a.Created, "updated": a.Updated} {
+				if got == nil || !got.Equal(want) {
+					t.Errorf("%s = %v, want %v", field, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAttributesJSONWritesUnixSeconds(t *testing.T) {
+	exp := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	enabled := true
+	data, err := json.Marshal(Attributes{Enabled: &enabled, Expires: &exp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"enabled":true,"exp":1740830400}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestAttributesJSONInvalidTime(t *testing.T) {
+	var a Attributes
+	err := json.Unmarshal([]byte(`{"exp":true}`), &a)
+	if err == nil || !strings.Contains(err.Error(), "exp") {
+		t.Errorf("got %v, want an error naming exp", err)
+	}
+}
+
+// staticProvider serves fixed secrets, every other method is unused by Export
+type staticProvider struct {
+	providers.SecretProvider
+	secrets []providers.Secret
+}
+
+func (p staticProvider) ListSecrets(ctx context.Context, store string) ([]providers.SecretProperties, error) {
+	var items []providers.SecretProperties
+	for _, secret := range p.secrets {
+		items = append(items, secret.SecretProperties)
+	}
+	return items, nil
+}
+
+func (p staticProvider) GetSecret(ctx context.Context, store, name, version string) (*providers.Secret, error) {
+	for _, secret := range p.secrets {
+		if secret.Name == name {
+			return &secret, nil
+		}
+	}
+	return nil, providers.ErrNotFound
+}
+
+func TestExportMetadataOnlyIsTagged(t *testing.T) {
+	p := staticProvider{secrets: []providers.Secret{
+		{SecretProperties: providers.SecretProperties{Name: "off", Tags: map[string]string{"team": "a"}}, Value: "hidden"},
+		{SecretProperties: providers.SecretProperties{Name: "on", Enabled: true}, Value: "v"},
+	}}
+	result, err := Export(context.Background(), p, "vault", Options{Concurrency: 1, States: filter.StateRules{Disabled: filter.StateMetadataOnly}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Secrets) != 2 {
+		t.Fatalf("got %d secrets, want 2", len(result.Secrets))
+	}
+	off, on := result.Secrets[0], result.Secrets[1]
+	if off.Value != "" || off.Tags[migrate.TagValueUnavailable] != "true" || off.Tags["team"] != "a" {
+		t.Errorf("metadata only secret = %+v, want an empty value tagged %s", off, migrate.TagValueUnavailable)
+	}
+	if _, ok := on.Tags[migrate.TagValueUnavailable]; ok || on.Value != "v" {
+		t.Errorf("enabled secret = %+v, want its value and no tag", on)
+	}
+	if _, ok := p.secrets[0].Tags[migrate.TagValueUnavailable]; ok {
+		t.Error("the tags of the listed secret were modified")
+	}
+}
+
+// TestReadBaselineExport reads a file written by the first export command, which encoded the Key Vault
+// attributes as is, and plans its import
+func TestReadBaselineExport(t *testing.T) {
+	secrets, err := ReadFile("testdata/baseline.json", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := NewFileProvider(secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	got, err := src.GetSecret(ctx, "", "db-password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "s3cret" || got.ContentType != "text/plain" || !got.Enabled || got.Tags["owner"] != "team-a" {
+		t.Errorf("db-password = %+v", got)
+	}
+	if want := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC); got.Expires == nil || !got.Expires.Equal(want) {
+		t.Errorf("db-password expires = %v, want %v", got.Expires, want)
+	}
+	got, err = src.GetSecret(ctx, "", "api-key", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "k3y" || got.ContentType != "" || got.Enabled || got.Tags != nil || got.NotBefore == nil {
+		t.Errorf("api-key = %+v", got)
+	}
+
+	dst, err := NewFileProvider(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := migrate.BuildPlan(ctx, migrate.Endpoint{Provider: src, Store: "file"}, migrate.Endpoint{Provider: dst, Store: "vault"}, migrate.Options{Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := make(map[string]migrate.Action)
+	for _, item := range plan.Items {
+		actions[item.Name] = item.Action
+	}
+	if actions["db-password"] != migrate.ActionCreate {
+		t.Errorf("db-password planned as %q, want create", actions["db-password"])
+	}
+}
+
+func TestEncodeK8sSecretKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		style   NameStyle
+		wantErr bool
+	}{
+		{"valid key", "db.password-1", "", false},
+		{"invalid key", "db/password", "", true},
+		{"normalized", "db/password", NameUpperSnake, false},
+		{"dot", ".", "", true},
+		{"too long", strings.Repeat("a", 254), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Encode(&out, "k8s-secret", []ExportSecret{{Name: tt.secret, Value: "v"}}, FormatOptions{NameStyle: tt.style})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), tt.secret) {
+				t.Errorf("error %q does not name the key", err)
+			}
+		})
+	}
+}
+
+func TestCSVTagsRoundTrip(t *testing.T) {
+	tags := map[string]string{"a;b": "c=d", "team": "x,y", "quote": `"`}
+	var out bytes.Buffer
+	if err := Encode(&out, "csv", []ExportSecret{{Name: "db", Value: "v", Tags: tags}}, FormatOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	secrets, err := readCSV(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 || !reflect.DeepEqual(secrets[0].Tags, tags) {
+		t.Errorf("read back %+v, want tags %v", secrets, tags)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	var k8s, list bytes.Buffer
+	secrets := []ExportSecret{{Name: "db", Value: "v"}}
+	if err := Encode(&k8s, "k8s-secret", secrets, FormatOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Encode(&list, "yaml", secrets, FormatOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		data []byte
+		want string
+	}{
+		{"secrets.yaml", k8s.Bytes(), "k8s-secret"},
+		{"secrets.yml", k8s.Bytes(), "k8s-secret"},
+		{"secrets.yaml", list.Bytes(), "yaml"},
+		{"secrets.csv", nil, "csv"},
+		{"secrets.env", nil, "dotenv"},
+		{"secrets", nil, "json"},
+	}
+	for _, tt := range tests {
+		if got := DetectFormat(tt.path, tt.data); got != tt.want {
+			t.Errorf("DetectFormat(%s) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
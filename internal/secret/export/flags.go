@@ -0,0 +1,39 @@
+package export
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// AddFormatFlags registers the output format flags
+func AddFormatFlags(flags *pflag.FlagSet) {
+	flags.String("format", "json", "Output format: json, yaml, dotenv, k8s-secret, tfvars or csv")
+	flags.String("name-style", "", "How secret names become keys: keep, upper-snake or lower-snake (default depends on the format)")
+	flags.String("k8s-name", "secrets", "Name of the Secret written with --format k8s-secret")
+	flags.String("k8s-namespace", "", "Namespace of the Secret written with --format k8s-secret")
+}
+
+// FormatFromFlags reads the format name and options registered by AddFormatFlags
+func FormatFromFlags(flags *pflag.FlagSet) (string, FormatOptions, error) {
+	var opts FormatOptions
+	format, err := flags.GetString("format")
+	if err != nil {
+		return "", opts, err
+	}
+	if _, err := GetFormat(format); err != nil {
+		return "", opts, err
+	}
+	style, err := flags.GetString("name-style")
+	if err != nil {
+		return "", opts, err
+	}
+	if opts.NameStyle, err = ParseNameStyle(style); err != nil {
+		return "", opts, err
+	}
+	if opts.SecretName, err = flags.GetString("k8s-name"); err != nil {
+		return "", opts, err
+	}
+	if opts.Namespace, err = flags.GetString("k8s-namespace"); err != nil {
+		return "", opts, err
+	}
+	return format, opts, nil
+}
@@ -0,0 +1,201 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hazyforge/hazyctl/internal/secret/crypt"
+	"github.com/hazyforge/hazyctl/pkg/utils"
+)
+
+// NameStyle decides how secret names are turned into the keys of a format
+type NameStyle string
+
+const (
+	// NameKeep leaves names untouched
+	NameKeep NameStyle = "keep"
+	// NameUpperSnake turns db-password into DB_PASSWORD, as expected for environment variables
+	NameUpperSnake NameStyle = "upper-snake"
+	// NameLowerSnake turns db-password into db_password, as expected for Terraform identifiers
+	NameLowerSnake NameStyle = "lower-snake"
+)
+
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// Normalize converts a secret name into a key of this style
+func (s NameStyle) Normalize(name string) string {
+	switch s {
+	case NameUpperSnake, NameLowerSnake:
+		key := strings.Trim(nonIdentifier.ReplaceAllString(name, "_"), "_")
+		if key != "" && key[0] >= '0' && key[0] <= '9' {
+			key = "_" + key
+		}
+		if s == NameUpperSnake {
+			return strings.ToUpper(key)
+		}
+		return strings.ToLower(key)
+	default:
+		return name
+	}
+}
+
+// ParseNameStyle validates a name style, an empty value selects the default of the format
+func ParseNameStyle(value string) (NameStyle, error) {
+	switch style := NameStyle(value); style {
+	case "", NameKeep, NameUpperSnake, NameLowerSnake:
+		return style, nil
+	default:
+		return "", fmt.Errorf("unknown name style %q, expected keep, upper-snake or lower-snake", value)
+	}
+}
+
+// FormatOptions controls how secrets are written
+type FormatOptions struct {
+	// NameStyle overrides the default name style of the format
+	NameStyle NameStyle
+	// SecretName and Namespace name the manifest written by the k8s-secret format
+	SecretName string
+	Namespace  string
+}
+
+// Format is an export file format
+type Format struct {
+	// NameStyle is the name style used unless FormatOptions.NameStyle is set
+	NameStyle NameStyle
+	// Write encodes secrets whose names are already normalized
+	Write func(w io.Writer, secrets []ExportSecret, opts FormatOptions) error
+	// Read decodes a file written by Write
+	Read func(data []byte) ([]ExportSecret, error)
+	// Extensions are the file extensions the format is detected by
+	Extensions []string
+	// Sniff reports whether data is in the format, it tells apart formats sharing an extension
+	Sniff func(data []byte) bool
+}
+
+var formats = make(map[string]Format)
+
+// RegisterFormat adds a format to the registry
+func RegisterFormat(name string, format Format) {
+	formats[name] = format
+}
+
+// GetFormat returns a format by name
+func GetFormat(name string) (Format, error) {
+	format, ok := formats[name]
+	if !ok {
+		return Format{}, fmt.Errorf("format %s not found, available formats: %v", name, FormatNames())
+	}
+	return format, nil
+}
+
+// FormatNames returns the sorted names of all registered formats
+func FormatNames() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Encode normalizes the secret names and writes them to w in the named format.
+// It fails when two secrets normalize to the same key.
+func Encode(w io.Writer, name string, secrets []ExportSecret, opts FormatOptions) error {
+	format, err := GetFormat(name)
+	if err != nil {
+		return err
+	}
+	style := opts.NameStyle
+	if style == "" {
+		style = format.NameStyle
+	}
+	normalized := make([]ExportSecret, len(secrets))
+	keys := make(map[string]string, len(secrets))
+	for i, secret := range secrets {
+		key := style.Normalize(secret.Name)
+		if other, ok := keys[key]; ok {
+			return fmt.Errorf("secrets %s and %s both map to %s in the %s format", other, secret.Name, key, name)
+		}
+		keys[key] = secret.Name
+		secret.Name = key
+		normalized[i] = secret
+	}
+	return format.Write(w, normalized, opts)
+}
+
+// DetectFormat returns the format of a file from the extension of path, json when there is none.
+// Of the formats registered for the extension, one whose Sniff accepts data wins.
+func DetectFormat(path string, data []byte) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	detected := "json"
+	for _, name := range FormatNames() {
+		format := formats[name]
+		if !slices.Contains(format.Extensions, ext) {
+			continue
+		}
+		switch {
+		case format.Sniff == nil:
+			detected = name
+		case format.Sniff(data):
+			return name
+		}
+	}
+	return detected
+}
+
+// ReadFile reads the secrets of an export file, decrypting it with identityFiles when it is encrypted.
+// An empty format is detected from the file extension and content.
+func ReadFile(path, name string, identityFiles []string) ([]ExportSecret, error) {
+	if name != "" {
+		if _, err := GetFormat(name); err != nil {
+			return nil, err
+		}
+	}
+	data, err := crypt.ReadFile(path, identityFiles)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = DetectFormat(path, data)
+	}
+	format, err := GetFormat(name)
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := format.Read(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s as %s: %w", path, name, err)
+	}
+	return secrets, nil
+}
+
+// WriteFile writes secrets to path in the named format, encrypted unless encryption is nil.
+// The file is only readable by its owner. It is encoded and encrypted in memory first, so a
+// failed passphrase prompt or encoding error leaves an existing file untouched.
+func WriteFile(path, name string, secrets []ExportSecret, opts FormatOptions, encryption *crypt.Options) error {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var encrypted io.WriteCloser
+	if encryption != nil {
+		var err error
+		if encrypted, err = crypt.Encrypt(&buf, *encryption); err != nil {
+			return err
+		}
+		w = encrypted
+	}
+	if err := Encode(w, name, secrets, opts); err != nil {
+		return fmt.Errorf("failed to encode secrets as %s: %w", name, err)
+	}
+	if encrypted != nil {
+		if err := encrypted.Close(); err != nil {
+			return fmt.Errorf("failed to encrypt file: %w", err)
+		}
+	}
+	return utils.WritePrivateFile(path, buf.Bytes())
+}
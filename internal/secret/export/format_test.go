@@ -0,0 +1,74 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/hazyforge/hazyctl/internal/secret/crypt"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.json")
+	secrets := []ExportSecret{{Name: "db", Value: "s3cret"}}
+
+	// an existing file readable by others is replaced by a private one
+	if err := os.WriteFile(path, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, "json", secrets, FormatOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("mode %v, want 0600", mode)
+	}
+	got, err := ReadFile(path, "json", nil)
+	if err != nil || len(got) != 1 || got[0].Value != "s3cret" {
+		t.Errorf("read back %+v, %v", got, err)
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identityFile := filepath.Join(dir, "key.txt")
+	if err := os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	encryption := &crypt.Options{Recipients: []string{identity.Recipient().String()}}
+	if err := WriteFile(path, "json", secrets, FormatOptions{}, encryption); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ReadFile(path, "json", []string{identityFile}); err != nil || len(got) != 1 || got[0].Value != "s3cret" {
+		t.Errorf("decrypted %+v, %v", got, err)
+	}
+}
+
+func TestWriteFileFailureKeepsExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.json")
+	if err := os.WriteFile(path, []byte("previous"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// without a passphrase or a terminal to prompt on, encryption fails before anything is written
+	t.Setenv(crypt.PassphraseEnv, "")
+	if err := WriteFile(path, "json", []ExportSecret{{Name: "db", Value: "v"}}, FormatOptions{}, &crypt.Options{}); err == nil {
+		t.Fatal("encrypting without a passphrase succeeded")
+	}
+	if err := WriteFile(path, "unknown", nil, FormatOptions{}, nil); err == nil {
+		t.Fatal("an unknown format was written")
+	}
+
+	if data, err := os.ReadFile(path); err != nil || string(data) != "previous" {
+		t.Errorf("file now holds %q, %v, want it untouched", data, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("left %d files behind", len(entries))
+	}
+}
@@ -0,0 +1,167 @@
+package export
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	RegisterFormat("json", Format{NameStyle: NameKeep, Write: writeJSON, Read: readJSON, Extensions: []string{".json"}})
+	RegisterFormat("yaml", Format{NameStyle: NameKeep, Write: writeYAML, Read: readYAML, Extensions: []string{".yaml", ".yml"}})
+	RegisterFormat("dotenv", Format{NameStyle: NameUpperSnake, Write: writeDotenv, Read: readDotenv, Extensions: []string{".env"}})
+	RegisterFormat("k8s-secret", Format{NameStyle: NameKeep, Write: writeK8sSecret, Read: readK8sSecret, Extensions: []string{".yaml", ".yml"}, Sniff: sniffK8sSecret})
+	RegisterFormat("tfvars", Format{NameStyle: NameLowerSnake, Write: writeTfvars, Read: readTfvars, Extensions: []string{".tfvars"}})
+	RegisterFormat("csv", Format{NameStyle: NameKeep, Write: writeCSV, Read: readCSV, Extensions: []string{".csv"}})
+}
+
+func writeJSON(w io.Writer, secrets []ExportSecret, _ FormatOptions) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(secrets)
+}
+
+func writeYAML(w io.Writer, secrets []ExportSecret, _ FormatOptions) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(secrets); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// dotenvEscaper escapes values for double quoted dotenv values, multiline values become \n sequences
+var dotenvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`)
+
+func writeDotenv(w io.Writer, secrets []ExportSecret, _ FormatOptions) error {
+	buf := bufio.NewWriter(w)
+	for _, secret := range secrets {
+		fmt.Fprintf(buf, "%s=\"%s\"\n", secret.Name, dotenvEscaper.Replace(secret.Value))
+	}
+	return buf.Flush()
+}
+
+type k8sMetadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+type k8sSecret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data"`
+}
+
+func writeK8sSecret(w io.Writer, secrets []ExportSecret, opts FormatOptions) error {
+	manifest := k8sSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   k8sMetadata{Name: opts.SecretName, Namespace: opts.Namespace},
+		Type:       "Opaque",
+		Data:       make(map[string]string, len(secrets)),
+	}
+	if manifest.Metadata.Name == "" {
+		manifest.Metadata.Name = "secrets"
+	}
+	for _, secret := range secrets {
+		// keys are written as they are, Kubernetes would reject the whole manifest for one invalid key
+		if err := validateK8sKey(secret.Name); err != nil {
+			return fmt.Errorf("%s is not a valid Secret key, choose another --name-style: %w", secret.Name, err)
+		}
+		manifest.Data[secret.Name] = base64.StdEncoding.EncodeToString([]byte(secret.Value))
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(manifest); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// k8sKeyPattern matches the characters Kubernetes allows in Secret and ConfigMap keys
+var k8sKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// validateK8sKey applies the Secret key rules of the Kubernetes API server (IsConfigMapKey)
+func validateK8sKey(key string) error {
+	switch {
+	case len(key) > 253:
+		return errors.New("it must be no more than 253 characters")
+	case key == "." || key == "..":
+		return errors.New("it must not be '.' or '..'")
+	case !k8sKeyPattern.MatchString(key):
+		return errors.New("it must consist of alphanumeric characters, '-', '_' or '.'")
+	}
+	return nil
+}
+
+// hclEscaper escapes values for quoted HCL strings, including template sequences
+var hclEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "${", "$${", "%{", "%%{")
+
+func writeTfvars(w io.Writer, secrets []ExportSecret, _ FormatOptions) error {
+	buf := bufio.NewWriter(w)
+	for _, secret := range secrets {
+		fmt.Fprintf(buf, "%s = \"%s\"\n", secret.Name, hclEscaper.Replace(secret.Value))
+	}
+	return buf.Flush()
+}
+
+// csvHeader is the header row of the csv format
+var csvHeader = []string{"name", "value", "content_type", "enabled", "expires", "not_before", "tags"}
+
+func writeCSV(w io.Writer, secrets []ExportSecret, _ FormatOptions) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, secret := range secrets {
+		enabled, expires, notBefore := "true", "", ""
+		if attrs := secret.Attributes; attrs != nil {
+			if attrs.Enabled != nil {
+				enabled = strconv.FormatBool(*attrs.Enabled)
+			}
+			expires = formatCSVTime(attrs.Expires)
+			notBefore = formatCSVTime(attrs.NotBefore)
+		}
+		tags, err := formatCSVTags(secret.Tags)
+		if err != nil {
+			return fmt.Errorf("secret %s: %w", secret.Name, err)
+		}
+		record := []string{secret.Name, secret.Value, secret.ContentType, enabled, expires, notBefore, tags}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatCSVTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// formatCSVTags writes tags as a JSON object, so keys and values may hold any character
+func formatCSVTags(tags map[string]string) (string, error) {
+	if len(tags) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,76 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+)
+
+// errReadOnly is returned when writing to an export file through FileProvider
+var errReadOnly = errors.New("export files are read-only")
+
+// FileProvider serves the secrets of an export file as a read-only provider,
+// so imports reuse the migration engine. The store argument is ignored.
+type FileProvider struct {
+	secrets map[string]providers.Secret
+}
+
+// NewFileProvider creates a provider for the secrets read from an export file
+func NewFileProvider(secrets []ExportSecret) (*FileProvider, error) {
+	p := &FileProvider{secrets: make(map[string]providers.Secret, len(secrets))}
+	for _, exported := range secrets {
+		if exported.Name == "" {
+			return nil, errors.New("export file contains a secret without a name")
+		}
+		if _, ok := p.secrets[exported.Name]; ok {
+			return nil, fmt.Errorf("export file contains secret %s more than once", exported.Name)
+		}
+		secret := exported.Secret()
+		redact.Register(secret.Value)
+		p.secrets[exported.Name] = secret
+	}
+	return p, nil
+}
+
+func (p *FileProvider) ListSecrets(ctx context.Context, store string) ([]providers.SecretProperties, error) {
+	items := make([]providers.SecretProperties, 0, len(p.secrets))
+	for _, secret := range p.secrets {
+		items = append(items, secret.SecretProperties)
+	}
+	return items, nil
+}
+
+func (p *FileProvider) GetSecret(ctx context.Context, store, name, version string) (*providers.Secret, error) {
+	secret, ok := p.secrets[name]
+	if !ok || (version != "" && version != secret.Version) {
+		return nil, fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+	}
+	return &secret, nil
+}
+
+func (p *FileProvider) PutSecret(ctx context.Context, store string, secret providers.Secret) (*providers.SecretProperties, error) {
+	return nil, errReadOnly
+}
+
+func (p *FileProvider) DeleteSecret(ctx context.Context, store, name string) error {
+	return errReadOnly
+}
+
+func (p *FileProvider) ListVersions(ctx context.Context, store, name string) ([]providers.SecretProperties, error) {
+	secret, ok := p.secrets[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+	}
+	return []providers.SecretProperties{secret.SecretProperties}, nil
+}
+
+func (p *FileProvider) GetMetadata(ctx context.Context, store, name string) (*providers.SecretProperties, error) {
+	secret, err := p.GetSecret(ctx, store, name, "")
+	if err != nil {
+		return nil, err
+	}
+	return &secret.SecretProperties, nil
+}
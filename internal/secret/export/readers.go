@@ -0,0 +1,213 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func readJSON(data []byte) ([]ExportSecret, error) {
+	var secrets []ExportSecret
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func readYAML(data []byte) ([]ExportSecret, error) {
+	var secrets []ExportSecret
+	if err := yaml.Unmarshal(data, &secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func readDotenv(data []byte) ([]ExportSecret, error) {
+	var secrets []ExportSecret
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=value", n)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := unescape(value[1:len(value)-1], map[byte]string{'n': "\n", 'r': "\r", '\\': `\`, '"': `"`, '$': "$"})
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			value = unquoted
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		}
+		secrets = append(secrets, ExportSecret{Name: strings.TrimSpace(key), Value: value})
+	}
+	return secrets, scanner.Err()
+}
+
+// k8sSecretInput also accepts the plain text stringData of hand written manifests
+type k8sSecretInput struct {
+	Kind       string            `yaml:"kind"`
+	Data       map[string]string `yaml:"data"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+// sniffK8sSecret tells a Secret manifest apart from a yaml export, which is a list
+func sniffK8sSecret(data []byte) bool {
+	var manifest k8sSecretInput
+	return yaml.Unmarshal(data, &manifest) == nil && manifest.Kind == "Secret"
+}
+
+func readK8sSecret(data []byte) ([]ExportSecret, error) {
+	var manifest k8sSecretInput
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Kind != "Secret" {
+		return nil, fmt.Errorf("expected a Secret manifest, got kind %q", manifest.Kind)
+	}
+	values := make(map[string]string, len(manifest.Data)+len(manifest.StringData))
+	for key, encoded := range manifest.Data {
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("data %s is not base64: %w", key, err)
+		}
+		values[key] = string(value)
+	}
+	for key, value := range manifest.StringData {
+		values[key] = value
+	}
+	secrets := make([]ExportSecret, 0, len(values))
+	for key, value := range values {
+		secrets = append(secrets, ExportSecret{Name: key, Value: value})
+	}
+	sort.Slice(secrets, func(i, j int) bool { return secrets[i].Name < secrets[j].Name })
+	return secrets, nil
+}
+
+// readTfvars reads name = "value" assignments as written by writeTfvars, other HCL is not supported
+func readTfvars(data []byte) ([]ExportSecret, error) {
+	var secrets []ExportSecret
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		value = strings.TrimSpace(value)
+		if !ok || len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+			return nil, fmt.Errorf("line %d: expected name = \"value\"", n)
+		}
+		unquoted, err := unescape(value[1:len(value)-1], map[byte]string{'n': "\n", 'r': "\r", 't': "\t", '\\': `\`, '"': `"`})
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		unquoted = strings.NewReplacer("$${", "${", "%%{", "%{").Replace(unquoted)
+		secrets = append(secrets, ExportSecret{Name: strings.TrimSpace(key), Value: unquoted})
+	}
+	return secrets, scanner.Err()
+}
+
+func readCSV(data []byte) ([]ExportSecret, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("missing name column")
+	}
+	if _, ok := columns["value"]; !ok {
+		return nil, errors.New("missing value column")
+	}
+
+	var secrets []ExportSecret
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return secrets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		secret := ExportSecret{Name: field("name"), Value: field("value"), ContentType: field("content_type"), Attributes: &Attributes{}}
+		if enabled := field("enabled"); enabled != "" {
+			value, err := strconv.ParseBool(enabled)
+			if err != nil {
+				return nil, fmt.Errorf("secret %s: invalid enabled value %q", secret.Name, enabled)
+			}
+			secret.Attributes.Enabled = &value
+		}
+		if secret.Attributes.Expires, err = parseCSVTime(field("expires")); err != nil {
+			return nil, fmt.Errorf("secret %s: %w", secret.Name, err)
+		}
+		if secret.Attributes.NotBefore, err = parseCSVTime(field("not_before")); err != nil {
+			return nil, fmt.Errorf("secret %s: %w", secret.Name, err)
+		}
+		if tags := field("tags"); tags != "" {
+			if err := json.Unmarshal([]byte(tags), &secret.Tags); err != nil {
+				return nil, fmt.Errorf("secret %s: tags are not a JSON object: %w", secret.Name, err)
+			}
+		}
+		secrets = append(secrets, secret)
+	}
+}
+
+func parseCSVTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %q: %w", value, err)
+	}
+	return &t, nil
+}
+
+// unescape resolves backslash escapes using the given escape table
+func unescape(s string, escapes map[byte]string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 == len(s) {
+			return "", errors.New("trailing backslash")
+		}
+		replacement, ok := escapes[s[i+1]]
+		if !ok {
+			return "", fmt.Errorf("unknown escape \\%c", s[i+1])
+		}
+		b.WriteString(replacement)
+		i++
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,93 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+func TestSelector(t *testing.T) {
+	tests := []struct {
+		name             string
+		include, exclude []string
+		tags             []string
+		secret           string
+		secretTags       map[string]string
+		want             bool
+	}{
+		{"no rules", nil, nil, nil, "db", nil, true},
+		{"glob include", []string{"app-*"}, nil, nil, "app-db", nil, true},
+		{"glob include miss", []string{"app-*"}, nil, nil, "db", nil, false},
+		{"regex include", []string{"re:^(api|web)-"}, nil, nil, "web-key", nil, true},
+		{"exclude wins", []string{"app-*"}, []string{"*-old"}, nil, "app-old", nil, false},
+		{"tag value", nil, nil, []string{"env=prod"}, "db", map[string]string{"env": "prod"}, true},
+		{"tag value differs", nil, nil, []string{"env=prod"}, "db", map[string]string{"env": "dev"}, false},
+		{"tag present", nil, nil, []string{"owner"}, "db", map[string]string{"owner": "a"}, true},
+		{"tag missing", nil, nil, []string{"owner"}, "db", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSelector(tt.include, tt.exclude, tt.tags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Match(providers.SecretProperties{Name: tt.secret, Tags: tt.secretTags}); got != tt.want {
+				t.Errorf("Match(%s) = %v, want %v", tt.secret, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSelectorInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		include, tags []string
+	}{
+		{include: []string{"re:("}},
+		{include: []string{"[a"}},
+		{tags: []string{"=prod"}},
+	} {
+		if _, err := NewSelector(tt.include, nil, tt.tags); err == nil {
+			t.Errorf("NewSelector(%v, %v) succeeded", tt.include, tt.tags)
+		}
+	}
+}
+
+func TestRenamer(t *testing.T) {
+	mapping := filepath.Join(t.TempDir(), "names.yaml")
+	if err := os.WriteFile(mapping, []byte("legacy_db: db-password\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		rules RenameRules
+		in    string
+		want  string
+	}{
+		{"strip and add prefix", RenameRules{StripPrefix: "dev-", AddPrefix: "prod-"}, "dev-db", "prod-db"},
+		{"regex", RenameRules{Match: "_", Replace: "-"}, "db_pass_word", "db-pass-word"},
+		{"regex groups", RenameRules{Match: `^(\w+)\.(\w+)$`, Replace: "$2-$1"}, "db.app", "app-db"},
+		{"order", RenameRules{StripPrefix: "a-", Match: "^b", Replace: "c", AddPrefix: "d-"}, "a-b", "d-c"},
+		{"mapping wins", RenameRules{MappingFile: mapping, AddPrefix: "x-"}, "legacy_db", "db-password"},
+		{"mapping miss", RenameRules{MappingFile: mapping, AddPrefix: "x-"}, "api", "x-api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRenamer(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.Rename(tt.in); got != tt.want {
+				t.Errorf("Rename(%s) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	if r, err := NewRenamer(RenameRules{}); r != nil || err != nil {
+		t.Errorf("empty rules gave %v, %v, want no renamer", r, err)
+	}
+	if got := (*Renamer)(nil).Rename("db"); got != "db" {
+		t.Errorf("nil renamer renamed db to %s", got)
+	}
+}
@@ -0,0 +1,60 @@
+package filter
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// AddSelectorFlags registers the name and tag selection flags
+func AddSelectorFlags(flags *pflag.FlagSet) {
+	flags.StringArray("include", nil, "Only secrets whose name matches this glob, or regex when prefixed with re: (repeatable)")
+	flags.StringArray("exclude", nil, "Skip secrets whose name matches this glob, or regex when prefixed with re: (repeatable)")
+	flags.StringArray("tag", nil, "Only secrets with this tag, as key=value or key (repeatable)")
+}
+
+// SelectorFromFlags builds a selector from the flags registered by AddSelectorFlags
+func SelectorFromFlags(flags *pflag.FlagSet) (*Selector, error) {
+	include, err := flags.GetStringArray("include")
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := flags.GetStringArray("exclude")
+	if err != nil {
+		return nil, err
+	}
+	tags, err := flags.GetStringArray("tag")
+	if err != nil {
+		return nil, err
+	}
+	return NewSelector(include, exclude, tags)
+}
+
+// AddRenameFlags registers the destination renaming flags
+func AddRenameFlags(flags *pflag.FlagSet) {
+	flags.String("rename-file", "", "YAML or JSON file mapping source secret names to destination names")
+	flags.String("strip-prefix", "", "Prefix removed from secret names in the destination")
+	flags.String("rename-match", "", "Regex applied to secret names, replaced with --rename-replace")
+	flags.String("rename-replace", "", "Replacement for --rename-match, may reference groups as $1")
+	flags.String("add-prefix", "", "Prefix added to secret names in the destination")
+}
+
+// RenamerFromFlags builds a renamer from the flags registered by AddRenameFlags
+func RenamerFromFlags(flags *pflag.FlagSet) (*Renamer, error) {
+	var rules RenameRules
+	var err error
+	if rules.MappingFile, err = flags.GetString("rename-file"); err != nil {
+		return nil, err
+	}
+	if rules.StripPrefix, err = flags.GetString("strip-prefix"); err != nil {
+		return nil, err
+	}
+	if rules.Match, err = flags.GetString("rename-match"); err != nil {
+		return nil, err
+	}
+	if rules.Replace, err = flags.GetString("rename-replace"); err != nil {
+		return nil, err
+	}
+	if rules.AddPrefix, err = flags.GetString("add-prefix"); err != nil {
+		return nil, err
+	}
+	return NewRenamer(rules)
+}
@@ -0,0 +1,71 @@
+package filter
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Renamer maps source secret names to destination names
+type Renamer struct {
+	mapping     map[string]string
+	stripPrefix string
+	match       *regexp.Regexp
+	replace     string
+	addPrefix   string
+}
+
+// RenameRules configures a Renamer. An exact entry in the mapping file wins,
+// otherwise the prefix is stripped, the regex replacement applied and the prefix added, in that order.
+type RenameRules struct {
+	// MappingFile is a YAML or JSON object of source name to destination name
+	MappingFile string
+	StripPrefix string
+	// Match and Replace follow regexp.ReplaceAllString, Replace may use $1 style references
+	Match     string
+	Replace   string
+	AddPrefix string
+}
+
+// NewRenamer builds a renamer, it returns nil when the rules do not rename anything
+func NewRenamer(rules RenameRules) (*Renamer, error) {
+	if rules == (RenameRules{}) {
+		return nil, nil
+	}
+	r := &Renamer{stripPrefix: rules.StripPrefix, replace: rules.Replace, addPrefix: rules.AddPrefix}
+	if rules.Match != "" {
+		re, err := regexp.Compile(rules.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rename regex %q: %w", rules.Match, err)
+		}
+		r.match = re
+	}
+	if rules.MappingFile != "" {
+		data, err := os.ReadFile(rules.MappingFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rename mapping file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &r.mapping); err != nil {
+			return nil, fmt.Errorf("failed to parse rename mapping file %s: %w", rules.MappingFile, err)
+		}
+	}
+	return r, nil
+}
+
+// Rename returns the destination name for a source secret, a nil renamer keeps names unchanged
+func (r *Renamer) Rename(name string) string {
+	if r == nil {
+		return name
+	}
+	if mapped, ok := r.mapping[name]; ok {
+		return mapped
+	}
+	name = strings.TrimPrefix(name, r.stripPrefix)
+	if r.match != nil {
+		name = r.match.ReplaceAllString(name, r.replace)
+	}
+	return r.addPrefix + name
+}
@@ -0,0 +1,107 @@
+package filter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+// regexPrefix marks a name pattern as a regular expression instead of a glob
+const regexPrefix = "re:"
+
+// pattern matches secret names with a glob or, when prefixed with "re:", a regular expression
+type pattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+func parsePattern(s string) (pattern, error) {
+	if expr, ok := strings.CutPrefix(s, regexPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return pattern{}, fmt.Errorf("invalid name regex %q: %w", expr, err)
+		}
+		return pattern{re: re}, nil
+	}
+	if _, err := path.Match(s, ""); err != nil {
+		return pattern{}, fmt.Errorf("invalid name glob %q: %w", s, err)
+	}
+	return pattern{glob: s}, nil
+}
+
+func (p pattern) match(name string) bool {
+	if p.re != nil {
+		return p.re.MatchString(name)
+	}
+	ok, _ := path.Match(p.glob, name)
+	return ok
+}
+
+// Selector decides which secrets take part in an operation
+type Selector struct {
+	include []pattern
+	exclude []pattern
+	// tags maps tag keys to required values, an empty value only requires the tag to be present
+	tags map[string]string
+}
+
+// NewSelector builds a selector from name patterns and key=value tag selectors.
+// A secret is selected when it matches any include pattern (or there are none),
+// no exclude pattern and every tag selector.
+func NewSelector(include, exclude, tags []string) (*Selector, error) {
+	s := &Selector{tags: make(map[string]string, len(tags))}
+	for _, in := range include {
+		p, err := parsePattern(in)
+		if err != nil {
+			return nil, err
+		}
+		s.include = append(s.include, p)
+	}
+	for _, ex := range exclude {
+		p, err := parsePattern(ex)
+		if err != nil {
+			return nil, err
+		}
+		s.exclude = append(s.exclude, p)
+	}
+	for _, tag := range tags {
+		key, value, _ := strings.Cut(tag, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid tag selector %q, expected key=value or key", tag)
+		}
+		s.tags[key] = value
+	}
+	return s, nil
+}
+
+// Match reports whether a secret is selected, a nil selector selects everything
+func (s *Selector) Match(props providers.SecretProperties) bool {
+	if s == nil {
+		return true
+	}
+	if len(s.include) > 0 && !matchAny(s.include, props.Name) {
+		return false
+	}
+	if matchAny(s.exclude, props.Name) {
+		return false
+	}
+	for key, want := range s.tags {
+		got, ok := props.Tags[key]
+		if !ok || (want != "" && got != want) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAny(patterns []pattern, name string) bool {
+	for _, p := range patterns {
+		if p.match(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package filter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/spf13/pflag"
+)
+
+// State classifies a secret by its lifecycle attributes
+type State string
+
+const (
+	StateActive   State = "active"
+	StateDisabled State = "disabled"
+	StateExpired  State = "expired"
+	StateDeleted  State = "deleted"
+)
+
+// StateOf returns whether a secret is active, disabled or expired at now.
+// Disabled wins over expired as its value cannot be read either way.
+func StateOf(props providers.SecretProperties, now time.Time) State {
+	switch {
+	case !props.Enabled:
+		return StateDisabled
+	case props.Expires != nil && props.Expires.Before(now):
+		return StateExpired
+	default:
+		return StateActive
+	}
+}
+
+// StatePolicy decides how disabled or expired secrets are handled
+type StatePolicy string
+
+const (
+	// StateInclude treats the secret like any other, reading its value
+	StateInclude StatePolicy = "include"
+	StateSkip    StatePolicy = "skip"
+	// StateMetadataOnly keeps the secret's name, tags and attributes without reading its value
+	StateMetadataOnly StatePolicy = "include-metadata-only"
+	StateFail         StatePolicy = "fail"
+)
+
+// StatePolicies lists every supported policy
+var StatePolicies = []StatePolicy{StateInclude, StateSkip, StateMetadataOnly, StateFail}
+
+// ParseStatePolicy validates a policy name
+func ParseStatePolicy(s string) (StatePolicy, error) {
+	for _, p := range StatePolicies {
+		if string(p) == s {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown policy %q, expected one of %v", s, StatePolicies)
+}
+
+// StateRules holds the policy applied to each non active state
+type StateRules struct {
+	Disabled StatePolicy
+	Expired  StatePolicy
+}
+
+// DefaultStateRules skips disabled secrets, whose values providers usually refuse to return,
+// and treats expired secrets like active ones
+var DefaultStateRules = StateRules{Disabled: StateSkip, Expired: StateInclude}
+
+// For returns the policy for a state, active secrets are always included
+func (r StateRules) For(state State) StatePolicy {
+	switch state {
+	case StateDisabled:
+		return r.Disabled
+	case StateExpired:
+		return r.Expired
+	default:
+		return StateInclude
+	}
+}
+
+// AddStateFlags registers the disabled and expired secret policy flags
+func AddStateFlags(flags *pflag.FlagSet) {
+	flags.String("on-disabled", string(DefaultStateRules.Disabled), "How to handle disabled secrets: include, skip, include-metadata-only or fail")
+	flags.String("on-expired", string(DefaultStateRules.Expired), "How to handle expired secrets: include, skip, include-metadata-only or fail")
+}
+
+// StateRulesFromFlags reads the flags registered by AddStateFlags
+func StateRulesFromFlags(flags *pflag.FlagSet) (StateRules, error) {
+	rules := DefaultStateRules
+	disabled, err := flags.GetString("on-disabled")
+	if err != nil {
+		return rules, err
+	}
+	if rules.Disabled, err = ParseStatePolicy(disabled); err != nil {
+		return rules, fmt.Errorf("--on-disabled: %w", err)
+	}
+	expired, err := flags.GetString("on-expired")
+	if err != nil {
+		return rules, err
+	}
+	if rules.Expired, err = ParseStatePolicy(expired); err != nil {
+		return rules, fmt.Errorf("--on-expired: %w", err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,58 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/spf13/pflag"
+)
+
+func TestStateOf(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	tests := []struct {
+		name  string
+		props providers.SecretProperties
+		want  State
+	}{
+		{"active", providers.SecretProperties{Enabled: true}, StateActive},
+		{"expires later", providers.SecretProperties{Enabled: true, Expires: &future}, StateActive},
+		{"expired", providers.SecretProperties{Enabled: true, Expires: &past}, StateExpired},
+		{"disabled", providers.SecretProperties{}, StateDisabled},
+		{"disabled wins over expired", providers.SecretProperties{Expires: &past}, StateDisabled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StateOf(tt.props, now); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStateRulesFromFlags(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    StateRules
+		wantErr bool
+	}{
+		{nil, DefaultStateRules, false},
+		{[]string{"--on-disabled", "include-metadata-only", "--on-expired", "fail"}, StateRules{Disabled: StateMetadataOnly, Expired: StateFail}, false},
+		{[]string{"--on-expired", "keep"}, StateRules{}, true},
+	}
+	for _, tt := range tests {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		AddStateFlags(flags)
+		if err := flags.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		got, err := StateRulesFromFlags(flags)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("%v: got %+v, %v", tt.args, got, err)
+		}
+	}
+	if got := DefaultStateRules.For(StateActive); got != StateInclude {
+		t.Errorf("active secrets get %s, want include", got)
+	}
+}
@@ -0,0 +1,59 @@
+package manifest
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// defaultLength is the length of generated values when none is set
+const defaultLength = 32
+
+var charsets = map[string]string{
+	"alphanumeric": "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+	"hex":          "0123456789abcdef",
+	"ascii":        "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789!#$%&()*+,-./:;<=>?@[]^_{|}~",
+}
+
+// Generator creates random values
+type Generator struct {
+	Length int `yaml:"length,omitempty"`
+	// Charset is alphanumeric, hex or ascii, alphanumeric by default
+	Charset string `yaml:"charset,omitempty"`
+}
+
+func (g *Generator) validate() error {
+	if g.Length < 0 {
+		return fmt.Errorf("invalid generated length %d", g.Length)
+	}
+	if _, ok := charsets[g.charset()]; !ok {
+		return fmt.Errorf("unknown charset %q, expected alphanumeric, hex or ascii", g.Charset)
+	}
+	return nil
+}
+
+func (g *Generator) charset() string {
+	if g.Charset == "" {
+		return "alphanumeric"
+	}
+	return g.Charset
+}
+
+// Generate returns a new random value
+func (g *Generator) Generate() (string, error) {
+	length := g.Length
+	if length == 0 {
+		length = defaultLength
+	}
+	chars := charsets[g.charset()]
+	max := big.NewInt(int64(len(chars)))
+	value := make([]byte, length)
+	for i := range value {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate value: %w", err)
+		}
+		value[i] = chars[n.Int64()]
+	}
+	return string(value), nil
+}
@@ -0,0 +1,269 @@
+// Package manifest reads declarative secret manifests: YAML files describing the
+// desired secrets of a store and where their values come from, without the values.
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/export"
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest is the desired state of a store
+type Manifest struct {
+	// Store is the address of the store the manifest describes, <provider>://<store>
+	Store   string   `yaml:"store"`
+	Secrets []Secret `yaml:"secrets"`
+
+	// path of the manifest file, relative file sources are resolved from its directory
+	path string
+}
+
+// Secret is a desired secret, its value is read from Source
+type Secret struct {
+	Name        string            `yaml:"name"`
+	ContentType string            `yaml:"contentType,omitempty"`
+	Tags        map[string]string `yaml:"tags,omitempty"`
+	Enabled     *bool             `yaml:"enabled,omitempty"`
+	Expires     *time.Time        `yaml:"expires,omitempty"`
+	NotBefore   *time.Time        `yaml:"notBefore,omitempty"`
+	Source      Source            `yaml:"source"`
+}
+
+// Source is where the value of a secret comes from, exactly one field must be set
+type Source struct {
+	// Store copies the value of a secret in another store
+	Store *StoreSource `yaml:"store,omitempty"`
+	// Env reads the value from an environment variable
+	Env string `yaml:"env,omitempty"`
+	// File reads the value from a file, relative to the manifest
+	File string `yaml:"file,omitempty"`
+	// Generate creates a random value once, an existing value is kept
+	Generate *Generator `yaml:"generate,omitempty"`
+}
+
+// StoreSource references a secret in another store
+type StoreSource struct {
+	// Address of the store, <provider>://<store>
+	Address string `yaml:"address"`
+	// Name of the secret, defaults to the name of the desired secret
+	Name    string `yaml:"name,omitempty"`
+	Version string `yaml:"version,omitempty"`
+}
+
+// Load reads and validates a manifest file
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	m := &Manifest{path: path}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+func (m *Manifest) validate() error {
+	var errs []error
+	seen := make(map[string]bool, len(m.Secrets))
+	for i, secret := range m.Secrets {
+		if secret.Name == "" {
+			errs = append(errs, fmt.Errorf("secret %d has no name", i+1))
+			continue
+		}
+		if seen[secret.Name] {
+			errs = append(errs, fmt.Errorf("secret %s is declared more than once", secret.Name))
+		}
+		seen[secret.Name] = true
+		if err := secret.Source.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("secret %s: %w", secret.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s Source) validate() error {
+	n := 0
+	for _, set := range []bool{s.Store != nil, s.Env != "", s.File != "", s.Generate != nil} {
+		if set {
+			n++
+		}
+	}
+	switch {
+	case n != 1:
+		return errors.New("source needs exactly one of store, env, file or generate")
+	case s.Store != nil:
+		_, err := providers.ParseStoreRef(s.Store.Address)
+		return err
+	case s.Generate != nil:
+		return s.Generate.validate()
+	}
+	return nil
+}
+
+// Resolve reads the value of every desired secret. Generated values are only created for
+// secrets missing from dst, existing ones keep their current value so the manifest converges.
+// A disabled generated secret keeps its unreadable value as long as its metadata is unchanged.
+// Every secret that cannot be resolved is reported at once.
+func (m *Manifest) Resolve(ctx context.Context, dst migrate.Endpoint, concurrency int, retry batch.RetryPolicy) ([]providers.Secret, error) {
+	r := &resolver{dst: dst, retry: retry, dir: filepath.Dir(m.path)}
+	secrets := make([]providers.Secret, len(m.Secrets))
+	errs := make([]error, len(m.Secrets))
+	batch.ForEach(ctx, len(m.Secrets), concurrency, func(ctx context.Context, i int) {
+		desired := m.Secrets[i]
+		secret := providers.Secret{SecretProperties: desired.properties()}
+		value, err := r.value(ctx, desired, secret.SecretProperties)
+		if err != nil {
+			errs[i] = fmt.Errorf("secret %s: %w", desired.Name, err)
+			return
+		}
+		redact.Register(value)
+		secret.Value = value
+		secrets[i] = secret
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// properties returns the desired metadata of a secret, secrets are enabled unless disabled explicitly
+func (s Secret) properties() providers.SecretProperties {
+	enabled := true
+	if s.Enabled != nil {
+		enabled = *s.Enabled
+	}
+	return providers.SecretProperties{
+		Name:        s.Name,
+		ContentType: s.ContentType,
+		Tags:        s.Tags,
+		Enabled:     enabled,
+		Expires:     s.Expires,
+		NotBefore:   s.NotBefore,
+	}
+}
+
+type resolver struct {
+	dst   migrate.Endpoint
+	retry batch.RetryPolicy
+	dir   string
+	// opener shares the source providers between every secret referencing them
+	opener providers.Opener
+}
+
+func (r *resolver) value(ctx context.Context, desired Secret, props providers.SecretProperties) (string, error) {
+	source := desired.Source
+	switch {
+	case source.Env != "":
+		value, ok := os.LookupEnv(source.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", source.Env)
+		}
+		return value, nil
+	case source.File != "":
+		path := source.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(r.dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read value file: %w", err)
+		}
+		return string(data), nil
+	case source.Generate != nil:
+		current, readable, err := migrate.ReadDestination(ctx, r.dst, desired.Name, r.retry)
+		switch {
+		case errors.Is(err, providers.ErrNotFound):
+			return source.Generate.Generate()
+		case err != nil:
+			return "", fmt.Errorf("failed to read current value: %w", err)
+		case readable:
+			return current.Value, nil
+		}
+		// the value of a disabled secret cannot be read, it can only be kept while nothing else changes
+		mapped, _, _ := migrate.MapFor(providers.Secret{SecretProperties: props}, r.dst.Provider)
+		if !migrate.SameMetadata(current.SecretProperties, mapped.SecretProperties) {
+			return "", errors.New("the generated value cannot be read as the secret is disabled in the store, enable it there before changing its metadata")
+		}
+		return "", nil
+	default:
+		ep, err := r.open(source.Store.Address)
+		if err != nil {
+			return "", err
+		}
+		name := source.Store.Name
+		if name == "" {
+			name = desired.Name
+		}
+		secret, err := r.get(ctx, ep, name, source.Store.Version)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s from %s: %w", name, source.Store.Address, err)
+		}
+		return secret.Value, nil
+	}
+}
+
+func (r *resolver) get(ctx context.Context, ep migrate.Endpoint, name, version string) (*providers.Secret, error) {
+	secret, err := batch.Retry(ctx, r.retry, func() (*providers.Secret, error) {
+		return ep.Provider.GetSecret(ctx, ep.Store, name, version)
+	})
+	if err != nil {
+		return nil, err
+	}
+	redact.Register(secret.Value)
+	return secret, nil
+}
+
+func (r *resolver) open(address string) (migrate.Endpoint, error) {
+	ref, err := providers.ParseStoreRef(address)
+	if err != nil {
+		return migrate.Endpoint{}, err
+	}
+	provider, err := r.opener.Open(ref)
+	if err != nil {
+		return migrate.Endpoint{}, err
+	}
+	return migrate.Endpoint{Provider: provider, Store: ref.Store}, nil
+}
+
+// Execute resolves the manifest and converges dst to it with the migration engine,
+// writing the plan to w. Secrets in dst that are not in the manifest are only
+// deleted with opts.Prune.
+func Execute(ctx context.Context, m *Manifest, dst migrate.Endpoint, opts migrate.Options, w io.Writer) error {
+	secrets, err := m.Resolve(ctx, dst, opts.Concurrency, opts.Retry)
+	if err != nil {
+		return err
+	}
+	desired := make([]export.ExportSecret, len(secrets))
+	for i, secret := range secrets {
+		desired[i] = export.FromSecret(m.Store, secret)
+	}
+	source, err := export.NewFileProvider(desired)
+	if err != nil {
+		return err
+	}
+	// every desired value was resolved above, disabled secrets included
+	opts.States = filter.StateRules{Disabled: filter.StateInclude, Expired: filter.StateInclude}
+	return migrate.Execute(ctx, migrate.Endpoint{Provider: source, Store: m.path}, dst, opts, w)
+}
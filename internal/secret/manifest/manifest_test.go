@@ -0,0 +1,89 @@
+package manifest
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hazyforge/hazyctl/internal/secret/migrate"
+	"github.com/hazyforge/hazyctl/internal/secret/providers/providertest"
+)
+
+// load writes a manifest to a temporary directory and loads it
+func load(t *testing.T, manifest string) *Manifest {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secrets.yaml")
+	if err := os.WriteFile(path, []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func apply(ctx context.Context, m *Manifest, dst *providertest.Memory) error {
+	opts := migrate.Options{OnConflict: migrate.ConflictOverwriteIfDifferent, MaxDeletions: -1}
+	return Execute(ctx, m, migrate.Endpoint{Provider: dst, Store: "dst"}, opts, io.Discard)
+}
+
+func TestApplyTwice(t *testing.T) {
+	t.Setenv("MANIFEST_TEST_VALUE", "from-env")
+	m := load(t, `
+secrets:
+  - name: plain
+    source: {env: MANIFEST_TEST_VALUE}
+  - name: off
+    enabled: false
+    source: {env: MANIFEST_TEST_VALUE}
+  - name: generated
+    source: {generate: {length: 16}}
+  - name: generated-off
+    enabled: false
+    source: {generate: {}}
+`)
+	dst := providertest.NewMemory()
+	ctx := context.Background()
+	for run := 1; run <= 2; run++ {
+		if err := apply(ctx, m, dst); err != nil {
+			t.Fatalf("apply %d: %v", run, err)
+		}
+		for _, name := range []string{"plain", "off", "generated", "generated-off"} {
+			if versions := dst.Versions("dst", name); len(versions) != 1 {
+				t.Errorf("apply %d: %s has %d versions, want the first apply only", run, name, len(versions))
+			}
+		}
+	}
+	if got := dst.Versions("dst", "generated")[0].Value; len(got) != 16 {
+		t.Errorf("generated %q, want 16 characters", got)
+	}
+	if dst.Versions("dst", "generated-off")[0].Enabled {
+		t.Error("generated-off was written enabled")
+	}
+}
+
+func TestApplyDisabledGeneratedMetadata(t *testing.T) {
+	dst := providertest.NewMemory()
+	ctx := context.Background()
+	if err := apply(ctx, load(t, "secrets:\n  - {name: token, enabled: false, source: {generate: {}}}\n"), dst); err != nil {
+		t.Fatal(err)
+	}
+
+	// the unreadable value must not be replaced when only the tags change
+	err := apply(ctx, load(t, "secrets:\n  - {name: token, enabled: false, tags: {team: a}, source: {generate: {}}}\n"), dst)
+	if err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("got %v, want the disabled secret reported", err)
+	}
+	if versions := dst.Versions("dst", "token"); len(versions) != 1 {
+		t.Errorf("token has %d versions, want it left alone", len(versions))
+	}
+
+	// enabling it from the manifest would need the value as well
+	if err := apply(ctx, load(t, "secrets:\n  - {name: token, source: {generate: {}}}\n"), dst); err == nil {
+		t.Error("enabling a disabled generated secret replaced its value")
+	}
+}
@@ -0,0 +1,99 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+// ConflictPolicy decides what happens when a secret already exists in the destination
+type ConflictPolicy string
+
+const (
+	ConflictSkip                 ConflictPolicy = "skip"
+	ConflictOverwrite            ConflictPolicy = "overwrite"
+	ConflictOverwriteIfDifferent ConflictPolicy = "overwrite-if-different"
+	ConflictFail                 ConflictPolicy = "fail"
+	ConflictRename               ConflictPolicy = "rename-with-suffix"
+)
+
+// ConflictPolicies lists every supported policy
+var ConflictPolicies = []ConflictPolicy{
+	ConflictSkip, ConflictOverwrite, ConflictOverwriteIfDifferent, ConflictFail, ConflictRename,
+}
+
+// ErrConflict is returned for secrets that differ from the destination under ConflictFail
+var ErrConflict = errors.New("secret already exists in destination with different content")
+
+// ParseConflictPolicy validates a policy name
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	for _, p := range ConflictPolicies {
+		if string(p) == s {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown conflict policy %q, expected one of %v", s, ConflictPolicies)
+}
+
+// resolveConflict decides the action for a secret that exists in the destination.
+// diff describes how the destination differs and is empty when both are identical.
+func (p *planner) resolveConflict(ctx context.Context, item *PlanItem, diff string) error {
+	switch p.opts.OnConflict {
+	case ConflictSkip:
+		item.Action, item.Reason = ActionSkip, "exists in destination"
+		return nil
+	case ConflictOverwrite:
+		item.Action, item.Reason = ActionUpdate, diff
+		if diff == "" {
+			item.Reason = "identical, overwrite forced"
+		}
+		return nil
+	}
+
+	if diff == "" {
+		item.Action, item.Reason = ActionSkip, "identical"
+		return nil
+	}
+
+	switch p.opts.OnConflict {
+	case ConflictFail:
+		return fmt.Errorf("%w (%s)", ErrConflict, diff)
+	case ConflictRename:
+		target, err := p.freeName(ctx, item.secret.Name)
+		if err != nil {
+			return err
+		}
+		item.Target, item.secret.Name = target, target
+		item.Action, item.Reason = ActionCreate, diff+", renamed"
+		return nil
+	default:
+		item.Action, item.Reason = ActionUpdate, diff
+		return nil
+	}
+}
+
+// freeName finds the first name derived from name and the conflict suffix
+// that is neither planned nor present in the destination
+func (p *planner) freeName(ctx context.Context, name string) (string, error) {
+	for i := 1; ; i++ {
+		candidate := name + p.opts.ConflictSuffix
+		if i > 1 {
+			candidate = fmt.Sprintf("%s%s-%d", name, p.opts.ConflictSuffix, i)
+		}
+		if !p.reserve(candidate) {
+			continue
+		}
+		_, err := batch.Retry(ctx, p.opts.Retry, func() (*providers.SecretProperties, error) {
+			return p.dst.Provider.GetMetadata(ctx, p.dst.Store, candidate)
+		})
+		if errors.Is(err, providers.ErrNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to check destination name %s: %w", candidate, err)
+		}
+	}
+}
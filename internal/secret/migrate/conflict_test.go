@@ -0,0 +1,98 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers/providertest"
+)
+
+func TestConflictPolicies(t *testing.T) {
+	tests := []struct {
+		policy ConflictPolicy
+		// dst holds the destination secrets next to "same" and "changed", which the source holds as well
+		dst     map[string]string
+		actions map[string]Action
+		targets map[string]string
+		values  map[string]string
+		// conflicts is the number of secrets failing under ConflictFail, which writes nothing
+		conflicts int
+	}{
+		{
+			policy:  ConflictSkip,
+			actions: map[string]Action{"changed": ActionSkip, "new": ActionCreate, "same": ActionSkip},
+			values:  map[string]string{"changed": "old", "new": "v"},
+		},
+		{
+			policy:  ConflictOverwrite,
+			actions: map[string]Action{"changed": ActionUpdate, "new": ActionCreate, "same": ActionUpdate},
+			values:  map[string]string{"changed": "v2", "new": "v"},
+		},
+		{
+			policy:  ConflictOverwriteIfDifferent,
+			actions: map[string]Action{"changed": ActionUpdate, "new": ActionCreate, "same": ActionSkip},
+			values:  map[string]string{"changed": "v2", "new": "v"},
+		},
+		{
+			policy:    ConflictFail,
+			conflicts: 1,
+			values:    map[string]string{"changed": "old"},
+		},
+		{
+			policy:  ConflictRename,
+			actions: map[string]Action{"changed": ActionCreate, "new": ActionCreate, "same": ActionSkip},
+			targets: map[string]string{"changed": "changed-migrated"},
+			values:  map[string]string{"changed": "old", "changed-migrated": "v2"},
+		},
+		{
+			policy:  ConflictRename,
+			dst:     map[string]string{"changed-migrated": "taken", "changed-migrated-2": "taken"},
+			actions: map[string]Action{"changed": ActionCreate, "new": ActionCreate, "same": ActionSkip},
+			targets: map[string]string{"changed": "changed-migrated-3"},
+			values:  map[string]string{"changed-migrated": "taken", "changed-migrated-3": "v2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			m := providertest.NewMemory()
+			put(t, m, "src", "same", "v")
+			put(t, m, "src", "changed", "v2")
+			put(t, m, "src", "new", "v")
+			put(t, m, "dst", "same", "v")
+			put(t, m, "dst", "changed", "old")
+			for name, value := range tt.dst {
+				put(t, m, "dst", name, value)
+			}
+
+			plan, err := Run(context.Background(), Endpoint{m, "src"}, Endpoint{m, "dst"}, Options{OnConflict: tt.policy, ConflictSuffix: "-migrated"})
+			if (err != nil) != (tt.conflicts > 0) || plan.Conflicts() != tt.conflicts {
+				t.Fatalf("got %v with %d conflicts, want %d", err, plan.Conflicts(), tt.conflicts)
+			}
+			for _, item := range plan.Items {
+				if want, ok := tt.actions[item.Name]; ok && item.Action != want {
+					t.Errorf("%s: %s (%s), want %s", item.Name, item.Action, item.Reason, want)
+				}
+				if item.Target != tt.targets[item.Name] {
+					t.Errorf("%s: target %q, want %q", item.Name, item.Target, tt.targets[item.Name])
+				}
+			}
+			for name, want := range tt.values {
+				got, err := m.GetSecret(context.Background(), "dst", name, "")
+				if err != nil || got.Value != want {
+					t.Errorf("destination %s = %+v, %v, want %q", name, got, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseConflictPolicy(t *testing.T) {
+	for _, policy := range ConflictPolicies {
+		if got, err := ParseConflictPolicy(string(policy)); err != nil || got != policy {
+			t.Errorf("ParseConflictPolicy(%q) = %q, %v", policy, got, err)
+		}
+	}
+	if _, err := ParseConflictPolicy("replace"); err == nil {
+		t.Error("an unknown policy was accepted")
+	}
+}
@@ -0,0 +1,133 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+// cleanupTimeout bounds deleting a recovered source secret again, which still runs when the
+// migration was cancelled
+const cleanupTimeout = 2 * time.Minute
+
+// candidate is a source secret considered for migration
+type candidate struct {
+	props providers.SecretProperties
+	state filter.State
+}
+
+func listDeleted(ctx context.Context, src Endpoint, opts Options) ([]candidate, error) {
+	deleted, err := deletedSecrets(ctx, src, opts)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]candidate, 0, len(deleted))
+	for _, secret := range deleted {
+		candidates = append(candidates, candidate{props: secret.SecretProperties, state: filter.StateDeleted})
+	}
+	return candidates, nil
+}
+
+// deletedNames returns the names of the soft-deleted secrets of the destination. Secrets copied
+// into a store that deletes them for good would be lost, so such destinations are refused.
+func deletedNames(ctx context.Context, dst Endpoint, opts Options) (map[string]bool, error) {
+	deleted, err := deletedSecrets(ctx, dst, opts)
+	if err != nil {
+		return nil, fmt.Errorf("destination: %w", err)
+	}
+	names := make(map[string]bool, len(deleted))
+	for _, secret := range deleted {
+		names[secret.Name] = true
+	}
+	return names, nil
+}
+
+func deletedSecrets(ctx context.Context, ep Endpoint, opts Options) ([]providers.DeletedSecret, error) {
+	deletedProvider, ok := ep.Provider.(providers.DeletedSecretProvider)
+	if !ok {
+		return nil, fmt.Errorf("provider of %s does not support soft-deleted secrets", ep.Store)
+	}
+	deleted, err := batch.Retry(ctx, opts.Retry, func() ([]providers.DeletedSecret, error) {
+		return deletedProvider.ListDeletedSecrets(ctx, ep.Store)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted secrets: %w", err)
+	}
+	return deleted, nil
+}
+
+// planDeleted plans a soft-deleted secret. Its value can only be read after recovery,
+// which happens in Apply, so it is never compared with the destination.
+func (p *planner) planDeleted(ctx context.Context, item *PlanItem, props providers.SecretProperties) {
+	item.secret = providers.Secret{SecretProperties: props}
+	if item.Target != "" {
+		item.secret.Name = item.Target
+	}
+	_, err := batch.Retry(ctx, p.opts.Retry, func() (*providers.SecretProperties, error) {
+		return p.dst.Provider.GetMetadata(ctx, p.dst.Store, item.secret.Name)
+	})
+	switch {
+	case p.dstDeleted[item.secret.Name]:
+		// copied by an earlier run, recovering it again would only restart its purge schedule
+		item.Action, item.Reason = ActionSkip, "deleted in source and destination"
+	case errors.Is(err, providers.ErrNotFound):
+		item.Action, item.Reason = ActionCreate, "recovered, copied and deleted again"
+	case err != nil && !errors.Is(err, providers.ErrDisabled):
+		item.fail(fmt.Errorf("failed to read destination secret: %w", err))
+	default:
+		item.Action, item.Reason = ActionSkip, "deleted in source, exists in destination"
+	}
+}
+
+// applyDeleted recovers a soft-deleted secret in the source, copies it and deletes it
+// again in both stores so each ends up holding it soft-deleted
+func applyDeleted(ctx context.Context, item *PlanItem, src, dst Endpoint, opts Options) {
+	recoverer := src.Provider.(providers.DeletedSecretProvider)
+	_, err := batch.Retry(ctx, opts.Retry, func() (struct{}, error) {
+		return struct{}{}, recoverer.RecoverDeletedSecret(ctx, src.Store, item.Name)
+	})
+	if err != nil {
+		item.fail(fmt.Errorf("failed to recover secret: %w", err))
+		return
+	}
+	// whatever happens next the source goes back to its deleted state, even when ctx is cancelled
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+		defer cancel()
+		_, err := batch.Retry(cleanupCtx, opts.Retry, func() (struct{}, error) {
+			return struct{}{}, src.Provider.DeleteSecret(cleanupCtx, src.Store, item.Name)
+		})
+		if err != nil && item.Err == nil {
+			item.fail(fmt.Errorf("copied but failed to delete the recovered source secret again: %w", err))
+		}
+	}()
+
+	secret, err := getSecret(ctx, src, item.Name, "", opts.Retry)
+	switch {
+	case errors.Is(err, providers.ErrDisabled):
+		secret = &providers.Secret{SecretProperties: item.secret.SecretProperties}
+		secret.Tags = withTag(secret.Tags, TagValueUnavailable, "true")
+	case err != nil:
+		item.fail(fmt.Errorf("failed to get recovered secret: %w", err))
+		return
+	}
+	mapped, _, _ := MapFor(*secret, dst.Provider)
+	mapped.Name = item.secret.Name
+
+	if _, err := batch.Retry(ctx, opts.Retry, func() (*providers.SecretProperties, error) {
+		return dst.Provider.PutSecret(ctx, dst.Store, mapped)
+	}); err != nil {
+		item.fail(fmt.Errorf("failed to set secret: %w", err))
+		return
+	}
+	if _, err := batch.Retry(ctx, opts.Retry, func() (struct{}, error) {
+		return struct{}{}, dst.Provider.DeleteSecret(ctx, dst.Store, mapped.Name)
+	}); err != nil {
+		item.fail(fmt.Errorf("copied but failed to delete the destination secret: %w", err))
+	}
+}
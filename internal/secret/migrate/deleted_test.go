@@ -0,0 +1,154 @@
+package migrate
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/providers/providertest"
+)
+
+// actions returns the action of every plan item by name, failed items map to their error
+func actions(plan *Plan) map[string]string {
+	out := make(map[string]string, len(plan.Items))
+	for _, item := range plan.Items {
+		if item.Err != nil {
+			out[item.Name] = "error: " + item.Error
+			continue
+		}
+		out[item.Name] = string(item.Action)
+	}
+	return out
+}
+
+func TestRerunWithDisabledDestination(t *testing.T) {
+	tests := []struct {
+		name string
+		// disabled secrets are written disabled in the source, active ones enabled
+		disabled, active []string
+		// dstDisabled are written disabled into the destination before the first run
+		dstDisabled []string
+		first       map[string]string
+		second      map[string]string
+	}{
+		{
+			name:     "metadata-only placeholders",
+			disabled: []string{"off"},
+			active:   []string{"on"},
+			first:    map[string]string{"off": "create", "on": "create"},
+			second:   map[string]string{"off": "skip", "on": "skip"},
+		},
+		{
+			name:        "destination disabled by hand",
+			active:      []string{"on"},
+			dstDisabled: []string{"on"},
+			first:       map[string]string{"on": "update"},
+			second:      map[string]string{"on": "skip"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dst := providertest.NewMemory(), providertest.NewMemory()
+			for _, name := range tt.disabled {
+				putVersions(t, src, "src", name, "!"+name)
+			}
+			for _, name := range tt.active {
+				put(t, src, "src", name, name)
+			}
+			for _, name := range tt.dstDisabled {
+				putVersions(t, dst, "dst", name, "!old")
+			}
+			opts := Options{
+				OnConflict: ConflictOverwriteIfDifferent,
+				States:     filter.StateRules{Disabled: filter.StateMetadataOnly, Expired: filter.StateInclude},
+			}
+			for run, want := range []map[string]string{tt.first, tt.second} {
+				plan, err := Run(context.Background(), Endpoint{src, "src"}, Endpoint{dst, "dst"}, opts)
+				if err != nil {
+					t.Fatalf("run %d: %v", run+1, err)
+				}
+				if got := actions(plan); !equalMaps(got, want) {
+					t.Errorf("run %d: %v, want %v", run+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDisabledDestinationNote(t *testing.T) {
+	src, dst := providertest.NewMemory(), providertest.NewMemory()
+	put(t, src, "src", "db", "v")
+	putVersions(t, dst, "dst", "db", "!v")
+
+	plan, err := BuildPlan(context.Background(), Endpoint{src, "src"}, Endpoint{dst, "dst"}, Options{OnConflict: ConflictFail})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := plan.Items[0]
+	if item.Err == nil || !strings.Contains(item.Error, "metadata differs") {
+		t.Errorf("got %+v, want a metadata conflict", item)
+	}
+	if len(item.Notes) != 1 || !strings.Contains(item.Notes[0], "not compared") {
+		t.Errorf("notes %q, want the uncompared value reported", item.Notes)
+	}
+}
+
+func TestRerunIncludeDeleted(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newSoftDeleting(), newSoftDeleting()
+	put(t, src, "src", "gone", "v")
+	put(t, src, "src", "live", "v")
+	if err := src.DeleteSecret(ctx, "src", "gone"); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{OnConflict: ConflictOverwriteIfDifferent, IncludeDeleted: true}
+
+	plan, err := Run(ctx, Endpoint{src, "src"}, Endpoint{dst, "dst"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"gone": "create", "live": "create"}; !equalMaps(actions(plan), want) {
+		t.Errorf("first run %v, want %v", actions(plan), want)
+	}
+	if _, ok := dst.deleted["gone"]; !ok || src.recovered != 1 {
+		t.Fatalf("destination deleted %v after %d recoveries, want gone copied and deleted", dst.deleted, src.recovered)
+	}
+
+	// a rerun must not recover the secret again, that would restart its purge schedule
+	plan, err = Run(ctx, Endpoint{src, "src"}, Endpoint{dst, "dst"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"gone": "skip", "live": "skip"}; !equalMaps(actions(plan), want) {
+		t.Errorf("second run %v, want %v", actions(plan), want)
+	}
+	if src.recovered != 1 {
+		t.Errorf("the source secret was recovered %d times, want once", src.recovered)
+	}
+}
+
+func TestIncludeDeletedNeedsSoftDeletingDestination(t *testing.T) {
+	src := newSoftDeleting()
+	opts := Options{IncludeDeleted: true}
+	if _, err := BuildPlan(context.Background(), Endpoint{src, "src"}, Endpoint{providertest.NewMemory(), "dst"}, opts); err == nil {
+		t.Error("deleted secrets were planned into a store that deletes them for good")
+	}
+	var dst providers.SecretProvider = newSoftDeleting()
+	if _, err := BuildPlan(context.Background(), Endpoint{providertest.NewMemory(), "src"}, Endpoint{dst, "dst"}, opts); err == nil {
+		t.Error("deleted secrets were planned from a store without soft delete")
+	}
+}
+
+func equalMaps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/spf13/pflag"
+)
+
+// AddFlags registers the migration options on a command's flag set
+func AddFlags(flags *pflag.FlagSet) {
+	flags.Bool("dry-run", false, "Print the migration plan without writing to the destination")
+	flags.String("format", "text", "Plan output format, text or json")
+	flags.String("on-conflict", string(ConflictOverwriteIfDifferent),
+		"What to do with secrets that already exist in the destination: skip, overwrite, overwrite-if-different, fail or rename-with-suffix")
+	flags.String("conflict-suffix", "-migrated", "Suffix appended to secret names with --on-conflict rename-with-suffix")
+	flags.Bool("strict-metadata", false, "Fail secrets whose metadata the destination cannot store")
+	flags.Bool("all-versions", false, "Replay every version of new secrets in chronological order instead of only the current one")
+	batch.AddFlags(flags)
+	flags.Bool("include-deleted", false, "Also migrate soft-deleted secrets. WARNING: this MODIFIES THE SOURCE, every deleted secret is recovered there and deleted again, which resets its deletion date and scheduled purge. Secrets already deleted in the destination are skipped, so reruns leave them alone")
+	flags.Bool("include-managed", false, "Also migrate secrets backing a certificate as plain secrets instead of skipping them")
+	filter.AddSelectorFlags(flags)
+	filter.AddStateFlags(flags)
+	filter.AddRenameFlags(flags)
+}
+
+// OptionsFromFlags reads the options registered by AddFlags
+func OptionsFromFlags(flags *pflag.FlagSet) (Options, error) {
+	var opts Options
+	var err error
+	if opts.DryRun, err = flags.GetBool("dry-run"); err != nil {
+		return opts, err
+	}
+	if opts.Format, err = flags.GetString("format"); err != nil {
+		return opts, err
+	}
+	onConflict, err := flags.GetString("on-conflict")
+	if err != nil {
+		return opts, err
+	}
+	if opts.OnConflict, err = ParseConflictPolicy(onConflict); err != nil {
+		return opts, err
+	}
+	if opts.ConflictSuffix, err = flags.GetString("conflict-suffix"); err != nil {
+		return opts, err
+	}
+	if opts.StrictMetadata, err = flags.GetBool("strict-metadata"); err != nil {
+		return opts, err
+	}
+	if opts.AllVersions, err = flags.GetBool("all-versions"); err != nil {
+		return opts, err
+	}
+	if opts.Concurrency, opts.Retry, err = batch.FromFlags(flags); err != nil {
+		return opts, err
+	}
+	if opts.Selector, err = filter.SelectorFromFlags(flags); err != nil {
+		return opts, err
+	}
+	if opts.Renamer, err = filter.RenamerFromFlags(flags); err != nil {
+		return opts, err
+	}
+	if opts.States, err = filter.StateRulesFromFlags(flags); err != nil {
+		return opts, err
+	}
+	if opts.IncludeDeleted, err = flags.GetBool("include-deleted"); err != nil {
+		return opts, err
+	}
+	if opts.IncludeManaged, err = flags.GetBool("include-managed"); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
@@ -0,0 +1,95 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+// TagValueUnavailable marks versions whose value could not be read from the source
+const TagValueUnavailable = "hazyctl-value-unavailable"
+
+// loadHistory reads every version of a secret, oldest first, with current last.
+// Disabled versions whose value the source refuses to return are kept as disabled
+// placeholders so the version history and attributes survive the migration.
+func (p *planner) loadHistory(ctx context.Context, item *PlanItem, current *providers.Secret) error {
+	versions, err := batch.Retry(ctx, p.opts.Retry, func() ([]providers.SecretProperties, error) {
+		return p.src.Provider.ListVersions(ctx, p.src.Store, item.Name)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list versions: %w", err)
+	}
+	sortVersions(versions, current.Version)
+
+	unavailable := 0
+	history := make([]providers.Secret, 0, len(versions))
+	for _, props := range versions {
+		if props.Version == current.Version {
+			history = append(history, *current)
+			continue
+		}
+		secret, err := getSecret(ctx, p.src, item.Name, props.Version, p.opts.Retry)
+		switch {
+		case errors.Is(err, providers.ErrDisabled):
+			unavailable++
+			if !p.caps.Disable {
+				// an enabled placeholder would replace a real value, leave the version out
+				continue
+			}
+			placeholder := providers.Secret{SecretProperties: props}
+			placeholder.Tags = withTag(props.Tags, TagValueUnavailable, "true")
+			history = append(history, placeholder)
+		case err != nil:
+			return fmt.Errorf("failed to get version %s: %w", props.Version, err)
+		default:
+			// the version listing carries the attributes of the version, keep them over the read response
+			secret.SecretProperties = props
+			history = append(history, *secret)
+		}
+	}
+
+	item.history = make([]providers.Secret, 0, len(history))
+	for _, version := range history {
+		mapped, _, dropped := MapFor(version, p.dst.Provider)
+		if p.opts.StrictMetadata && len(dropped) > 0 {
+			return fmt.Errorf("destination cannot store %v of version %s", dropped, version.Version)
+		}
+		mapped.Name = item.secret.Name
+		item.history = append(item.history, mapped)
+	}
+	item.Versions = len(item.history)
+	switch {
+	case unavailable > 0 && p.caps.Disable:
+		item.Notes = append(item.Notes, fmt.Sprintf("%d disabled versions copied disabled without their value, tagged %s", unavailable, TagValueUnavailable))
+	case unavailable > 0:
+		item.Notes = append(item.Notes, fmt.Sprintf("%d disabled versions left out, their value is unavailable and the destination cannot store disabled versions", unavailable))
+	}
+	return nil
+}
+
+// sortVersions orders versions by creation time with the current version last
+func sortVersions(versions []providers.SecretProperties, current string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, b := versions[i], versions[j]
+		if (a.Version == current) != (b.Version == current) {
+			return b.Version == current
+		}
+		if a.Created == nil || b.Created == nil {
+			return a.Created != nil
+		}
+		return a.Created.Before(*b.Created)
+	})
+}
+
+func withTag(tags map[string]string, key, value string) map[string]string {
+	out := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
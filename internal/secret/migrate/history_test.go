@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/providers/providertest"
+)
+
+// putVersions writes the versions of a secret in order, a value starting with "!" is written disabled
+func putVersions(t *testing.T, p providers.SecretProvider, store, name string, values ...string) {
+	t.Helper()
+	for _, value := range values {
+		secret := providers.Secret{SecretProperties: providers.SecretProperties{Name: name, Enabled: !strings.HasPrefix(value, "!")}, Value: value}
+		if _, err := p.PutSecret(context.Background(), store, secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHistoryReplay(t *testing.T) {
+	full := providers.Capabilities{Tags: true, ContentType: true, Expiry: true, Disable: true, Versions: true}
+	tests := []struct {
+		name string
+		caps providers.Capabilities
+		// existing is the value already in the destination, empty when the secret is new
+		existing string
+		action   Action
+		// values are the destination versions after the run, "-" for a disabled placeholder
+		values  []string
+		dropped []string
+		note    string
+	}{
+		{
+			name:   "new secret",
+			caps:   full,
+			action: ActionCreate,
+			values: []string{"one", "-", "three"},
+			note:   "1 disabled versions copied disabled without their value",
+		},
+		{
+			name:   "destination without disabled versions",
+			caps:   providers.Capabilities{Tags: true, Versions: true},
+			action: ActionCreate,
+			values: []string{"one", "three"},
+			note:   "1 disabled versions left out",
+		},
+		{
+			name:    "destination without versions",
+			caps:    providers.Capabilities{Tags: true, Disable: true},
+			action:  ActionCreate,
+			values:  []string{"three"},
+			dropped: []string{"version history"},
+		},
+		{
+			name:     "existing secret",
+			caps:     full,
+			existing: "old",
+			action:   ActionUpdate,
+			values:   []string{"old", "three"},
+			note:     "history is only replayed into new secrets",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, m := providertest.NewMemory(), providertest.NewMemory()
+			putVersions(t, src, "src", "db", "one", "!two", "three")
+			if tt.existing != "" {
+				put(t, m, "dst", "db", tt.existing)
+			}
+			dst := limited{m, tt.caps}
+
+			plan, err := Run(context.Background(), Endpoint{src, "src"}, Endpoint{dst, "dst"}, Options{OnConflict: ConflictOverwriteIfDifferent, AllVersions: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			item := plan.Items[0]
+			if item.Action != tt.action {
+				t.Errorf("action %s, want %s", item.Action, tt.action)
+			}
+			if !reflect.DeepEqual(item.Dropped, tt.dropped) {
+				t.Errorf("dropped %v, want %v", item.Dropped, tt.dropped)
+			}
+			if notes := strings.Join(item.Notes, "; "); !strings.Contains(notes, tt.note) {
+				t.Errorf("notes %q, want %q", notes, tt.note)
+			}
+
+			var values []string
+			for _, version := range m.Versions("dst", "db") {
+				value := version.Value
+				if !version.Enabled {
+					value = "-"
+					if version.Tags[TagValueUnavailable] != "true" || version.Value != "" {
+						t.Errorf("placeholder %+v, want an empty value tagged %s", version, TagValueUnavailable)
+					}
+				}
+				values = append(values, value)
+			}
+			if !reflect.DeepEqual(values, tt.values) {
+				t.Errorf("destination versions %v, want %v", values, tt.values)
+			}
+		})
+	}
+}
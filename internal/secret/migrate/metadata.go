@@ -0,0 +1,90 @@
+package migrate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+// Tags used to carry metadata into destinations that support tags but not the metadata itself
+const (
+	TagContentType = "hazyctl-content-type"
+	TagExpires     = "hazyctl-expires"
+	TagNotBefore   = "hazyctl-not-before"
+)
+
+// MapFor adapts a secret to a destination provider, mapping its metadata with MapMetadata
+// and rewriting its tags for providers with stricter tag rules
+func MapFor(secret providers.Secret, dst providers.SecretProvider) (out providers.Secret, mapped, dropped []string) {
+	out, mapped, dropped = MapMetadata(secret, providers.CapabilitiesOf(dst))
+	if len(out.Tags) > 0 {
+		var notes []string
+		out.Tags, notes = providers.TransformTags(dst, out.Tags)
+		mapped = append(mapped, notes...)
+		sort.Strings(mapped)
+	}
+	return out, mapped, dropped
+}
+
+// MapMetadata adapts a secret to the capabilities of the destination.
+// Metadata is moved into tags where possible, everything else is reported as dropped.
+func MapMetadata(secret providers.Secret, caps providers.Capabilities) (out providers.Secret, mapped, dropped []string) {
+	out = secret
+	tags := make(map[string]string, len(secret.Tags))
+	for k, v := range secret.Tags {
+		tags[k] = v
+	}
+
+	if secret.ContentType != "" && !caps.ContentType {
+		out.ContentType = ""
+		if caps.Tags {
+			tags[TagContentType] = secret.ContentType
+			mapped = append(mapped, "content type -> tag "+TagContentType)
+		} else {
+			dropped = append(dropped, "content type")
+		}
+	}
+
+	if !caps.Expiry {
+		out.Expires, out.NotBefore = nil, nil
+		for tag, t := range map[string]*time.Time{TagExpires: secret.Expires, TagNotBefore: secret.NotBefore} {
+			if t == nil {
+				continue
+			}
+			if caps.Tags {
+				tags[tag] = t.UTC().Format(time.RFC3339)
+				mapped = append(mapped, "expiry -> tag "+tag)
+			} else {
+				dropped = append(dropped, strings.TrimPrefix(tag, "hazyctl-"))
+			}
+		}
+	}
+
+	if !secret.Enabled && !caps.Disable {
+		out.Enabled = true
+		dropped = append(dropped, "disabled state")
+	}
+
+	if caps.Tags {
+		if len(tags) > 0 {
+			out.Tags = tags
+		}
+	} else {
+		out.Tags = nil
+		if len(secret.Tags) > 0 {
+			keys := make([]string, 0, len(secret.Tags))
+			for k := range secret.Tags {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			dropped = append(dropped, fmt.Sprintf("tags (%s)", strings.Join(keys, ", ")))
+		}
+	}
+
+	sort.Strings(mapped)
+	sort.Strings(dropped)
+	return out, mapped, dropped
+}
@@ -0,0 +1,380 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+)
+
+// Endpoint is a store of a specific provider
+type Endpoint struct {
+	Provider providers.SecretProvider
+	Store    string
+}
+
+// Options controls how secrets are migrated
+type Options struct {
+	// DryRun computes the plan without writing to the destination
+	DryRun bool
+	// OnConflict decides what happens to secrets that already exist in the destination
+	OnConflict ConflictPolicy
+	// ConflictSuffix is appended to secret names under ConflictRename
+	ConflictSuffix string
+	// StrictMetadata fails a secret instead of dropping metadata the destination cannot store
+	StrictMetadata bool
+	// Format of the printed plan, text or json
+	Format string
+	// Concurrency is the number of secrets processed in parallel
+	Concurrency int
+	// Retry controls how throttled provider calls are retried
+	Retry batch.RetryPolicy
+	// Selector picks the source secrets to migrate, nil selects all of them
+	Selector *filter.Selector
+	// Renamer maps source names to destination names, nil keeps them
+	Renamer *filter.Renamer
+	// States decides how disabled and expired secrets are handled
+	States filter.StateRules
+	// IncludeDeleted migrates soft-deleted source secrets by recovering them, copying them
+	// and deleting them again in both stores
+	IncludeDeleted bool
+	// IncludeManaged migrates secrets backing a certificate as plain secrets, by default they
+	// are skipped since they only move correctly together with their certificate
+	IncludeManaged bool
+	// AllVersions replays the full version history of newly created secrets instead of only the current version
+	AllVersions bool
+	// Prune deletes destination secrets that no longer exist in the source
+	Prune bool
+	// MaxDeletions aborts a pruning run that would delete more secrets, negative for no limit
+	MaxDeletions int
+}
+
+// Run plans the migration of the current version of every secret from src to dst
+// and applies it unless opts.DryRun is set
+func Run(ctx context.Context, src, dst Endpoint, opts Options) (*Plan, error) {
+	plan, err := BuildPlan(ctx, src, dst, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDeletions(plan, opts); err != nil {
+		return plan, err
+	}
+	if opts.DryRun {
+		return plan, nil
+	}
+	if failed := plan.Failed(); opts.OnConflict == ConflictFail && failed > 0 {
+		if conflicts := plan.Conflicts(); conflicts < failed {
+			return plan, fmt.Errorf("%d secrets failed planning, %d of them conflict with the destination, nothing was written", failed, conflicts)
+		}
+		return plan, fmt.Errorf("%d secrets conflict with the destination, nothing was written", failed)
+	}
+	Apply(ctx, plan, src, dst, opts)
+	if err := ctx.Err(); err != nil {
+		return plan, err
+	}
+	if failed := plan.Failed(); failed > 0 {
+		return plan, fmt.Errorf("%d of %d secrets failed to migrate", failed, len(plan.Items))
+	}
+	return plan, nil
+}
+
+// Execute runs a migration and writes its plan to w. The banner is left out
+// of json output so the plan can be redirected to a file.
+func Execute(ctx context.Context, src, dst Endpoint, opts Options, w io.Writer) error {
+	if opts.Format != "json" {
+		fmt.Fprintln(w, "Copying secrets from", src.Store, "to", dst.Store)
+	}
+	plan, err := Run(ctx, src, dst, opts)
+	if plan != nil {
+		if werr := plan.Write(w, opts.Format); werr != nil {
+			return werr
+		}
+	}
+	return err
+}
+
+// BuildPlan compares every source secret with the destination and decides what to do with it.
+// Secrets are planned concurrently, failures are recorded on their plan item.
+func BuildPlan(ctx context.Context, src, dst Endpoint, opts Options) (*Plan, error) {
+	items, err := batch.Retry(ctx, opts.Retry, func() ([]providers.SecretProperties, error) {
+		return src.Provider.ListSecrets(ctx, src.Store)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	candidates := make([]candidate, 0, len(items))
+	now := time.Now()
+	for _, props := range items {
+		candidates = append(candidates, candidate{props: props, state: filter.StateOf(props, now)})
+	}
+	var dstDeleted map[string]bool
+	if opts.IncludeDeleted {
+		deleted, err := listDeleted(ctx, src, opts)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, deleted...)
+		if dstDeleted, err = deletedNames(ctx, dst, opts); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].props.Name < candidates[j].props.Name })
+
+	plan := &Plan{Source: src.Store, Destination: dst.Store, DryRun: opts.DryRun, Prune: opts.Prune, States: map[filter.State]int{}}
+	p := &planner{
+		src:        src,
+		dst:        dst,
+		caps:       providers.CapabilitiesOf(dst.Provider),
+		opts:       opts,
+		reserved:   make(map[string]bool, len(candidates)),
+		dstDeleted: dstDeleted,
+	}
+
+	// select and rename up front so every destination name is reserved before planning
+	var selected []*PlanItem
+	var selectedProps []providers.SecretProperties
+	claimedBy := make(map[string]string, len(candidates))
+	for _, c := range candidates {
+		if !opts.Selector.Match(c.props) {
+			plan.Excluded++
+			continue
+		}
+		plan.States[c.state]++
+		item := &PlanItem{Name: c.props.Name, State: c.state}
+		target := opts.Renamer.Rename(c.props.Name)
+		if target != c.props.Name {
+			item.Target = target
+		}
+		if err := providers.ValidateName(dst.Provider, dst.Store, target); err != nil {
+			item.fail(err)
+		} else if other, ok := claimedBy[target]; ok {
+			item.fail(fmt.Errorf("renamed to %s which is already the destination of %s", target, other))
+		} else {
+			claimedBy[target] = c.props.Name
+			p.reserved[target] = true
+		}
+		selected = append(selected, item)
+		selectedProps = append(selectedProps, c.props)
+	}
+
+	plan.Items = selected
+	batch.ForEach(ctx, len(selected), opts.Concurrency, func(ctx context.Context, i int) {
+		if selected[i].Err == nil {
+			p.planSecret(ctx, selected[i], selectedProps[i])
+		}
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if opts.Prune {
+		if err := planPrune(ctx, plan, candidates, dst, opts); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// planner holds the state shared by concurrently planned secrets
+type planner struct {
+	src, dst Endpoint
+	caps     providers.Capabilities
+	opts     Options
+
+	// reserved holds destination names already claimed by the plan
+	mu       sync.Mutex
+	reserved map[string]bool
+	// dstDeleted holds the names soft-deleted in the destination when migrating deleted secrets
+	dstDeleted map[string]bool
+}
+
+// reserve claims a destination name, it returns false when the name is already taken
+func (p *planner) reserve(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.reserved[name] {
+		return false
+	}
+	p.reserved[name] = true
+	return true
+}
+
+func (p *planner) planSecret(ctx context.Context, item *PlanItem, props providers.SecretProperties) {
+	var secret *providers.Secret
+	switch policy := p.opts.States.For(item.State); {
+	case item.State == filter.StateDeleted:
+		p.planDeleted(ctx, item, props)
+		return
+	case props.Managed && !p.opts.IncludeManaged:
+		item.Action, item.Reason = ActionSkip, "managed by a certificate"
+		return
+	case policy == filter.StateSkip:
+		item.Action, item.Reason = ActionSkip, string(item.State)
+		return
+	case policy == filter.StateFail:
+		item.fail(fmt.Errorf("secret is %s", item.State))
+		return
+	case policy == filter.StateMetadataOnly:
+		item.metadataOnly = true
+		secret = &providers.Secret{SecretProperties: props}
+		secret.Tags = withTag(props.Tags, TagValueUnavailable, "true")
+	default:
+		var err error
+		secret, err = getSecret(ctx, p.src, item.Name, "", p.opts.Retry)
+		if err != nil {
+			item.fail(fmt.Errorf("failed to get secret: %w", err))
+			return
+		}
+	}
+
+	item.secret, item.Mapped, item.Dropped = MapFor(*secret, p.dst.Provider)
+	if p.opts.StrictMetadata && len(item.Dropped) > 0 {
+		item.fail(fmt.Errorf("destination cannot store %s", strings.Join(item.Dropped, ", ")))
+		return
+	}
+	if item.Target != "" {
+		item.secret.Name = item.Target
+	}
+
+	current, readable, err := ReadDestination(ctx, p.dst, item.secret.Name, p.opts.Retry)
+	switch {
+	case errors.Is(err, providers.ErrNotFound):
+		item.Action = ActionCreate
+		if item.metadataOnly {
+			item.Reason = "metadata only, tagged " + TagValueUnavailable
+		}
+	case err != nil:
+		item.fail(fmt.Errorf("failed to read destination secret: %w", err))
+		return
+	case item.metadataOnly:
+		// never replace a real value with a value-less placeholder
+		item.Action, item.Reason = ActionSkip, "metadata only, exists in destination"
+		return
+	default:
+		if !readable {
+			// the value of a disabled destination cannot be read, only its metadata is compared
+			current.Value = item.secret.Value
+			item.Notes = append(item.Notes, "destination is disabled, its value was not compared")
+		}
+		if err := p.resolveConflict(ctx, item, difference(current, &item.secret)); err != nil {
+			item.fail(err)
+			return
+		}
+	}
+
+	if !p.opts.AllVersions || item.Action == ActionSkip || item.metadataOnly {
+		return
+	}
+	switch {
+	case !p.caps.Versions:
+		item.Dropped = append(item.Dropped, "version history")
+	case item.Action == ActionUpdate:
+		item.Notes = append(item.Notes, "history is only replayed into new secrets, writing the current version")
+	default:
+		if err := p.loadHistory(ctx, item, secret); err != nil {
+			item.fail(err)
+		}
+	}
+}
+
+// Apply concurrently writes every planned create, update and delete to the destination.
+// Failures are recorded on their plan item and do not stop the other secrets.
+func Apply(ctx context.Context, plan *Plan, src, dst Endpoint, opts Options) {
+	plan.Applied = true
+	batch.ForEach(ctx, len(plan.Items), opts.Concurrency, func(ctx context.Context, i int) {
+		item := plan.Items[i]
+		if item.Err != nil || item.Action == ActionSkip {
+			return
+		}
+		if item.Action == ActionDelete {
+			_, err := batch.Retry(ctx, opts.Retry, func() (struct{}, error) {
+				return struct{}{}, dst.Provider.DeleteSecret(ctx, dst.Store, item.Name)
+			})
+			if err != nil {
+				item.fail(fmt.Errorf("failed to delete secret: %w", err))
+			}
+			return
+		}
+		if item.State == filter.StateDeleted {
+			applyDeleted(ctx, item, src, dst, opts)
+			return
+		}
+		versions := item.history
+		if len(versions) == 0 {
+			versions = []providers.Secret{item.secret}
+		}
+		// versions are written in order, a failure stops the remaining ones to keep history ordered
+		for _, version := range versions {
+			_, err := batch.Retry(ctx, opts.Retry, func() (*providers.SecretProperties, error) {
+				return dst.Provider.PutSecret(ctx, dst.Store, version)
+			})
+			if err != nil {
+				item.fail(fmt.Errorf("failed to set secret: %w", err))
+				return
+			}
+		}
+	})
+}
+
+// getSecret reads a secret with retries and registers its value for redaction
+func getSecret(ctx context.Context, ep Endpoint, name, version string, retry batch.RetryPolicy) (*providers.Secret, error) {
+	secret, err := batch.Retry(ctx, retry, func() (*providers.Secret, error) {
+		return ep.Provider.GetSecret(ctx, ep.Store, name, version)
+	})
+	if err != nil {
+		return nil, err
+	}
+	redact.Register(secret.Value)
+	return secret, nil
+}
+
+// ReadDestination reads the current version of a destination secret. A disabled secret still
+// exists, its value cannot be read though: it is returned with its metadata only and readable false.
+func ReadDestination(ctx context.Context, ep Endpoint, name string, retry batch.RetryPolicy) (*providers.Secret, bool, error) {
+	secret, err := getSecret(ctx, ep, name, "", retry)
+	if !errors.Is(err, providers.ErrDisabled) {
+		return secret, err == nil, err
+	}
+	props, err := batch.Retry(ctx, retry, func() (*providers.SecretProperties, error) {
+		return ep.Provider.GetMetadata(ctx, ep.Store, name)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &providers.Secret{SecretProperties: *props}, false, nil
+}
+
+// difference describes how two secrets differ, it is empty when they are identical
+func difference(current, desired *providers.Secret) string {
+	switch {
+	case current.Value != desired.Value:
+		return "value differs"
+	case !SameMetadata(current.SecretProperties, desired.SecretProperties):
+		return "metadata differs"
+	default:
+		return ""
+	}
+}
+
+// SameMetadata reports whether two secrets have the same content type, state, dates and tags
+func SameMetadata(a, b providers.SecretProperties) bool {
+	if a.ContentType != b.ContentType || a.Enabled != b.Enabled ||
+		!sameTime(a.Expires, b.Expires) || !sameTime(a.NotBefore, b.NotBefore) ||
+		len(a.Tags) != len(b.Tags) {
+		return false
+	}
+	for k, v := range a.Tags {
+		if bv, ok := b.Tags[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,145 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/providers/providertest"
+)
+
+// limited is a destination that can only store the metadata of caps
+type limited struct {
+	*providertest.Memory
+	caps providers.Capabilities
+}
+
+func (l limited) Capabilities() providers.Capabilities {
+	return l.caps
+}
+
+// unreadable is a destination that fails to read the secrets named broken
+type unreadable struct {
+	*providertest.Memory
+}
+
+func (u unreadable) GetSecret(ctx context.Context, store, name, version string) (*providers.Secret, error) {
+	if name == "broken" {
+		return nil, errors.New("permission denied")
+	}
+	return u.Memory.GetSecret(ctx, store, name, version)
+}
+
+func put(t *testing.T, p providers.SecretProvider, store, name, value string) {
+	t.Helper()
+	providertest.Put(t, p, store, providertest.NewSecret(name, value, nil))
+}
+
+func TestRunConflictFail(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets map[string]string
+		want    string
+	}{
+		{
+			name:    "conflicts only",
+			secrets: map[string]string{"a": "new", "b": "new"},
+			want:    "2 secrets conflict with the destination, nothing was written",
+		},
+		{
+			name:    "conflicts and unreadable secrets",
+			secrets: map[string]string{"a": "new", "broken": "x"},
+			want:    "2 secrets failed planning, 1 of them conflict with the destination, nothing was written",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := providertest.NewMemory()
+			dst := unreadable{m}
+			put(t, m, "dst", "a", "old")
+			put(t, m, "dst", "b", "old")
+			for name, value := range tt.secrets {
+				put(t, m, "src", name, value)
+			}
+			_, err := Run(context.Background(), Endpoint{m, "src"}, Endpoint{dst, "dst"}, Options{OnConflict: ConflictFail})
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("got %v, want %q", err, tt.want)
+			}
+			if got, _ := m.GetSecret(context.Background(), "dst", "a", ""); got.Value != "old" {
+				t.Errorf("destination was written: a = %q", got.Value)
+			}
+		})
+	}
+}
+
+// softDeleting keeps deleted secrets recoverable and fails calls on a cancelled context like a
+// remote store would. cancel, when set, is called once a secret has been recovered.
+type softDeleting struct {
+	*providertest.Memory
+	mu        sync.Mutex
+	deleted   map[string][]providers.Secret
+	cancel    context.CancelFunc
+	recovered int
+}
+
+func newSoftDeleting() *softDeleting {
+	return &softDeleting{Memory: providertest.NewMemory(), deleted: make(map[string][]providers.Secret)}
+}
+
+func (s *softDeleting) DeleteSecret(ctx context.Context, store, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	versions := s.Versions(store, name)
+	s.mu.Lock()
+	s.deleted[name] = versions
+	s.mu.Unlock()
+	return s.Memory.DeleteSecret(ctx, store, name)
+}
+
+func (s *softDeleting) ListDeletedSecrets(ctx context.Context, store string) ([]providers.DeletedSecret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted []providers.DeletedSecret
+	for _, versions := range s.deleted {
+		deleted = append(deleted, providers.DeletedSecret{SecretProperties: versions[len(versions)-1].SecretProperties})
+	}
+	return deleted, nil
+}
+
+func (s *softDeleting) RecoverDeletedSecret(ctx context.Context, store, name string) error {
+	s.mu.Lock()
+	s.Restore(store, name, s.deleted[name])
+	delete(s.deleted, name)
+	s.recovered++
+	s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
+
+func TestApplyDeletedRedeletesSourceAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := newSoftDeleting()
+	src.cancel = cancel
+	put(t, src, "src", "a", "value")
+	if err := src.DeleteSecret(ctx, "src", "a"); err != nil {
+		t.Fatal(err)
+	}
+	dst := providertest.NewMemory()
+
+	item := &PlanItem{Name: "a", Action: ActionCreate}
+	item.secret.Name = "a"
+	applyDeleted(ctx, item, Endpoint{src, "src"}, Endpoint{dst, "dst"}, Options{})
+
+	if _, err := src.GetMetadata(context.Background(), "src", "a"); err == nil {
+		t.Error("the recovered source secret was not deleted again")
+	}
+	if _, ok := src.deleted["a"]; !ok {
+		t.Error("the source secret is not soft-deleted")
+	}
+}
@@ -0,0 +1,185 @@
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+// Action is what a migration does with a single secret
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionSkip   Action = "skip"
+	ActionDelete Action = "delete"
+)
+
+// PlanItem is the planned, and after Apply the actual, outcome for a single secret
+type PlanItem struct {
+	Name    string       `json:"name"`
+	Target  string       `json:"target,omitempty"`
+	State   filter.State `json:"state"`
+	Action  Action       `json:"action,omitempty"`
+	Reason  string       `json:"reason,omitempty"`
+	Mapped  []string     `json:"mapped,omitempty"`
+	Dropped []string     `json:"dropped,omitempty"`
+	Notes   []string     `json:"notes,omitempty"`
+	// Versions is the number of versions written when migrating history
+	Versions int    `json:"versions,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Err      error  `json:"-"`
+
+	secret providers.Secret
+	// history holds every version to replay, oldest first, when migrating history
+	history []providers.Secret
+	// metadataOnly is set when the value was deliberately not read
+	metadataOnly bool
+}
+
+func (i *PlanItem) fail(err error) {
+	i.Err = err
+	i.Error = err.Error()
+}
+
+// Plan is the set of changes a migration makes to the destination
+type Plan struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	DryRun      bool   `json:"dryRun"`
+	Applied     bool   `json:"applied"`
+	Excluded    int    `json:"excluded"`
+	// Prune is set when destination secrets missing from the source are deleted
+	Prune bool `json:"prune,omitempty"`
+	// States counts the selected secrets by lifecycle state
+	States map[filter.State]int `json:"states"`
+	Items  []*PlanItem          `json:"items"`
+}
+
+// Count returns the number of items planned with the given action that did not fail
+func (p *Plan) Count(action Action) int {
+	n := 0
+	for _, item := range p.Items {
+		if item.Err == nil && item.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns the number of secrets that could not be planned or migrated
+func (p *Plan) Failed() int {
+	n := 0
+	for _, item := range p.Items {
+		if item.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Conflicts returns the number of secrets that failed because they conflict with the destination
+func (p *Plan) Conflicts() int {
+	n := 0
+	for _, item := range p.Items {
+		if errors.Is(item.Err, ErrConflict) {
+			n++
+		}
+	}
+	return n
+}
+
+// Write prints the plan as text or json
+func (p *Plan) Write(w io.Writer, format string) error {
+	switch format {
+	case "", "text":
+		p.writeText(w)
+		return nil
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(p)
+	default:
+		return fmt.Errorf("unknown plan format %q, expected text or json", format)
+	}
+}
+
+func (p *Plan) writeText(w io.Writer) {
+	verbs := map[Action]string{ActionCreate: "created", ActionUpdate: "updated", ActionSkip: "skipped", ActionDelete: "deleted"}
+	if !p.Applied {
+		verbs = map[Action]string{ActionCreate: "would create", ActionUpdate: "would update", ActionSkip: "would skip", ActionDelete: "would delete"}
+		if p.DryRun {
+			fmt.Fprintf(w, "Dry run, no changes written to %s\n", p.Destination)
+		} else {
+			fmt.Fprintf(w, "No changes written to %s\n", p.Destination)
+		}
+	}
+	symbols := map[Action]string{ActionCreate: "+", ActionUpdate: "~", ActionSkip: "=", ActionDelete: "-"}
+
+	for _, item := range p.Items {
+		if item.Err != nil {
+			fmt.Fprintf(w, "! %s: %v\n", item.Name, item.Err)
+			continue
+		}
+		name := item.Name
+		if item.Target != "" {
+			name += " -> " + item.Target
+		}
+		line := fmt.Sprintf("%s %s: %s", symbols[item.Action], name, verbs[item.Action])
+		if item.Reason != "" {
+			line += " (" + item.Reason + ")"
+		}
+		if item.Versions > 0 && item.Action != ActionSkip {
+			line += fmt.Sprintf(", %d versions", item.Versions)
+		}
+		fmt.Fprintln(w, line)
+		if len(item.Mapped) > 0 {
+			fmt.Fprintf(w, "    mapped: %s\n", strings.Join(item.Mapped, "; "))
+		}
+		if len(item.Dropped) > 0 {
+			fmt.Fprintf(w, "    dropped, not supported by destination: %s\n", strings.Join(item.Dropped, "; "))
+		}
+		for _, note := range item.Notes {
+			fmt.Fprintf(w, "    note: %s\n", note)
+		}
+	}
+	summary := "%d created, %d updated, %d unchanged, %d failed\n"
+	if !p.Applied {
+		summary = "%d to create, %d to update, %d unchanged, %d failed\n"
+	}
+	if p.Prune {
+		summary = "%d created, %d updated, %d unchanged, %d deleted, %d failed\n"
+		if !p.Applied {
+			summary = "%d to create, %d to update, %d unchanged, %d to delete, %d failed\n"
+		}
+		fmt.Fprintf(w, summary, p.Count(ActionCreate), p.Count(ActionUpdate), p.Count(ActionSkip), p.Count(ActionDelete), p.Failed())
+	} else {
+		fmt.Fprintf(w, summary, p.Count(ActionCreate), p.Count(ActionUpdate), p.Count(ActionSkip), p.Failed())
+	}
+	var states []string
+	for _, state := range []filter.State{filter.StateActive, filter.StateDisabled, filter.StateExpired, filter.StateDeleted} {
+		if n := p.States[state]; n > 0 {
+			states = append(states, fmt.Sprintf("%d %s", n, state))
+		}
+	}
+	if len(states) > 0 {
+		fmt.Fprintf(w, "secrets by state: %s\n", strings.Join(states, ", "))
+	}
+	if p.Excluded > 0 {
+		fmt.Fprintf(w, "%d secrets excluded by filters\n", p.Excluded)
+	}
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,58 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+// ErrTooManyDeletions is returned when pruning would delete more secrets than allowed
+var ErrTooManyDeletions = errors.New("too many deletions")
+
+// planPrune adds a delete item for every destination secret no live source secret maps to.
+// Source secrets left out by filters still protect their destination, and the selector
+// also limits which destination secrets can be pruned. Secrets managed by a certificate
+// are never pruned.
+func planPrune(ctx context.Context, plan *Plan, candidates []candidate, dst Endpoint, opts Options) error {
+	targets := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		if c.state != filter.StateDeleted {
+			targets[opts.Renamer.Rename(c.props.Name)] = true
+		}
+	}
+	items, err := batch.Retry(ctx, opts.Retry, func() ([]providers.SecretProperties, error) {
+		return dst.Provider.ListSecrets(ctx, dst.Store)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list destination secrets: %w", err)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	now := time.Now()
+	for _, props := range items {
+		if targets[props.Name] || props.Managed || !opts.Selector.Match(props) {
+			continue
+		}
+		plan.Items = append(plan.Items, &PlanItem{
+			Name:   props.Name,
+			State:  filter.StateOf(props, now),
+			Action: ActionDelete,
+			Reason: "not in source",
+		})
+	}
+	return nil
+}
+
+// checkDeletions enforces opts.MaxDeletions, a negative limit allows any number of deletions
+func checkDeletions(plan *Plan, opts Options) error {
+	if n := plan.Count(ActionDelete); opts.MaxDeletions >= 0 && n > opts.MaxDeletions {
+		return fmt.Errorf("%w: %d secrets would be deleted, more than the limit of %d, nothing was written",
+			ErrTooManyDeletions, n, opts.MaxDeletions)
+	}
+	return nil
+}
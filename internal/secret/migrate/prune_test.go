@@ -0,0 +1,124 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hazyforge/hazyctl/internal/secret/filter"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/providers/providertest"
+)
+
+func TestSyncTwice(t *testing.T) {
+	ctx := context.Background()
+	src, dst := providertest.NewMemory(), providertest.NewMemory()
+	put(t, src, "src", "api", "v")
+	put(t, src, "src", "db", "v")
+	put(t, dst, "dst", "api", "old")
+	putVersions(t, dst, "dst", "db", "!v")
+	put(t, dst, "dst", "stale", "v")
+	opts := Options{OnConflict: ConflictOverwriteIfDifferent, Prune: true, MaxDeletions: -1}
+
+	plan, err := Run(ctx, Endpoint{src, "src"}, Endpoint{dst, "dst"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"api": "update", "db": "update", "stale": "delete"}; !equalMaps(actions(plan), want) {
+		t.Errorf("first run %v, want %v", actions(plan), want)
+	}
+
+	plan, err = Run(ctx, Endpoint{src, "src"}, Endpoint{dst, "dst"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"api": "skip", "db": "skip"}; !equalMaps(actions(plan), want) {
+		t.Errorf("second run %v, want nothing written", actions(plan))
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name         string
+		selector     []string
+		maxDeletions int
+		wantErr      error
+		// deleted are the destination secrets pruned, nil when nothing may be written
+		deleted map[string]string
+	}{
+		{
+			name:         "unlimited",
+			maxDeletions: -1,
+			deleted:      map[string]string{"app-stale": "delete", "other-stale": "delete"},
+		},
+		{
+			name:         "within the limit",
+			maxDeletions: 2,
+			deleted:      map[string]string{"app-stale": "delete", "other-stale": "delete"},
+		},
+		{
+			name:         "over the limit",
+			maxDeletions: 1,
+			wantErr:      ErrTooManyDeletions,
+		},
+		{
+			name:         "selector limits the pruned secrets",
+			selector:     []string{"app-*"},
+			maxDeletions: 1,
+			deleted:      map[string]string{"app-stale": "delete"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dst := providertest.NewMemory(), providertest.NewMemory()
+			put(t, src, "src", "app-live", "v")
+			put(t, dst, "dst", "app-live", "v")
+			put(t, dst, "dst", "app-stale", "v")
+			put(t, dst, "dst", "other-stale", "v")
+			managed := providers.Secret{SecretProperties: providers.SecretProperties{Name: "app-cert", Enabled: true, Managed: true}, Value: "pem"}
+			if _, err := dst.PutSecret(context.Background(), "dst", managed); err != nil {
+				t.Fatal(err)
+			}
+			selector, err := filter.NewSelector(tt.selector, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts := Options{OnConflict: ConflictOverwriteIfDifferent, Selector: selector, Prune: true, MaxDeletions: tt.maxDeletions}
+
+			plan, err := Run(context.Background(), Endpoint{src, "src"}, Endpoint{dst, "dst"}, opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			deleted := map[string]string{}
+			for _, item := range plan.Items {
+				if item.Action == ActionDelete {
+					deleted[item.Name] = string(item.Action)
+				}
+			}
+			want := tt.deleted
+			if tt.wantErr != nil {
+				want = map[string]string{"app-stale": "delete", "other-stale": "delete"}
+			}
+			if !equalMaps(deleted, want) {
+				t.Errorf("planned deletes %v, want %v", deleted, want)
+			}
+
+			left, err := dst.ListSecrets(context.Background(), "dst")
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := map[string]bool{}
+			for _, props := range left {
+				names[props.Name] = true
+			}
+			if !names["app-cert"] || !names["app-live"] {
+				t.Errorf("destination %v, want the managed and live secrets kept", names)
+			}
+			for name := range want {
+				if names[name] == (tt.wantErr == nil) {
+					t.Errorf("destination %v after pruning %s", names, name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,426 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	sdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+// StageCurrent and StagePrevious are the staging labels Secrets Manager moves on every new version
+const (
+	StageCurrent  = "AWSCURRENT"
+	StagePrevious = "AWSPREVIOUS"
+)
+
+// sdkRetryables are the retry checks of the SDK's standard retryer without its throttling error codes.
+// Throttled requests are left to batch.Retry, which honours --max-retries, instead of multiplying both retry loops.
+var sdkRetryables = []retry.IsErrorRetryable{
+	retry.NoRetryCanceledError{},
+	retry.RetryableError{},
+	retry.RetryableConnectionError{},
+	retry.RetryableHTTPStatusCode{Codes: retry.DefaultRetryableHTTPStatusCodes},
+	retry.RetryableErrorCode{Codes: retry.DefaultRetryableErrorCodes},
+}
+
+// defaultRegion is the store name that uses the region of the AWS configuration
+const defaultRegion = "default"
+
+// defaultRecoveryWindow is how many days deleted secrets stay recoverable
+const defaultRecoveryWindow = 30
+
+var namePattern = regexp.MustCompile(`^[0-9A-Za-z/_+=.@-]{1,512}$`)
+
+// versionIDPattern matches version ids, any other version is read as a staging label such as AWSPREVIOUS
+var versionIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// AWSSecretProvider implements providers.SecretProvider on top of AWS Secrets Manager.
+// Stores are AWS regions, "default" uses the region of the AWS configuration.
+// Credentials come from the standard AWS chain.
+type AWSSecretProvider struct {
+	// endpoint overrides the Secrets Manager endpoint, e.g. for LocalStack
+	endpoint string
+	profile  string
+	// kmsKeyID encrypts newly created secrets, empty uses the account's default key
+	kmsKeyID string
+	// recoveryWindow is the number of days deleted secrets stay recoverable, 0 deletes immediately
+	recoveryWindow int64
+
+	mu      sync.Mutex
+	clients map[string]*secretsmanager.Client
+	// purgeDates are the deletion dates Secrets Manager returned for the secrets deleted by this provider,
+	// by store and name. Other deleted secrets were deleted with an unknown recovery window.
+	purgeDates map[string]time.Time
+}
+
+func init() {
+	providers.Register("aws", New)
+}
+
+// New creates an AWS Secrets Manager provider from the aws config section:
+// profile, endpoint, kms_key_id and recovery_window_days
+func New(cfg providers.Config) (providers.SecretProvider, error) {
+	window, err := strconv.ParseInt(cfg.Get("recovery_window_days", strconv.Itoa(defaultRecoveryWindow)), 10, 64)
+	if err != nil || (window != 0 && (window < 7 || window > 30)) {
+		return nil, fmt.Errorf("invalid recovery_window_days %q, expected 0 or 7 to 30", cfg.Get("recovery_window_days", ""))
+	}
+	return &AWSSecretProvider{
+		endpoint:       cfg.Get("endpoint", ""),
+		profile:        cfg.Get("profile", ""),
+		kmsKeyID:       cfg.Get("kms_key_id", ""),
+		recoveryWindow: window,
+		clients:        make(map[string]*secretsmanager.Client),
+		purgeDates:     make(map[string]time.Time),
+	}, nil
+}
+
+// Capabilities reports what Secrets Manager can store: tags and versions, but no content type,
+// expiry or disabled state
+func (p *AWSSecretProvider) Capabilities() providers.Capabilities {
+	return providers.Capabilities{Tags: true, Versions: true}
+}
+
+// ValidateName checks the Secrets Manager naming rules
+func (p *AWSSecretProvider) ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid AWS secret name %q, names are 1-512 characters of 0-9, a-z, A-Z and /_+=.@-", name)
+	}
+	return nil
+}
+
+func (p *AWSSecretProvider) client(ctx context.Context, store string) (*secretsmanager.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if client, ok := p.clients[store]; ok {
+		return client, nil
+	}
+	var opts []func(*config.LoadOptions) error
+	if store != defaultRegion {
+		opts = append(opts, config.WithRegion(store))
+	}
+	if p.profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(p.profile))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("no AWS region configured, use the region as store name")
+	}
+	client := secretsmanager.NewFromConfig(cfg, func(o *secretsmanager.Options) {
+		if p.endpoint != "" {
+			o.BaseEndpoint = sdk.String(p.endpoint)
+		}
+		o.Retryer = retry.NewStandard(func(so *retry.StandardOptions) {
+			so.Retryables = sdkRetryables
+		})
+	})
+	p.clients[store] = client
+	return client, nil
+}
+
+func (p *AWSSecretProvider) ListSecrets(ctx context.Context, store string) ([]providers.SecretProperties, error) {
+	client, err := p.client(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	var items []providers.SecretProperties
+	pager := secretsmanager.NewListSecretsPaginator(client, &secretsmanager.ListSecretsInput{})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError("", err)
+		}
+		for _, entry := range page.SecretList {
+			props := providers.SecretProperties{
+				Name:    sdk.ToString(entry.Name),
+				Version: currentVersion(entry.SecretVersionsToStages),
+				Tags:    fromTags(entry.Tags),
+				Enabled: true,
+				Created: entry.CreatedDate,
+				Updated: entry.LastChangedDate,
+			}
+			items = append(items, props)
+		}
+	}
+	return items, nil
+}
+
+// GetSecret reads a version by id or by staging label, e.g. AWSPREVIOUS
+func (p *AWSSecretProvider) GetSecret(ctx context.Context, store, name, version string) (*providers.Secret, error) {
+	client, err := p.client(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	input := &secretsmanager.GetSecretValueInput{SecretId: sdk.String(name)}
+	switch {
+	case version == "":
+	case versionIDPattern.MatchString(version):
+		input.VersionId = sdk.String(version)
+	default:
+		input.VersionStage = sdk.String(version)
+	}
+	out, err := client.GetSecretValue(ctx, input)
+	var invalid *types.InvalidRequestException
+	if errors.As(err, &invalid) {
+		// secrets scheduled for deletion refuse reads, they are reported as missing like in ListSecrets
+		if _, describeErr := p.describe(ctx, client, name); errors.Is(describeErr, providers.ErrNotFound) {
+			return nil, describeErr
+		}
+	}
+	if err != nil {
+		return nil, wrapError(name, err)
+	}
+	props, err := p.describe(ctx, client, name)
+	if err != nil {
+		return nil, err
+	}
+	props.Version = sdk.ToString(out.VersionId)
+	props.Created = out.CreatedDate
+	value := sdk.ToString(out.SecretString)
+	if out.SecretString == nil {
+		value = string(out.SecretBinary)
+	}
+	return &providers.Secret{SecretProperties: *props, Value: value}, nil
+}
+
+// PutSecret writes a new version that becomes AWSCURRENT, creating the secret with the configured
+// KMS key when it does not exist yet, and replaces its tags
+func (p *AWSSecretProvider) PutSecret(ctx context.Context, store string, secret providers.Secret) (*providers.SecretProperties, error) {
+	if err := p.ValidateName(secret.Name); err != nil {
+		return nil, err
+	}
+	client, err := p.client(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     sdk.String(secret.Name),
+		SecretString: sdk.String(secret.Value),
+	})
+	var version *string
+	switch {
+	case errors.Is(wrapError(secret.Name, err), providers.ErrNotFound):
+		input := &secretsmanager.CreateSecretInput{
+			Name:         sdk.String(secret.Name),
+			SecretString: sdk.String(secret.Value),
+			Tags:         toTags(secret.Tags),
+		}
+		if p.kmsKeyID != "" {
+			input.KmsKeyId = sdk.String(p.kmsKeyID)
+		}
+		created, err := client.CreateSecret(ctx, input)
+		if err != nil {
+			return nil, wrapError(secret.Name, err)
+		}
+		version = created.VersionId
+	case err != nil:
+		return nil, wrapError(secret.Name, err)
+	default:
+		version = out.VersionId
+		if err := p.replaceTags(ctx, client, secret.Name, secret.Tags); err != nil {
+			return nil, err
+		}
+	}
+	props := secret.SecretProperties
+	props.Version = sdk.ToString(version)
+	return &props, nil
+}
+
+// replaceTags makes the tags of a secret equal to tags
+func (p *AWSSecretProvider) replaceTags(ctx context.Context, client *secretsmanager.Client, name string, tags map[string]string) error {
+	current, err := p.describe(ctx, client, name)
+	if err != nil {
+		return err
+	}
+	var removed []string
+	for k := range current.Tags {
+		if _, ok := tags[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	if len(removed) > 0 {
+		if _, err := client.UntagResource(ctx, &secretsmanager.UntagResourceInput{SecretId: sdk.String(name), TagKeys: removed}); err != nil {
+			return fmt.Errorf("failed to remove tags: %w", wrapError(name, err))
+		}
+	}
+	if len(tags) > 0 {
+		if _, err := client.TagResource(ctx, &secretsmanager.TagResourceInput{SecretId: sdk.String(name), Tags: toTags(tags)}); err != nil {
+			return fmt.Errorf("failed to set tags: %w", wrapError(name, err))
+		}
+	}
+	return nil
+}
+
+// DeleteSecret schedules the deletion of a secret after the recovery window, or deletes it
+// immediately when recovery_window_days is 0
+func (p *AWSSecretProvider) DeleteSecret(ctx context.Context, store, name string) error {
+	client, err := p.client(ctx, store)
+	if err != nil {
+		return err
+	}
+	input := &secretsmanager.DeleteSecretInput{SecretId: sdk.String(name)}
+	if p.recoveryWindow == 0 {
+		input.ForceDeleteWithoutRecovery = sdk.Bool(true)
+	} else {
+		input.RecoveryWindowInDays = sdk.Int64(p.recoveryWindow)
+	}
+	out, err := client.DeleteSecret(ctx, input)
+	if err != nil {
+		return wrapError(name, err)
+	}
+	if out.DeletionDate != nil && p.recoveryWindow > 0 {
+		p.mu.Lock()
+		p.purgeDates[store+"/"+name] = *out.DeletionDate
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+// ListVersions returns the versions that still carry a staging label, oldest first and the
+// AWSCURRENT version last. Labels holds the staging labels of every version.
+func (p *AWSSecretProvider) ListVersions(ctx context.Context, store, name string) ([]providers.SecretProperties, error) {
+	client, err := p.client(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	current, err := p.describe(ctx, client, name)
+	if err != nil {
+		return nil, err
+	}
+	var versions []providers.SecretProperties
+	pager := secretsmanager.NewListSecretVersionIdsPaginator(client, &secretsmanager.ListSecretVersionIdsInput{SecretId: sdk.String(name)})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(name, err)
+		}
+		for _, entry := range page.Versions {
+			props := *current
+			props.Version = sdk.ToString(entry.VersionId)
+			props.Labels = entry.VersionStages
+			props.Created, props.Updated = entry.CreatedDate, entry.CreatedDate
+			versions = append(versions, props)
+		}
+	}
+	// a staging label moved back to an older version makes it current, whatever its creation date
+	sort.SliceStable(versions, func(i, j int) bool {
+		if ci, cj := versions[i].Version == current.Version, versions[j].Version == current.Version; ci != cj {
+			return cj
+		}
+		return timeOf(versions[i].Created).Before(timeOf(versions[j].Created))
+	})
+	return versions, nil
+}
+
+func (p *AWSSecretProvider) GetMetadata(ctx context.Context, store, name string) (*providers.SecretProperties, error) {
+	client, err := p.client(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	return p.describe(ctx, client, name)
+}
+
+func (p *AWSSecretProvider) describe(ctx context.Context, client *secretsmanager.Client, name string) (*providers.SecretProperties, error) {
+	out, err := client.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: sdk.String(name)})
+	if err != nil {
+		return nil, wrapError(name, err)
+	}
+	if out.DeletedDate != nil {
+		return nil, fmt.Errorf("%w: %s is scheduled for deletion", providers.ErrNotFound, name)
+	}
+	return &providers.SecretProperties{
+		Name:    sdk.ToString(out.Name),
+		Version: currentVersion(out.VersionIdsToStages),
+		Tags:    fromTags(out.Tags),
+		Enabled: true,
+		Created: out.CreatedDate,
+		Updated: out.LastChangedDate,
+	}, nil
+}
+
+// currentVersion returns the version labelled AWSCURRENT
+func currentVersion(stages map[string][]string) string {
+	for version, labels := range stages {
+		for _, label := range labels {
+			if label == StageCurrent {
+				return version
+			}
+		}
+	}
+	return ""
+}
+
+func fromTags(tags []types.Tag) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		out[sdk.ToString(tag.Key)] = sdk.ToString(tag.Value)
+	}
+	return out
+}
+
+func toTags(tags map[string]string) []types.Tag {
+	if len(tags) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]types.Tag, 0, len(tags))
+	for _, k := range keys {
+		out = append(out, types.Tag{Key: sdk.String(k), Value: sdk.String(tags[k])})
+	}
+	return out
+}
+
+func timeOf(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// wrapError maps Secrets Manager errors to provider errors
+func wrapError(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if _, ok := retry.DefaultThrottleErrorCodes[apiErr.ErrorCode()]; ok {
+			return &providers.ThrottledError{Err: fmt.Errorf("secret %s: %w", name, err)}
+		}
+	}
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusTooManyRequests {
+		return &providers.ThrottledError{Err: fmt.Errorf("secret %s: %w", name, err)}
+	}
+	if name == "" {
+		return err
+	}
+	return fmt.Errorf("secret %s: %w", name, err)
+}
@@ -0,0 +1,583 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/providers/providertest"
+)
+
+// endpointEnv points the endpoint tests at a Secrets Manager compatible endpoint such as LocalStack,
+// e.g. HAZYCTL_TEST_AWS_ENDPOINT=http://localhost:4566. They run against fakeSecretsManager when it is not set.
+const endpointEnv = "HAZYCTL_TEST_AWS_ENDPOINT"
+
+// kmsKeyEnv optionally names a KMS key of the endpoint new secrets are encrypted with,
+// e.g. the KeyId printed by awslocal kms create-key
+const kmsKeyEnv = "HAZYCTL_TEST_AWS_KMS_KEY_ID"
+
+const testRegion = "us-east-1"
+
+// fakeSecretsManager serves the Secrets Manager JSON API operations the provider uses
+type fakeSecretsManager struct {
+	t *testing.T
+
+	mu      sync.Mutex
+	secrets map[string]*fakeSecret
+	// throttle answers that many requests with a ThrottlingException
+	throttle int
+	requests int
+	next     int
+}
+
+type fakeSecret struct {
+	name     string
+	kmsKeyID string
+	tags     []types.Tag
+	versions []*fakeVersion
+	created  time.Time
+	deleted  *time.Time
+}
+
+type fakeVersion struct {
+	id      string
+	value   string
+	stages  []string
+	created time.Time
+}
+
+func newFakeSecretsManager(t *testing.T) (*fakeSecretsManager, *httptest.Server) {
+	f := &fakeSecretsManager{t: t, secrets: make(map[string]*fakeSecret)}
+	server := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakeSecretsManager) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+	if f.throttle > 0 {
+		f.throttle--
+		f.fail(w, "ThrottlingException", "Rate exceeded")
+		return
+	}
+	var in map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		f.fail(w, "SerializationException", err.Error())
+		return
+	}
+	str := func(key string) string { v, _ := in[key].(string); return v }
+	operation := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "secretsmanager.")
+
+	switch operation {
+	case "ListSecrets":
+		entries := []map[string]any{}
+		for _, name := range sortedNames(f.secrets) {
+			secret := f.secrets[name]
+			if secret.deleted != nil && in["IncludePlannedDeletion"] != true {
+				continue
+			}
+			entry := map[string]any{"Name": name, "Tags": secret.tags, "SecretVersionsToStages": secret.stages(), "CreatedDate": epoch(secret.created)}
+			if secret.deleted != nil {
+				entry["DeletedDate"] = epoch(*secret.deleted)
+			}
+			entries = append(entries, entry)
+		}
+		f.reply(w, map[string]any{"SecretList": entries})
+		return
+	case "CreateSecret":
+		if _, ok := f.secrets[str("Name")]; ok {
+			f.fail(w, "ResourceExistsException", "the secret already exists")
+			return
+		}
+		var tags []types.Tag
+		if raw, err := json.Marshal(in["Tags"]); err == nil {
+			json.Unmarshal(raw, &tags)
+		}
+		secret := &fakeSecret{name: str("Name"), kmsKeyID: str("KmsKeyId"), tags: tags, created: f.now()}
+		f.secrets[secret.name] = secret
+		version := f.addVersion(secret, str("SecretString"))
+		f.reply(w, map[string]any{"Name": secret.name, "VersionId": version.id})
+		return
+	}
+
+	secret, ok := f.secrets[str("SecretId")]
+	if !ok {
+		f.fail(w, "ResourceNotFoundException", "Secrets Manager can't find the specified secret.")
+		return
+	}
+	if secret.deleted != nil && operation != "DescribeSecret" && operation != "RestoreSecret" {
+		f.fail(w, "InvalidRequestException", "You can't perform this operation on the secret because it was marked for deletion.")
+		return
+	}
+	switch operation {
+	case "GetSecretValue":
+		version := secret.find(str("VersionId"), str("VersionStage"))
+		if version == nil {
+			f.fail(w, "ResourceNotFoundException", "Secrets Manager can't find the specified secret value.")
+			return
+		}
+		f.reply(w, map[string]any{"Name": secret.name, "VersionId": version.id, "SecretString": version.value, "VersionStages": version.stages, "CreatedDate": epoch(version.created)})
+	case "DescribeSecret":
+		out := map[string]any{"Name": secret.name, "Tags": secret.tags, "VersionIdsToStages": secret.stages(), "CreatedDate": epoch(secret.created)}
+		if secret.kmsKeyID != "" {
+			out["KmsKeyId"] = secret.kmsKeyID
+		}
+		if secret.deleted != nil {
+			out["DeletedDate"] = epoch(*secret.deleted)
+		}
+		f.reply(w, out)
+	case "PutSecretValue":
+		version := f.addVersion(secret, str("SecretString"))
+		f.reply(w, map[string]any{"Name": secret.name, "VersionId": version.id, "VersionStages": version.stages})
+	case "TagResource":
+		var tags []types.Tag
+		if raw, err := json.Marshal(in["Tags"]); err == nil {
+			json.Unmarshal(raw, &tags)
+		}
+		for _, tag := range tags {
+			secret.tags = slices.DeleteFunc(secret.tags, func(t types.Tag) bool { return sdk.ToString(t.Key) == sdk.ToString(tag.Key) })
+			secret.tags = append(secret.tags, tag)
+		}
+		f.reply(w, map[string]any{})
+	case "UntagResource":
+		keys, _ := in["TagKeys"].([]any)
+		secret.tags = slices.DeleteFunc(secret.tags, func(t types.Tag) bool { return slices.Contains(keys, any(sdk.ToString(t.Key))) })
+		f.reply(w, map[string]any{})
+	case "ListSecretVersionIds":
+		versions := []map[string]any{}
+		for _, version := range secret.versions {
+			if len(version.stages) > 0 {
+				versions = append(versions, map[string]any{"VersionId": version.id, "VersionStages": version.stages, "CreatedDate": epoch(version.created)})
+			}
+		}
+		f.reply(w, map[string]any{"Name": secret.name, "Versions": versions})
+	case "DeleteSecret":
+		if in["ForceDeleteWithoutRecovery"] == true {
+			delete(f.secrets, secret.name)
+			f.reply(w, map[string]any{"Name": secret.name, "DeletionDate": epoch(f.now())})
+			return
+		}
+		days, _ := in["RecoveryWindowInDays"].(float64)
+		deleted := f.now()
+		secret.deleted = &deleted
+		f.reply(w, map[string]any{"Name": secret.name, "DeletionDate": epoch(deleted.Add(time.Duration(days) * 24 * time.Hour))})
+	case "RestoreSecret":
+		secret.deleted = nil
+		f.reply(w, map[string]any{"Name": secret.name})
+	default:
+		f.t.Errorf("unexpected operation %s", operation)
+		f.fail(w, "InvalidAction", operation)
+	}
+}
+
+// now returns a distinct, increasing time for every call so versions sort by creation
+func (f *fakeSecretsManager) now() time.Time {
+	f.next++
+	return time.Date(2025, 3, 1, 12, 0, f.next, 0, time.UTC)
+}
+
+// addVersion makes value the AWSCURRENT version and moves AWSPREVIOUS to the version it replaces
+func (f *fakeSecretsManager) addVersion(secret *fakeSecret, value string) *fakeVersion {
+	for _, version := range secret.versions {
+		stages := slices.DeleteFunc(version.stages, func(s string) bool { return s == StagePrevious })
+		if slices.Contains(stages, StageCurrent) {
+			stages = slices.DeleteFunc(stages, func(s string) bool { return s == StageCurrent })
+			stages = append(stages, StagePrevious)
+		}
+		version.stages = stages
+	}
+	version := &fakeVersion{id: fmt.Sprintf("00000000-0000-0000-0000-%012d", f.next+1), value: value, stages: []string{StageCurrent}, created: f.now()}
+	secret.versions = append(secret.versions, version)
+	return version
+}
+
+func (s *fakeSecret) find(id, stage string) *fakeVersion {
+	if id == "" && stage == "" {
+		stage = StageCurrent
+	}
+	for _, version := range s.versions {
+		if version.id == id || (stage != "" && slices.Contains(version.stages, stage)) {
+			return version
+		}
+	}
+	return nil
+}
+
+func (s *fakeSecret) stages() map[string][]string {
+	stages := make(map[string][]string)
+	for _, version := range s.versions {
+		if len(version.stages) > 0 {
+			stages[version.id] = version.stages
+		}
+	}
+	return stages
+}
+
+func (f *fakeSecretsManager) reply(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeSecretsManager) fail(w http.ResponseWriter, code, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"__type": code, "message": message})
+}
+
+func epoch(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newTestProvider returns a provider talking to a fake Secrets Manager
+func newTestProvider(t *testing.T, cfg providers.Config) (*AWSSecretProvider, *fakeSecretsManager) {
+	t.Helper()
+	f, server := newFakeSecretsManager(t)
+	if cfg == nil {
+		cfg = providers.Config{}
+	}
+	cfg["endpoint"] = server.URL
+	return newProvider(t, cfg), f
+}
+
+func newProvider(t *testing.T, cfg providers.Config) *AWSSecretProvider {
+	t.Helper()
+	// the fake and LocalStack accept any credentials, real ones from the environment are kept
+	if os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		t.Setenv("AWS_ACCESS_KEY_ID", "test")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.(*AWSSecretProvider)
+}
+
+// newEndpointProvider returns a provider for the endpoint named by endpointEnv, or for a fake one
+func newEndpointProvider(t *testing.T) *AWSSecretProvider {
+	t.Helper()
+	endpoint, kmsKeyID := os.Getenv(endpointEnv), os.Getenv(kmsKeyEnv)
+	if endpoint == "" {
+		_, server := newFakeSecretsManager(t)
+		endpoint, kmsKeyID = server.URL, "alias/hazyctl-test"
+	}
+	return newProvider(t, providers.Config{
+		"endpoint":             endpoint,
+		"kms_key_id":           kmsKeyID,
+		"recovery_window_days": "0",
+	})
+}
+
+// testName returns a secret name that does not collide with earlier runs against the same endpoint
+func testName(t *testing.T) string {
+	return fmt.Sprintf("hazyctl-test/%s-%d", t.Name(), time.Now().UnixNano())
+}
+
+func put(t *testing.T, p *AWSSecretProvider, name, value string, tags map[string]string) *providers.SecretProperties {
+	t.Helper()
+	return providertest.Put(t, p, testRegion, providertest.NewSecret(name, value, tags))
+}
+
+func TestProvider(t *testing.T) {
+	p, _ := newTestProvider(t, providers.Config{"recovery_window_days": "0"})
+	providertest.Run(t, p, testRegion)
+}
+
+func TestThrottling(t *testing.T) {
+	p, f := newTestProvider(t, nil)
+	put(t, p, "db", "v", nil)
+
+	// the SDK must not retry on its own, batch.Retry retries throttled calls
+	f.mu.Lock()
+	f.throttle, f.requests = 1, 0
+	f.mu.Unlock()
+	_, err := p.GetSecret(context.Background(), testRegion, "db", "")
+	var throttled *providers.ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("got %v, want a ThrottledError", err)
+	}
+	if f.requests != 1 {
+		t.Errorf("sent %d requests, want 1", f.requests)
+	}
+}
+
+func TestVersionStages(t *testing.T) {
+	p, f := newTestProvider(t, nil)
+	ctx := context.Background()
+	first := put(t, p, "db", "one", nil)
+	second := put(t, p, "db", "two", nil)
+
+	versions, err := p.ListVersions(ctx, testRegion, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{StagePrevious}, {StageCurrent}}
+	if len(versions) != 2 || versions[0].Version != first.Version || !reflect.DeepEqual(versions[0].Labels, want[0]) ||
+		versions[1].Version != second.Version || !reflect.DeepEqual(versions[1].Labels, want[1]) {
+		t.Errorf("got %+v, want the staging labels of %s and %s", versions, first.Version, second.Version)
+	}
+
+	// moving AWSCURRENT back to the first version makes it the last one listed
+	f.mu.Lock()
+	secret := f.secrets["db"]
+	secret.versions[0].stages, secret.versions[1].stages = []string{StageCurrent}, []string{StagePrevious}
+	f.mu.Unlock()
+	if versions, err = p.ListVersions(ctx, testRegion, "db"); err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[1].Version != first.Version {
+		t.Errorf("got %+v, want the current version %s last", versions, first.Version)
+	}
+}
+
+func TestDeletedSecrets(t *testing.T) {
+	p, f := newTestProvider(t, providers.Config{"recovery_window_days": "7"})
+	ctx := context.Background()
+	put(t, p, "db", "v", nil)
+	put(t, p, "api", "v", nil)
+	if err := p.DeleteSecret(ctx, testRegion, "db"); err != nil {
+		t.Fatal(err)
+	}
+	// deleted by another process with an unknown recovery window
+	f.mu.Lock()
+	deleted := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	f.secrets["api"].deleted = &deleted
+	f.mu.Unlock()
+
+	if _, err := p.GetSecret(ctx, testRegion, "db", ""); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v reading a deleted secret, want ErrNotFound", err)
+	}
+	items, err := p.ListDeletedSecrets(ctx, testRegion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Name != "api" || items[1].Name != "db" {
+		t.Fatalf("listed %+v, want api and db", items)
+	}
+	if items[0].ScheduledPurgeDate != nil {
+		t.Errorf("api purges at %v, want it unknown", items[0].ScheduledPurgeDate)
+	}
+	if purge := items[1].ScheduledPurgeDate; purge == nil || !purge.Equal(items[1].DeletedDate.Add(7*24*time.Hour)) {
+		t.Errorf("db purges at %v, want 7 days after %v", purge, items[1].DeletedDate)
+	}
+
+	if err := p.RecoverDeletedSecret(ctx, testRegion, "db"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := p.GetSecret(ctx, testRegion, "db", ""); err != nil || got.Value != "v" {
+		t.Errorf("got %+v, %v after recovery", got, err)
+	}
+}
+
+func TestEndpointSecrets(t *testing.T) {
+	p := newEndpointProvider(t)
+	ctx := context.Background()
+	name := testName(t)
+	t.Cleanup(func() { p.DeleteSecret(context.Background(), testRegion, name) })
+
+	put(t, p, name, "one", map[string]string{"owner": "team-a"})
+	got, err := p.GetSecret(ctx, testRegion, name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "one" || !reflect.DeepEqual(got.Tags, map[string]string{"owner": "team-a"}) {
+		t.Errorf("got %+v, want the value with its tags", got)
+	}
+
+	items, err := p.ListSecrets(ctx, testRegion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, item := range items {
+		if item.Name == name {
+			found = true
+			if item.Version != got.Version {
+				t.Errorf("listed version %s, want the current version %s", item.Version, got.Version)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("%s was not listed", name)
+	}
+
+	if err := p.DeleteSecret(ctx, testRegion, name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetMetadata(ctx, testRegion, name); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v after delete, want ErrNotFound", err)
+	}
+	if _, err := p.GetSecret(ctx, testRegion, name, ""); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v reading a deleted secret, want ErrNotFound", err)
+	}
+}
+
+func TestEndpointStages(t *testing.T) {
+	p := newEndpointProvider(t)
+	ctx := context.Background()
+	name := testName(t)
+	t.Cleanup(func() { p.DeleteSecret(context.Background(), testRegion, name) })
+
+	first := put(t, p, name, "one", nil)
+	second := put(t, p, name, "two", nil)
+	if first.Version == "" || first.Version == second.Version {
+		t.Fatalf("versions %q and %q, want two distinct version ids", first.Version, second.Version)
+	}
+
+	for _, tt := range []struct{ version, want, id string }{
+		{"", "two", second.Version},
+		{StageCurrent, "two", second.Version},
+		{StagePrevious, "one", first.Version},
+		{first.Version, "one", first.Version},
+	} {
+		got, err := p.GetSecret(ctx, testRegion, name, tt.version)
+		if err != nil {
+			t.Fatalf("version %q: %v", tt.version, err)
+		}
+		if got.Value != tt.want || got.Version != tt.id {
+			t.Errorf("version %q = %s at %s, want %s at %s", tt.version, got.Value, got.Version, tt.want, tt.id)
+		}
+	}
+
+	versions, err := p.ListVersions(ctx, testRegion, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, v := range versions {
+		ids = append(ids, v.Version)
+	}
+	if want := []string{first.Version, second.Version}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("versions %v, want %v oldest first", ids, want)
+	}
+	if _, err := p.GetSecret(ctx, testRegion, name, "00000000-0000-0000-0000-000000000000"); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v for an unknown version, want ErrNotFound", err)
+	}
+}
+
+func TestEndpointTags(t *testing.T) {
+	p := newEndpointProvider(t)
+	ctx := context.Background()
+	name := testName(t)
+	t.Cleanup(func() { p.DeleteSecret(context.Background(), testRegion, name) })
+
+	put(t, p, name, "one", map[string]string{"owner": "team-a", "env": "dev"})
+	put(t, p, name, "two", map[string]string{"env": "prod"})
+	meta, err := p.GetMetadata(ctx, testRegion, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"env": "prod"}; !reflect.DeepEqual(meta.Tags, want) {
+		t.Errorf("tags %v, want them replaced by %v", meta.Tags, want)
+	}
+
+	put(t, p, name, "three", nil)
+	if meta, err = p.GetMetadata(ctx, testRegion, name); err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.Tags) != 0 {
+		t.Errorf("tags %v, want all of them removed", meta.Tags)
+	}
+}
+
+func TestEndpointKMSKey(t *testing.T) {
+	p := newEndpointProvider(t)
+	if p.kmsKeyID == "" {
+		t.Skipf("set %s to test the KMS key selection", kmsKeyEnv)
+	}
+	ctx := context.Background()
+	name := testName(t)
+	t.Cleanup(func() { p.DeleteSecret(context.Background(), testRegion, name) })
+
+	put(t, p, name, "one", nil)
+	client, err := p.client(ctx, testRegion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := client.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: sdk.String(name)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the key may be reported as its id or its ARN
+	if got := sdk.ToString(out.KmsKeyId); got == "" || !strings.HasSuffix(got, p.kmsKeyID) && !strings.HasSuffix(p.kmsKeyID, got) {
+		t.Errorf("secret encrypted with %q, want %q", got, p.kmsKeyID)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for window, valid := range map[string]bool{"": true, "0": true, "7": true, "30": true, "6": false, "31": false, "x": false} {
+		_, err := New(providers.Config{"recovery_window_days": window})
+		if (err == nil) != valid {
+			t.Errorf("recovery_window_days %q: got %v, want valid %v", window, err, valid)
+		}
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	p := &AWSSecretProvider{}
+	for name, valid := range map[string]bool{
+		"db-password":     true,
+		"team/app_db+x=@": true,
+		"":                false,
+		"db password":     false,
+		"db:password":     false,
+	} {
+		if err := p.ValidateName(name); (err == nil) != valid {
+			t.Errorf("ValidateName(%q) = %v, want valid %v", name, err, valid)
+		}
+	}
+}
+
+func TestCurrentVersion(t *testing.T) {
+	stages := map[string][]string{
+		"a": {StagePrevious},
+		"b": {"custom", StageCurrent},
+		"c": nil,
+	}
+	if got := currentVersion(stages); got != "b" {
+		t.Errorf("got %q, want b", got)
+	}
+	if got := currentVersion(nil); got != "" {
+		t.Errorf("got %q without versions, want none", got)
+	}
+}
+
+func TestTags(t *testing.T) {
+	tags := map[string]string{"b": "2", "a": "1"}
+	converted := toTags(tags)
+	if len(converted) != 2 || sdk.ToString(converted[0].Key) != "a" || sdk.ToString(converted[1].Key) != "b" {
+		t.Errorf("toTags = %+v, want the tags sorted by key", converted)
+	}
+	if got := fromTags(converted); !reflect.DeepEqual(got, tags) {
+		t.Errorf("fromTags = %v, want %v", got, tags)
+	}
+	if toTags(nil) != nil || fromTags(nil) != nil {
+		t.Error("empty tags were not nil")
+	}
+}
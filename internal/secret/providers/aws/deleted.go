@@ -0,0 +1,61 @@
+package aws
+
+import (
+	"context"
+
+	sdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+// ListDeletedSecrets returns the secrets scheduled for deletion
+func (p *AWSSecretProvider) ListDeletedSecrets(ctx context.Context, store string) ([]providers.DeletedSecret, error) {
+	client, err := p.client(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []providers.DeletedSecret
+	pager := secretsmanager.NewListSecretsPaginator(client, &secretsmanager.ListSecretsInput{IncludePlannedDeletion: sdk.Bool(true)})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError("", err)
+		}
+		for _, entry := range page.SecretList {
+			if entry.DeletedDate == nil {
+				continue
+			}
+			secret := providers.DeletedSecret{
+				SecretProperties: providers.SecretProperties{
+					Name:    sdk.ToString(entry.Name),
+					Version: currentVersion(entry.SecretVersionsToStages),
+					Tags:    fromTags(entry.Tags),
+					Enabled: true,
+					Created: entry.CreatedDate,
+					Updated: entry.LastChangedDate,
+				},
+				DeletedDate: entry.DeletedDate,
+			}
+			// only known for secrets this provider deleted, the recovery window of others was not recorded
+			p.mu.Lock()
+			if purge, ok := p.purgeDates[store+"/"+secret.Name]; ok {
+				secret.ScheduledPurgeDate = &purge
+			}
+			p.mu.Unlock()
+			deleted = append(deleted, secret)
+		}
+	}
+	return deleted, nil
+}
+
+// RecoverDeletedSecret cancels the scheduled deletion of a secret
+func (p *AWSSecretProvider) RecoverDeletedSecret(ctx context.Context, store, name string) error {
+	client, err := p.client(ctx, store)
+	if err != nil {
+		return err
+	}
+	if _, err := client.RestoreSecret(ctx, &secretsmanager.RestoreSecretInput{SecretId: sdk.String(name)}); err != nil {
+		return wrapError(name, err)
+	}
+	return nil
+}
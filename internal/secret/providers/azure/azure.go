@@ -1,23 +1,402 @@
 package azure
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/spf13/cobra"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azsecrets"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azcertificates"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	azureUtils "github.com/hazyforge/hazyctl/pkg/utils"
 )
 
-type AzureSecretProvider struct{}
+// AzureSecretProvider implements providers.SecretProvider on top of Azure Key Vault.
+// Stores are Key Vault names or full vault URLs.
+type AzureSecretProvider struct {
+	credential azcore.TokenCredential
+	// options configure the data plane clients, e.g. their transport
+	options azcore.ClientOptions
 
-func (asp *AzureSecretProvider) MigrateAll(sourceVaultURL, destinationVaultURL string) error {
-	// Implement the migration logic here
-	return nil
+	mu      sync.Mutex
+	clients map[string]*vaultClients
 }
 
-var AzureCmd = &cobra.Command{
-	Use:   "azure",
-	Short: "Azure utilities",
+// vaultClients holds the data plane clients of a single vault
+type vaultClients struct {
+	secrets      *azsecrets.Client
+	certificates *azcertificates.Client
+	keys         *azkeys.Client
 }
 
+// recoverPollInterval is how often a recovering secret is checked for availability
+const recoverPollInterval = 2 * time.Second
+
 func init() {
-	
+	providers.Register("azure", New)
+}
+
+// New creates an Azure Key Vault provider using the default Azure credential chain
+func New(cfg providers.Config) (providers.SecretProvider, error) {
+	client, err := azureUtils.NewAzureClient(cfg.Get("subscription", ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure client: %w", err)
+	}
+	return newProvider(client.Credential, azcore.ClientOptions{}), nil
+}
+
+// sdkRetryStatusCodes are the responses the SDK clients retry themselves. Throttling responses are
+// left to batch.Retry, which honours --max-retries, instead of multiplying both retry loops.
+var sdkRetryStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusGatewayTimeout,
+}
+
+func newProvider(credential azcore.TokenCredential, options azcore.ClientOptions) *AzureSecretProvider {
+	if options.Retry.StatusCodes == nil {
+		options.Retry.StatusCodes = sdkRetryStatusCodes
+	}
+	return &AzureSecretProvider{
+		credential: credential,
+		options:    options,
+		clients:    make(map[string]*vaultClients),
+	}
+}
+
+func (asp *AzureSecretProvider) vault(store string) (*vaultClients, error) {
+	asp.mu.Lock()
+	defer asp.mu.Unlock()
+	if clients, ok := asp.clients[store]; ok {
+		return clients, nil
+	}
+	vaultURL := store
+	if !strings.HasPrefix(store, "https://") {
+		vaultURL = azureUtils.VaultNameToURL(store)
+	}
+	secrets, err := azsecrets.NewClient(vaultURL, asp.credential, &azsecrets.ClientOptions{ClientOptions: asp.options})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret client for %s: %w", store, err)
+	}
+	certificates, err := azcertificates.NewClient(vaultURL, asp.credential, &azcertificates.ClientOptions{ClientOptions: asp.options})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate client for %s: %w", store, err)
+	}
+	keys, err := azkeys.NewClient(vaultURL, asp.credential, &azkeys.ClientOptions{ClientOptions: asp.options})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key client for %s: %w", store, err)
+	}
+	clients := &vaultClients{secrets: secrets, certificates: certificates, keys: keys}
+	asp.clients[store] = clients
+	return clients, nil
+}
+
+func (asp *AzureSecretProvider) secretsClient(store string) (*azsecrets.Client, error) {
+	clients, err := asp.vault(store)
+	if err != nil {
+		return nil, err
+	}
+	return clients.secrets, nil
+}
+
+func (asp *AzureSecretProvider) ListSecrets(ctx context.Context, store string) ([]providers.SecretProperties, error) {
+	client, err := asp.secretsClient(store)
+	if err != nil {
+		return nil, err
+	}
+	var secrets []providers.SecretProperties
+	pager := client.NewListSecretsPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get secrets page: %w", wrapError(store, err))
+		}
+		for _, item := range page.Value {
+			secrets = append(secrets, propertiesFromItem(item))
+		}
+	}
+	return secrets, nil
+}
+
+func (asp *AzureSecretProvider) GetSecret(ctx context.Context, store, name, version string) (*providers.Secret, error) {
+	client, err := asp.secretsClient(store)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.GetSecret(ctx, name, version, nil)
+	if err != nil {
+		return nil, wrapError(name, err)
+	}
+	return secretFromBundle(resp.SecretBundle), nil
+}
+
+func (asp *AzureSecretProvider) PutSecret(ctx context.Context, store string, secret providers.Secret) (*providers.SecretProperties, error) {
+	client, err := asp.secretsClient(store)
+	if err != nil {
+		return nil, err
+	}
+	params := azsecrets.SetSecretParameters{
+		Value: &secret.Value,
+		SecretAttributes: &azsecrets.SecretAttributes{
+			Enabled:   &secret.Enabled,
+			Expires:   secret.Expires,
+			NotBefore: secret.NotBefore,
+		},
+		Tags: toAzureTags(secret.Tags),
+	}
+	if secret.ContentType != "" {
+		params.ContentType = &secret.ContentType
+	}
+	resp, err := client.SetSecret(ctx, secret.Name, params, nil)
+	if err != nil {
+		return nil, wrapError(secret.Name, err)
+	}
+	return &secretFromBundle(resp.SecretBundle).SecretProperties, nil
+}
+
+func (asp *AzureSecretProvider) DeleteSecret(ctx context.Context, store, name string) error {
+	client, err := asp.secretsClient(store)
+	if err != nil {
+		return err
+	}
+	if _, err := client.DeleteSecret(ctx, name, nil); err != nil {
+		return wrapError(name, err)
+	}
+	return nil
+}
+
+func (asp *AzureSecretProvider) ListVersions(ctx context.Context, store, name string) ([]providers.SecretProperties, error) {
+	client, err := asp.secretsClient(store)
+	if err != nil {
+		return nil, err
+	}
+	var versions []providers.SecretProperties
+	pager := client.NewListSecretVersionsPager(name, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError(name, err)
+		}
+		for _, item := range page.Value {
+			versions = append(versions, propertiesFromItem(item))
+		}
+	}
+	return versions, nil
+}
+
+// GetMetadata reads the properties of the newest version from the version list, so it needs no
+// get permission and works on disabled secrets
+func (asp *AzureSecretProvider) GetMetadata(ctx context.Context, store, name string) (*providers.SecretProperties, error) {
+	versions, err := asp.ListVersions(ctx, store, name)
+	if err != nil {
+		return nil, err
+	}
+	current := currentVersion(versions)
+	if current == nil {
+		return nil, fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+	}
+	return current, nil
 }
 
+// currentVersion returns the most recently created version, which Key Vault serves as the current one
+func currentVersion(versions []providers.SecretProperties) *providers.SecretProperties {
+	var current *providers.SecretProperties
+	for i := range versions {
+		v := &versions[i]
+		if current == nil || v.Created != nil && (current.Created == nil || v.Created.After(*current.Created)) {
+			current = v
+		}
+	}
+	return current
+}
+
+func (asp *AzureSecretProvider) ListDeletedSecrets(ctx context.Context, store string) ([]providers.DeletedSecret, error) {
+	client, err := asp.secretsClient(store)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []providers.DeletedSecret
+	pager := client.NewListDeletedSecretsPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get deleted secrets page: %w", wrapError(store, err))
+		}
+		for _, item := range page.Value {
+			secret := providers.DeletedSecret{
+				SecretProperties: propertiesFromItem(&azsecrets.SecretItem{
+					Attributes:  item.Attributes,
+					ContentType: item.ContentType,
+					ID:          item.ID,
+					Tags:        item.Tags,
+					Managed:     item.Managed,
+				}),
+				DeletedDate:        item.DeletedDate,
+				ScheduledPurgeDate: item.ScheduledPurgeDate,
+			}
+			deleted = append(deleted, secret)
+		}
+	}
+	return deleted, nil
+}
+
+func (asp *AzureSecretProvider) RecoverDeletedSecret(ctx context.Context, store, name string) error {
+	client, err := asp.secretsClient(store)
+	if err != nil {
+		return err
+	}
+	if _, err := client.RecoverDeletedSecret(ctx, name, nil); err != nil {
+		return wrapError(name, err)
+	}
+
+	// recovery is asynchronous, wait until the secret can be read again
+	for {
+		_, err := client.GetSecret(ctx, name, "", nil)
+		if err == nil {
+			return nil
+		}
+		werr := wrapError(name, err)
+		if errors.Is(werr, providers.ErrDisabled) {
+			return nil
+		}
+		if !errors.Is(werr, providers.ErrNotFound) {
+			return werr
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(recoverPollInterval):
+		}
+	}
+}
+
+// secretName matches the Key Vault object naming rules
+var secretName = regexp.MustCompile(`^[0-9a-zA-Z-]{1,127}$`)
+
+func (asp *AzureSecretProvider) ValidateName(name string) error {
+	if !secretName.MatchString(name) {
+		return fmt.Errorf("invalid Key Vault secret name %q, names are 1-127 characters of 0-9, a-z, A-Z and -", name)
+	}
+	return nil
+}
+
+// wrapError maps Key Vault not found and throttling responses to the provider errors
+func wrapError(name string, err error) error {
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.StatusCode {
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+		case http.StatusForbidden:
+			// Key Vault answers reads of disabled versions with a Forbidden error mentioning the state
+			if strings.Contains(respErr.Error(), "disabled") {
+				return fmt.Errorf("%w: %s", providers.ErrDisabled, name)
+			}
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return &providers.ThrottledError{RetryAfter: retryAfter(respErr.RawResponse), Err: fmt.Errorf("secret %s: %w", name, err)}
+		}
+	}
+	return fmt.Errorf("secret %s: %w", name, err)
+}
+
+// retryAfter reads the delay Key Vault asks for on throttled responses
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	if ms, err := strconv.Atoi(resp.Header.Get("x-ms-retry-after-ms")); err == nil {
+		return time.Duration(ms) * time.Millisecond
+	}
+	value := resp.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+func propertiesFromItem(item *azsecrets.SecretItem) providers.SecretProperties {
+	props := providers.SecretProperties{
+		Name:    item.ID.Name(),
+		Version: item.ID.Version(),
+		Tags:    fromAzureTags(item.Tags),
+		Managed: item.Managed != nil && *item.Managed,
+	}
+	if item.ContentType != nil {
+		props.ContentType = *item.ContentType
+	}
+	applyAttributes(&props, item.Attributes)
+	return props
+}
+
+func secretFromBundle(bundle azsecrets.SecretBundle) *providers.Secret {
+	secret := &providers.Secret{
+		SecretProperties: providers.SecretProperties{
+			Name:    bundle.ID.Name(),
+			Version: bundle.ID.Version(),
+			Tags:    fromAzureTags(bundle.Tags),
+			Managed: bundle.Managed != nil && *bundle.Managed,
+		},
+	}
+	if bundle.Value != nil {
+		secret.Value = *bundle.Value
+	}
+	if bundle.ContentType != nil {
+		secret.ContentType = *bundle.ContentType
+	}
+	applyAttributes(&secret.SecretProperties, bundle.Attributes)
+	return secret
+}
+
+func applyAttributes(props *providers.SecretProperties, attrs *azsecrets.SecretAttributes) {
+	// Key Vault treats a missing enabled attribute as enabled
+	props.Enabled = true
+	if attrs == nil {
+		return
+	}
+	if attrs.Enabled != nil {
+		props.Enabled = *attrs.Enabled
+	}
+	props.Expires = attrs.Expires
+	props.NotBefore = attrs.NotBefore
+	props.Created = attrs.Created
+	props.Updated = attrs.Updated
+}
+
+func fromAzureTags(tags map[string]*string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(tags))
+	for k, v := range tags {
+		if v != nil {
+			out[k] = *v
+		} else {
+			out[k] = ""
+		}
+	}
+	return out
+}
+
+func toAzureTags(tags map[string]string) map[string]*string {
+	if len(tags) == 0 {
+		return nil
+	}
+	out := make(map[string]*string, len(tags))
+	for k, v := range tags {
+		v := v
+		out[k] = &v
+	}
+	return out
+}
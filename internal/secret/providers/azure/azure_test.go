@@ -0,0 +1,289 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/providers/providertest"
+)
+
+// testStore is the vault the tests talk to, its requests never leave the process
+const testStore = "test"
+
+type fakeCredential struct{}
+
+func (fakeCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// fakeVersion is a secret version as the fake Key Vault stores it
+type fakeVersion struct {
+	ID          string            `json:"id"`
+	Value       string            `json:"value,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Attributes  fakeAttributes    `json:"attributes"`
+}
+
+type fakeAttributes struct {
+	Enabled bool   `json:"enabled"`
+	Expires *int64 `json:"exp,omitempty"`
+	Created int64  `json:"created"`
+	Updated int64  `json:"updated"`
+}
+
+// fakeKeyVault answers the Key Vault secrets REST API from memory, it is used as the transport of
+// the provider so no network is involved
+type fakeKeyVault struct {
+	mu       sync.Mutex
+	secrets  map[string][]fakeVersion
+	serial   int
+	requests []string
+	// throttle is the number of requests answered with 429 Too Many Requests before serving again
+	throttle int
+}
+
+func newTestProvider(t *testing.T) (*AzureSecretProvider, *fakeKeyVault) {
+	t.Helper()
+	kv := &fakeKeyVault{secrets: make(map[string][]fakeVersion)}
+	return newProvider(fakeCredential{}, azcore.ClientOptions{Transport: kv}), kv
+}
+
+func (kv *fakeKeyVault) Do(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	kv.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+func (kv *fakeKeyVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer authorization="https://login.microsoftonline.com/tenant", resource="https://vault.azure.net"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.requests = append(kv.requests, r.Method+" "+r.URL.Path)
+	if kv.throttle > 0 {
+		kv.throttle--
+		w.Header().Set("Retry-After", "0")
+		writeError(w, http.StatusTooManyRequests, "Too many requests")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 1 && parts[0] == "secrets":
+		var items []fakeVersion
+		for name, versions := range kv.secrets {
+			item := versions[len(versions)-1]
+			item.ID, item.Value = kv.url(name, ""), ""
+			items = append(items, item)
+		}
+		writeJSON(w, map[string]any{"value": items})
+	case len(parts) < 2 || parts[0] != "secrets":
+		http.NotFound(w, r)
+	case r.Method == http.MethodPut:
+		var body struct {
+			Value       string            `json:"value"`
+			ContentType string            `json:"contentType"`
+			Tags        map[string]string `json:"tags"`
+			Attributes  fakeAttributes    `json:"attributes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		kv.serial++
+		now := time.Now().Unix() + int64(kv.serial)
+		version := fakeVersion{
+			ID:          kv.url(parts[1], fmt.Sprintf("%032x", kv.serial)),
+			Value:       body.Value,
+			ContentType: body.ContentType,
+			Tags:        body.Tags,
+			Attributes:  fakeAttributes{Enabled: body.Attributes.Enabled, Expires: body.Attributes.Expires, Created: now, Updated: now},
+		}
+		kv.secrets[parts[1]] = append(kv.secrets[parts[1]], version)
+		writeJSON(w, version)
+	case r.Method == http.MethodDelete:
+		versions, ok := kv.secrets[parts[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "SecretNotFound")
+			return
+		}
+		delete(kv.secrets, parts[1])
+		writeJSON(w, versions[len(versions)-1])
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[2] == "versions":
+		versions, ok := kv.secrets[parts[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "SecretNotFound")
+			return
+		}
+		var items []fakeVersion
+		// Key Vault lists versions in no particular order
+		for i := len(versions) - 1; i >= 0; i-- {
+			item := versions[i]
+			item.Value = ""
+			items = append(items, item)
+		}
+		writeJSON(w, map[string]any{"value": items})
+	case r.Method == http.MethodGet:
+		versions, ok := kv.secrets[parts[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "SecretNotFound")
+			return
+		}
+		want := ""
+		if len(parts) == 3 {
+			want = parts[2]
+		}
+		for i := len(versions) - 1; i >= 0; i-- {
+			v := versions[i]
+			if want != "" && !strings.HasSuffix(v.ID, "/"+want) {
+				continue
+			}
+			if !v.Attributes.Enabled {
+				writeError(w, http.StatusForbidden, "Operation get is not allowed on a disabled secret.")
+				return
+			}
+			writeJSON(w, v)
+			return
+		}
+		writeError(w, http.StatusNotFound, "SecretNotFound")
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func (kv *fakeKeyVault) url(name, version string) string {
+	id := "https://" + testStore + ".vault.azure.net/secrets/" + name
+	if version != "" {
+		id += "/" + version
+	}
+	return id
+}
+
+// count returns how many requests matched method and path prefix
+func (kv *fakeKeyVault) count(request string) int {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	n := 0
+	for _, r := range kv.requests {
+		if strings.HasPrefix(r, request) {
+			n++
+		}
+	}
+	return n
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	io.WriteString(w, `{"error":{"code":"`+http.StatusText(status)+`","message":"`+message+`"}}`)
+}
+
+func TestProvider(t *testing.T) {
+	p, _ := newTestProvider(t)
+	providertest.Run(t, p, testStore)
+}
+
+func TestAttributes(t *testing.T) {
+	p, _ := newTestProvider(t)
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	secret := providertest.NewSecret("db-password", "s3cret", map[string]string{"team": "a"})
+	secret.ContentType, secret.Expires = "text/plain", &expires
+	providertest.Put(t, p, testStore, secret)
+
+	got, err := p.GetSecret(context.Background(), testStore, "db-password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ContentType != "text/plain" || got.Expires == nil || !got.Expires.Equal(expires) || got.Created == nil {
+		t.Errorf("got %+v, want the content type and expiry", got)
+	}
+}
+
+func TestGetMetadata(t *testing.T) {
+	p, kv := newTestProvider(t)
+	ctx := context.Background()
+
+	providertest.Put(t, p, testStore, providertest.NewSecret("db", "one", map[string]string{"rev": "1"}))
+	disabled := providertest.NewSecret("db", "two", map[string]string{"rev": "2"})
+	disabled.Enabled = false
+	current := providertest.Put(t, p, testStore, disabled)
+
+	meta, err := p.GetMetadata(ctx, testStore, "db")
+	if err != nil {
+		t.Fatalf("got %v, want the metadata of a disabled secret", err)
+	}
+	if meta.Version != current.Version || meta.Enabled || meta.Tags["rev"] != "2" {
+		t.Errorf("metadata %+v, want the disabled current version", meta)
+	}
+	if n := kv.count("GET /secrets/db/" + current.Version); n != 0 {
+		t.Errorf("the value was read %d times, metadata must not need get permission", n)
+	}
+	if _, err := p.GetMetadata(ctx, testStore, "missing"); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v for a missing secret, want ErrNotFound", err)
+	}
+}
+
+func TestCurrentVersion(t *testing.T) {
+	at := func(sec int64) *time.Time {
+		t := time.Unix(sec, 0)
+		return &t
+	}
+	versions := []providers.SecretProperties{
+		{Version: "b", Created: at(2)},
+		{Version: "c", Created: at(3)},
+		{Version: "a", Created: at(1)},
+	}
+	if got := currentVersion(versions); got == nil || got.Version != "c" {
+		t.Errorf("got %+v, want the newest version", got)
+	}
+	if got := currentVersion(nil); got != nil {
+		t.Errorf("got %+v without versions, want none", got)
+	}
+}
+
+func TestThrottling(t *testing.T) {
+	p, kv := newTestProvider(t)
+	providertest.Put(t, p, testStore, providertest.NewSecret("db", "v", nil))
+
+	kv.throttle = 1
+	before := kv.count("GET /secrets/db")
+	_, err := p.GetSecret(context.Background(), testStore, "db", "")
+	var throttled *providers.ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("got %v, want a ThrottledError", err)
+	}
+	// the SDK must leave throttling to batch.Retry so --max-retries bounds the attempts
+	if n := kv.count("GET /secrets/db") - before; n != 1 {
+		t.Errorf("%d requests for one throttled read, want the SDK not to retry it", n)
+	}
+
+	kv.throttle = 2
+	got, err := batch.Retry(context.Background(), batch.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, func() (*providers.Secret, error) {
+		return p.GetSecret(context.Background(), testStore, "db", "")
+	})
+	if err != nil || got.Value != "v" {
+		t.Errorf("got %+v, %v, want the read to succeed on the third attempt", got, err)
+	}
+}
@@ -0,0 +1,135 @@
+package azure
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azsecrets"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azcertificates"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+func (asp *AzureSecretProvider) BackupSecret(ctx context.Context, store, name string) ([]byte, error) {
+	client, err := asp.secretsClient(store)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.BackupSecret(ctx, name, nil)
+	if err != nil {
+		return nil, wrapError(name, err)
+	}
+	return resp.Value, nil
+}
+
+// RestoreSecret restores a secret backup blob with all its versions, it fails when a secret with the same name exists
+func (asp *AzureSecretProvider) RestoreSecret(ctx context.Context, store string, backup []byte) error {
+	client, err := asp.secretsClient(store)
+	if err != nil {
+		return err
+	}
+	if _, err := client.RestoreSecret(ctx, azsecrets.RestoreSecretParameters{SecretBundleBackup: backup}, nil); err != nil {
+		return wrapError(store, err)
+	}
+	return nil
+}
+
+func (asp *AzureSecretProvider) BackupCertificate(ctx context.Context, store, name string) ([]byte, error) {
+	clients, err := asp.vault(store)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := clients.certificates.BackupCertificate(ctx, name, nil)
+	if err != nil {
+		return nil, wrapError(name, err)
+	}
+	return resp.Value, nil
+}
+
+// RestoreCertificate restores a certificate backup blob together with its key and secret
+func (asp *AzureSecretProvider) RestoreCertificate(ctx context.Context, store string, backup []byte) error {
+	clients, err := asp.vault(store)
+	if err != nil {
+		return err
+	}
+	params := azcertificates.RestoreCertificateParameters{CertificateBackup: backup}
+	if _, err := clients.certificates.RestoreCertificate(ctx, params, nil); err != nil {
+		return wrapError(store, err)
+	}
+	return nil
+}
+
+// BackupObject returns the backup blob of a secret, certificate or key
+func (asp *AzureSecretProvider) BackupObject(ctx context.Context, kind Kind, store, name string) ([]byte, error) {
+	switch kind {
+	case KindCertificates:
+		return asp.BackupCertificate(ctx, store, name)
+	case KindKeys:
+		return asp.BackupKey(ctx, store, name)
+	default:
+		return asp.BackupSecret(ctx, store, name)
+	}
+}
+
+// RestoreObject restores the backup blob of a secret, certificate or key
+func (asp *AzureSecretProvider) RestoreObject(ctx context.Context, kind Kind, store string, backup []byte) error {
+	switch kind {
+	case KindCertificates:
+		return asp.RestoreCertificate(ctx, store, backup)
+	case KindKeys:
+		return asp.RestoreKey(ctx, store, backup)
+	default:
+		return asp.RestoreSecret(ctx, store, backup)
+	}
+}
+
+// ObjectExists reports whether a secret, certificate or key exists in a store, disabled secrets included
+func (asp *AzureSecretProvider) ObjectExists(ctx context.Context, kind Kind, store, name string) (bool, error) {
+	switch kind {
+	case KindCertificates:
+		_, err := asp.GetCertificateThumbprint(ctx, store, name)
+		return exists(err)
+	case KindKeys:
+		return asp.KeyExists(ctx, store, name)
+	default:
+		_, err := asp.GetMetadata(ctx, store, name)
+		if errors.Is(err, providers.ErrDisabled) {
+			return true, nil
+		}
+		return exists(err)
+	}
+}
+
+// ListObjects returns the objects of a kind in a store as secret properties holding their name and tags.
+// Secrets and keys backing a certificate are left out, they are part of the certificate backup.
+func (asp *AzureSecretProvider) ListObjects(ctx context.Context, kind Kind, store string) ([]providers.SecretProperties, error) {
+	var objects []providers.SecretProperties
+	switch kind {
+	case KindCertificates:
+		certificates, err := asp.ListCertificates(ctx, store)
+		if err != nil {
+			return nil, err
+		}
+		for _, cert := range certificates {
+			objects = append(objects, providers.SecretProperties{Name: cert.Name, Tags: cert.Tags})
+		}
+	case KindKeys:
+		keys, err := asp.ListKeys(ctx, store)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			objects = append(objects, providers.SecretProperties{Name: key.Name, Tags: key.Tags})
+		}
+	default:
+		secrets, err := asp.ListSecrets(ctx, store)
+		if err != nil {
+			return nil, err
+		}
+		for _, secret := range secrets {
+			if !secret.Managed {
+				objects = append(objects, secret)
+			}
+		}
+	}
+	return objects, nil
+}
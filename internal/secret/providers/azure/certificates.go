@@ -0,0 +1,167 @@
+package azure
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azcertificates"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+)
+
+// Content types of the secret backing a certificate
+const (
+	ContentTypePKCS12 = "application/x-pkcs12"
+	ContentTypePEM    = "application/x-pem-file"
+)
+
+// Certificate is a Key Vault certificate together with the content of its backing secret
+type Certificate struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// ContentType is ContentTypePKCS12 for base64 encoded PFX content or ContentTypePEM
+	ContentType string            `json:"contentType"`
+	Thumbprint  string            `json:"thumbprint,omitempty"`
+	Enabled     bool              `json:"enabled"`
+	Expires     *time.Time        `json:"expires,omitempty"`
+	NotBefore   *time.Time        `json:"notBefore,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	// Policy is the management policy the certificate is imported with
+	Policy *azcertificates.CertificatePolicy `json:"policy,omitempty"`
+	// Content holds the certificate and its private key as stored in the backing secret
+	Content string `json:"-"`
+}
+
+// CertificateProperties describes a certificate without reading its content
+type CertificateProperties struct {
+	Name       string
+	Thumbprint string
+	Tags       map[string]string
+}
+
+func (asp *AzureSecretProvider) ListCertificates(ctx context.Context, store string) ([]CertificateProperties, error) {
+	clients, err := asp.vault(store)
+	if err != nil {
+		return nil, err
+	}
+	var certificates []CertificateProperties
+	pager := clients.certificates.NewListCertificatePropertiesPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get certificates page: %w", wrapError(store, err))
+		}
+		for _, item := range page.Value {
+			certificates = append(certificates, CertificateProperties{
+				Name:       item.ID.Name(),
+				Thumbprint: hex.EncodeToString(item.X509Thumbprint),
+				Tags:       fromAzureTags(item.Tags),
+			})
+		}
+	}
+	return certificates, nil
+}
+
+// GetCertificate reads the current version of a certificate, its policy and the content of its backing secret.
+// The content only includes the private key when the key is exportable.
+func (asp *AzureSecretProvider) GetCertificate(ctx context.Context, store, name string) (*Certificate, error) {
+	clients, err := asp.vault(store)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := clients.certificates.GetCertificate(ctx, name, "", nil)
+	if err != nil {
+		return nil, wrapError(name, err)
+	}
+	bundle := resp.Certificate
+	cert := &Certificate{
+		Name:       name,
+		Version:    bundle.ID.Version(),
+		Thumbprint: hex.EncodeToString(bundle.X509Thumbprint),
+		Enabled:    true,
+		Tags:       fromAzureTags(bundle.Tags),
+		Policy:     bundle.Policy,
+	}
+	if attrs := bundle.Attributes; attrs != nil {
+		if attrs.Enabled != nil {
+			cert.Enabled = *attrs.Enabled
+		}
+		cert.Expires = attrs.Expires
+		cert.NotBefore = attrs.NotBefore
+	}
+	if cert.Policy != nil {
+		// the id and policy timestamps are read-only and rejected on import
+		cert.Policy.ID = nil
+		cert.Policy.Attributes = nil
+	}
+
+	secret, err := clients.secrets.GetSecret(ctx, name, cert.Version, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backing secret: %w", wrapError(name, err))
+	}
+	if secret.Value != nil {
+		cert.Content = *secret.Value
+		redact.Register(cert.Content)
+	}
+	cert.ContentType = ContentTypePKCS12
+	if secret.ContentType != nil {
+		cert.ContentType = *secret.ContentType
+	}
+	return cert, nil
+}
+
+// ImportCertificate imports cert as a new version of the certificate named cert.Name
+func (asp *AzureSecretProvider) ImportCertificate(ctx context.Context, store string, cert Certificate) error {
+	clients, err := asp.vault(store)
+	if err != nil {
+		return err
+	}
+	policy := cert.Policy
+	if policy == nil {
+		policy = &azcertificates.CertificatePolicy{}
+	}
+	// Key Vault reads the content according to the content type of the policy
+	policy.SecretProperties = &azcertificates.SecretProperties{ContentType: &cert.ContentType}
+	params := azcertificates.ImportCertificateParameters{
+		Base64EncodedCertificate: &cert.Content,
+		CertificateAttributes: &azcertificates.CertificateAttributes{
+			Enabled:   &cert.Enabled,
+			Expires:   cert.Expires,
+			NotBefore: cert.NotBefore,
+		},
+		CertificatePolicy: policy,
+		Tags:              toAzureTags(cert.Tags),
+	}
+	if _, err := clients.certificates.ImportCertificate(ctx, cert.Name, params, nil); err != nil {
+		return wrapError(cert.Name, err)
+	}
+	return nil
+}
+
+// GetCertificateThumbprint returns the hex thumbprint of the current version of a certificate
+func (asp *AzureSecretProvider) GetCertificateThumbprint(ctx context.Context, store, name string) (string, error) {
+	clients, err := asp.vault(store)
+	if err != nil {
+		return "", err
+	}
+	resp, err := clients.certificates.GetCertificate(ctx, name, "", nil)
+	if err != nil {
+		return "", wrapError(name, err)
+	}
+	return hex.EncodeToString(resp.X509Thumbprint), nil
+}
+
+// exists turns a not found error into false
+func exists(err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, providers.ErrNotFound):
+		return false, nil
+	default:
+		return false, err
+	}
+}
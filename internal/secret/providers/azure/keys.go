@@ -0,0 +1,77 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+)
+
+// KeyProperties describes a key without its key material
+type KeyProperties struct {
+	Name string
+	Tags map[string]string
+}
+
+// ListKeys returns the keys of a store, keys backing a certificate are left out
+// because they move together with their certificate
+func (asp *AzureSecretProvider) ListKeys(ctx context.Context, store string) ([]KeyProperties, error) {
+	clients, err := asp.vault(store)
+	if err != nil {
+		return nil, err
+	}
+	var keys []KeyProperties
+	pager := clients.keys.NewListKeyPropertiesPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get keys page: %w", wrapError(store, err))
+		}
+		for _, item := range page.Value {
+			if item.Managed != nil && *item.Managed {
+				continue
+			}
+			keys = append(keys, KeyProperties{Name: item.KID.Name(), Tags: fromAzureTags(item.Tags)})
+		}
+	}
+	return keys, nil
+}
+
+// BackupKey returns the protected backup blob of a key and all its versions.
+// The blob can only be restored into a vault of the same subscription and geography.
+func (asp *AzureSecretProvider) BackupKey(ctx context.Context, store, name string) ([]byte, error) {
+	clients, err := asp.vault(store)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := clients.keys.BackupKey(ctx, name, nil)
+	if err != nil {
+		return nil, wrapError(name, err)
+	}
+	return resp.Value, nil
+}
+
+// RestoreKey restores a key backup blob, it fails when a key with the same name exists
+func (asp *AzureSecretProvider) RestoreKey(ctx context.Context, store string, backup []byte) error {
+	clients, err := asp.vault(store)
+	if err != nil {
+		return err
+	}
+	if _, err := clients.keys.RestoreKey(ctx, azkeys.RestoreKeyParameters{KeyBackup: backup}, nil); err != nil {
+		return wrapError(store, err)
+	}
+	return nil
+}
+
+// KeyExists reports whether a key exists in a store
+func (asp *AzureSecretProvider) KeyExists(ctx context.Context, store, name string) (bool, error) {
+	clients, err := asp.vault(store)
+	if err != nil {
+		return false, err
+	}
+	_, err = clients.keys.GetKey(ctx, name, "", nil)
+	if err != nil {
+		err = wrapError(name, err)
+	}
+	return exists(err)
+}
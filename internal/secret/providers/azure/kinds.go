@@ -0,0 +1,33 @@
+package azure
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind is a type of Key Vault object
+type Kind string
+
+const (
+	KindSecrets      Kind = "secrets"
+	KindCertificates Kind = "certificates"
+	KindKeys         Kind = "keys"
+)
+
+// Kinds lists every supported kind
+var Kinds = []Kind{KindSecrets, KindCertificates, KindKeys}
+
+// ParseKinds validates a list of kind names
+func ParseKinds(values []string) (map[Kind]bool, error) {
+	kinds := make(map[Kind]bool, len(values))
+	for _, value := range values {
+		kind := Kind(strings.TrimSpace(value))
+		switch kind {
+		case KindSecrets, KindCertificates, KindKeys:
+			kinds[kind] = true
+		default:
+			return nil, fmt.Errorf("unknown kind %q, expected one of %v", value, Kinds)
+		}
+	}
+	return kinds, nil
+}
@@ -0,0 +1,63 @@
+package providers
+
+// Capabilities describes which secret metadata a provider can store
+type Capabilities struct {
+	Tags        bool
+	ContentType bool
+	// Expiry covers both the expires and not-before attributes
+	Expiry   bool
+	Disable  bool
+	Versions bool
+}
+
+// CapabilityReporter is implemented by providers that cannot store every kind of metadata
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// CapabilitiesOf returns the capabilities of a provider, assuming full support
+// when the provider does not report them
+func CapabilitiesOf(p SecretProvider) Capabilities {
+	if r, ok := p.(CapabilityReporter); ok {
+		return r.Capabilities()
+	}
+	return Capabilities{Tags: true, ContentType: true, Expiry: true, Disable: true, Versions: true}
+}
+
+// NameValidator is implemented by providers that restrict secret names
+type NameValidator interface {
+	// ValidateName returns an error describing why name cannot be used in the provider
+	ValidateName(name string) error
+}
+
+// StoreNameValidator is implemented by providers whose naming rules depend on the store
+type StoreNameValidator interface {
+	// ValidateStoreName returns an error describing why name cannot be used in store
+	ValidateStoreName(store, name string) error
+}
+
+// ValidateName checks name against the naming rules of p for store, any name is valid for
+// providers without rules
+func ValidateName(p SecretProvider, store, name string) error {
+	if v, ok := p.(StoreNameValidator); ok {
+		return v.ValidateStoreName(store, name)
+	}
+	if v, ok := p.(NameValidator); ok {
+		return v.ValidateName(name)
+	}
+	return nil
+}
+
+// TagTransformer is implemented by providers with stricter tag rules that rewrite incompatible tags
+type TagTransformer interface {
+	// TransformTags returns the tags as the provider stores them and a note for every rewritten tag
+	TransformTags(tags map[string]string) (map[string]string, []string)
+}
+
+// TransformTags rewrites tags for p, they are returned unchanged for providers without rules
+func TransformTags(p SecretProvider, tags map[string]string) (map[string]string, []string) {
+	if t, ok := p.(TagTransformer); ok {
+		return t.TransformTags(tags)
+	}
+	return tags, nil
+}
@@ -0,0 +1,20 @@
+package providers
+
+import (
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// LoadConfig collects every setting under the "<name>." prefix of the global config,
+// e.g. azure.subscription becomes Config{"subscription": ...} for the azure provider
+func LoadConfig(name string) Config {
+	cfg := Config{}
+	prefix := name + "."
+	for _, key := range viper.AllKeys() {
+		if strings.HasPrefix(key, prefix) {
+			cfg[strings.TrimPrefix(key, prefix)] = viper.GetString(key)
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,22 @@
+package providers
+
+import (
+	"context"
+	"time"
+)
+
+// DeletedSecret is a soft-deleted secret that can still be recovered
+type DeletedSecret struct {
+	SecretProperties
+	DeletedDate        *time.Time `json:"deletedDate,omitempty"`
+	ScheduledPurgeDate *time.Time `json:"scheduledPurgeDate,omitempty"`
+}
+
+// DeletedSecretProvider is implemented by providers whose stores soft-delete secrets
+type DeletedSecretProvider interface {
+	// ListDeletedSecrets returns every soft-deleted secret in the store
+	ListDeletedSecrets(ctx context.Context, store string) ([]DeletedSecret, error)
+	// RecoverDeletedSecret restores a soft-deleted secret with all its versions.
+	// It returns once the secret can be read again.
+	RecoverDeletedSecret(ctx context.Context, store, name string) error
+}
@@ -0,0 +1,395 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,255}$`)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// GCPSecretProvider implements providers.SecretProvider on top of Google Secret Manager.
+// Stores are project ids, tags are stored as labels.
+// Credentials come from Application Default Credentials unless credentials_file is set.
+type GCPSecretProvider struct {
+	opts []option.ClientOption
+	// locations replicates new secrets to these locations, empty lets Google choose
+	locations []string
+
+	mu     sync.Mutex
+	client *secretmanager.Client
+}
+
+func init() {
+	providers.Register("gcp", New)
+}
+
+// New creates a Google Secret Manager provider from the gcp config section:
+// credentials_file, endpoint, insecure and replication_locations
+func New(cfg providers.Config) (providers.SecretProvider, error) {
+	var opts []option.ClientOption
+	if file := cfg.Get("credentials_file", ""); file != "" {
+		opts = append(opts, option.WithCredentialsFile(file))
+	}
+	if endpoint := cfg.Get("endpoint", ""); endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint))
+	}
+	// insecure talks plain gRPC without credentials, e.g. to an emulator
+	if plain, err := strconv.ParseBool(cfg.Get("insecure", "false")); err != nil {
+		return nil, fmt.Errorf("invalid insecure %q, expected true or false", cfg.Get("insecure", ""))
+	} else if plain {
+		opts = append(opts, option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	}
+	var locations []string
+	for _, location := range strings.Split(cfg.Get("replication_locations", ""), ",") {
+		if location = strings.TrimSpace(location); location != "" {
+			locations = append(locations, location)
+		}
+	}
+	return &GCPSecretProvider{opts: opts, locations: locations}, nil
+}
+
+// Capabilities reports what Secret Manager can store: labels as tags, disabled versions and versions,
+// but no content type or expiry
+func (p *GCPSecretProvider) Capabilities() providers.Capabilities {
+	return providers.Capabilities{Tags: true, Disable: true, Versions: true}
+}
+
+// ValidateName checks the Secret Manager naming rules
+func (p *GCPSecretProvider) ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid GCP secret name %q, names are 1-255 characters of 0-9, a-z, A-Z, _ and -", name)
+	}
+	return nil
+}
+
+func (p *GCPSecretProvider) getClient(ctx context.Context) (*secretmanager.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client != nil {
+		return p.client, nil
+	}
+	client, err := secretmanager.NewClient(ctx, p.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Secret Manager client: %w", err)
+	}
+	setCallOptions(client)
+	p.client = client
+	return client, nil
+}
+
+// setCallOptions drops ResourceExhausted from the codes the client retries AccessSecretVersion on.
+// Throttled calls are left to batch.Retry, which honours --max-retries, instead of multiplying both retry loops.
+func setCallOptions(client *secretmanager.Client) {
+	client.CallOptions.AccessSecretVersion = []gax.CallOption{
+		gax.WithTimeout(time.Minute),
+		gax.WithRetry(func() gax.Retryer {
+			return gax.OnCodes([]codes.Code{codes.Unavailable}, gax.Backoff{Initial: 2 * time.Second, Max: time.Minute, Multiplier: 2})
+		}),
+	}
+}
+
+func (p *GCPSecretProvider) ListSecrets(ctx context.Context, store string) ([]providers.SecretProperties, error) {
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var items []providers.SecretProperties
+	it := client.ListSecrets(ctx, &secretmanagerpb.ListSecretsRequest{Parent: project(store)})
+	for {
+		secret, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, wrapError("", err)
+		}
+		name := path.Base(secret.Name)
+		version, err := latestVersion(ctx, client, secret.Name)
+		if err = wrapError(name, err); errors.Is(err, providers.ErrNotFound) {
+			// deleted since it was listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, properties(secret, version))
+	}
+	return items, nil
+}
+
+// GetSecret reads a numbered version, an empty version reads the latest one
+func (p *GCPSecretProvider) GetSecret(ctx context.Context, store, name, version string) (*providers.Secret, error) {
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		version = "latest"
+	}
+	out, err := client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{
+		Name: secretName(store, name) + "/versions/" + version,
+	})
+	if err != nil {
+		return nil, wrapError(name, err)
+	}
+	data := out.GetPayload().GetData()
+	if sum := out.GetPayload().DataCrc32C; sum != nil && int64(crc32.Checksum(data, crc32c)) != *sum {
+		return nil, fmt.Errorf("secret %s: payload checksum mismatch", name)
+	}
+	secret, err := client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{Name: secretName(store, name)})
+	if err != nil {
+		return nil, wrapError(name, err)
+	}
+	resolved, err := client.GetSecretVersion(ctx, &secretmanagerpb.GetSecretVersionRequest{Name: out.Name})
+	if err != nil {
+		return nil, wrapError(name, err)
+	}
+	return &providers.Secret{SecretProperties: properties(secret, resolved), Value: string(data)}, nil
+}
+
+// PutSecret adds a new version, creating the secret with the configured replication when it
+// does not exist yet, and replaces its labels
+func (p *GCPSecretProvider) PutSecret(ctx context.Context, store string, secret providers.Secret) (*providers.SecretProperties, error) {
+	if err := p.ValidateName(secret.Name); err != nil {
+		return nil, err
+	}
+	if err := validateLabels(secret.Tags); err != nil {
+		return nil, fmt.Errorf("secret %s: %w", secret.Name, err)
+	}
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name := secretName(store, secret.Name)
+	current, err := client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{Name: name})
+	switch {
+	case errors.Is(wrapError(secret.Name, err), providers.ErrNotFound):
+		_, err = client.CreateSecret(ctx, &secretmanagerpb.CreateSecretRequest{
+			Parent:   project(store),
+			SecretId: secret.Name,
+			Secret:   &secretmanagerpb.Secret{Replication: p.replication(), Labels: secret.Tags},
+		})
+		if err != nil {
+			return nil, wrapError(secret.Name, err)
+		}
+	case err != nil:
+		return nil, wrapError(secret.Name, err)
+	case !equalLabels(current.Labels, secret.Tags):
+		_, err = client.UpdateSecret(ctx, &secretmanagerpb.UpdateSecretRequest{
+			Secret:     &secretmanagerpb.Secret{Name: name, Labels: secret.Tags},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"labels"}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to set labels: %w", wrapError(secret.Name, err))
+		}
+	}
+
+	data := []byte(secret.Value)
+	sum := int64(crc32.Checksum(data, crc32c))
+	version, err := client.AddSecretVersion(ctx, &secretmanagerpb.AddSecretVersionRequest{
+		Parent:  name,
+		Payload: &secretmanagerpb.SecretPayload{Data: data, DataCrc32C: &sum},
+	})
+	if err != nil {
+		return nil, wrapError(secret.Name, err)
+	}
+	if !secret.Enabled {
+		if _, err := client.DisableSecretVersion(ctx, &secretmanagerpb.DisableSecretVersionRequest{Name: version.Name}); err != nil {
+			return nil, fmt.Errorf("failed to disable version: %w", wrapError(secret.Name, err))
+		}
+	}
+	props := secret.SecretProperties
+	props.Version = path.Base(version.Name)
+	props.Created = timeOf(version.CreateTime)
+	return &props, nil
+}
+
+// replication returns automatic replication, or user managed replication to the configured locations
+func (p *GCPSecretProvider) replication() *secretmanagerpb.Replication {
+	if len(p.locations) == 0 {
+		return &secretmanagerpb.Replication{
+			Replication: &secretmanagerpb.Replication_Automatic_{Automatic: &secretmanagerpb.Replication_Automatic{}},
+		}
+	}
+	replicas := make([]*secretmanagerpb.Replication_UserManaged_Replica, 0, len(p.locations))
+	for _, location := range p.locations {
+		replicas = append(replicas, &secretmanagerpb.Replication_UserManaged_Replica{Location: location})
+	}
+	return &secretmanagerpb.Replication{
+		Replication: &secretmanagerpb.Replication_UserManaged_{UserManaged: &secretmanagerpb.Replication_UserManaged{Replicas: replicas}},
+	}
+}
+
+// DeleteSecret deletes a secret and all its versions, Secret Manager cannot recover them
+func (p *GCPSecretProvider) DeleteSecret(ctx context.Context, store, name string) error {
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteSecret(ctx, &secretmanagerpb.DeleteSecretRequest{Name: secretName(store, name)}); err != nil {
+		return wrapError(name, err)
+	}
+	return nil
+}
+
+// ListVersions returns the versions that are not destroyed, oldest first
+func (p *GCPSecretProvider) ListVersions(ctx context.Context, store, name string) ([]providers.SecretProperties, error) {
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{Name: secretName(store, name)})
+	if err != nil {
+		return nil, wrapError(name, err)
+	}
+	var versions []providers.SecretProperties
+	it := client.ListSecretVersions(ctx, &secretmanagerpb.ListSecretVersionsRequest{Parent: secret.Name})
+	for {
+		version, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, wrapError(name, err)
+		}
+		if version.State == secretmanagerpb.SecretVersion_DESTROYED {
+			continue
+		}
+		versions = append(versions, properties(secret, version))
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, _ := strconv.Atoi(versions[i].Version)
+		b, _ := strconv.Atoi(versions[j].Version)
+		return a < b
+	})
+	return versions, nil
+}
+
+func (p *GCPSecretProvider) GetMetadata(ctx context.Context, store, name string) (*providers.SecretProperties, error) {
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{Name: secretName(store, name)})
+	if err != nil {
+		return nil, wrapError(name, err)
+	}
+	version, err := latestVersion(ctx, client, secret.Name)
+	if err != nil {
+		return nil, wrapError(name, err)
+	}
+	props := properties(secret, version)
+	return &props, nil
+}
+
+// latestVersion returns the newest version that is not destroyed, nil when there is none
+func latestVersion(ctx context.Context, client *secretmanager.Client, secret string) (*secretmanagerpb.SecretVersion, error) {
+	it := client.ListSecretVersions(ctx, &secretmanagerpb.ListSecretVersionsRequest{
+		Parent:   secret,
+		Filter:   "NOT state:DESTROYED",
+		PageSize: 1,
+	})
+	// versions are listed newest first
+	version, err := it.Next()
+	if errors.Is(err, iterator.Done) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+func properties(secret *secretmanagerpb.Secret, version *secretmanagerpb.SecretVersion) providers.SecretProperties {
+	props := providers.SecretProperties{
+		Name:    path.Base(secret.Name),
+		Tags:    secret.Labels,
+		Enabled: true,
+		Created: timeOf(secret.CreateTime),
+	}
+	if version != nil {
+		props.Version = path.Base(version.Name)
+		props.Enabled = version.State == secretmanagerpb.SecretVersion_ENABLED
+		props.Updated = timeOf(version.CreateTime)
+	}
+	if len(props.Tags) == 0 {
+		props.Tags = nil
+	}
+	return props
+}
+
+// project returns the resource name of the project a store addresses, stores may be given
+// with or without the projects/ prefix
+func project(store string) string {
+	return "projects/" + strings.TrimPrefix(strings.Trim(store, "/"), "projects/")
+}
+
+func secretName(store, name string) string {
+	return project(store) + "/secrets/" + name
+}
+
+func equalLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
+
+func timeOf(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+// wrapError maps gRPC status codes to provider errors
+func wrapError(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var prefixed error = err
+	if name != "" {
+		prefixed = fmt.Errorf("secret %s: %w", name, err)
+	}
+	switch status.Code(err) {
+	case codes.NotFound:
+		return fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+	case codes.FailedPrecondition:
+		// returned when accessing a disabled or destroyed version
+		return fmt.Errorf("%w: %v", providers.ErrDisabled, prefixed)
+	case codes.ResourceExhausted:
+		return &providers.ThrottledError{Err: prefixed}
+	}
+	return prefixed
+}
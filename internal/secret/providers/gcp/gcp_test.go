@@ -0,0 +1,493 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/providers/providertest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeSecretManager keeps secrets and their versions in memory, versions are numbered from 1
+type fakeSecretManager struct {
+	secretmanagerpb.UnimplementedSecretManagerServiceServer
+
+	mu       sync.Mutex
+	secrets  map[string]*secretmanagerpb.Secret
+	versions map[string][]*secretmanagerpb.SecretVersion
+	payloads map[string][]byte
+	// corrupt makes AccessSecretVersion return a wrong checksum
+	corrupt bool
+	// throttle answers that many AccessSecretVersion calls with ResourceExhausted
+	throttle int
+	accesses int
+}
+
+func (f *fakeSecretManager) ListSecrets(ctx context.Context, req *secretmanagerpb.ListSecretsRequest) (*secretmanagerpb.ListSecretsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := &secretmanagerpb.ListSecretsResponse{}
+	for name, secret := range f.secrets {
+		if strings.HasPrefix(name, req.Parent+"/secrets/") {
+			resp.Secrets = append(resp.Secrets, proto.Clone(secret).(*secretmanagerpb.Secret))
+		}
+	}
+	sort.Slice(resp.Secrets, func(i, j int) bool { return resp.Secrets[i].Name < resp.Secrets[j].Name })
+	return resp, nil
+}
+
+func (f *fakeSecretManager) CreateSecret(ctx context.Context, req *secretmanagerpb.CreateSecretRequest) (*secretmanagerpb.Secret, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := req.Parent + "/secrets/" + req.SecretId
+	if _, ok := f.secrets[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "%s exists", name)
+	}
+	if req.Secret.GetReplication() == nil {
+		return nil, status.Error(codes.InvalidArgument, "replication is required")
+	}
+	secret := proto.Clone(req.Secret).(*secretmanagerpb.Secret)
+	secret.Name, secret.CreateTime = name, timestamppb.Now()
+	f.secrets[name] = secret
+	return secret, nil
+}
+
+func (f *fakeSecretManager) GetSecret(ctx context.Context, req *secretmanagerpb.GetSecretRequest) (*secretmanagerpb.Secret, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	secret, ok := f.secrets[req.Name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Name)
+	}
+	return proto.Clone(secret).(*secretmanagerpb.Secret), nil
+}
+
+func (f *fakeSecretManager) UpdateSecret(ctx context.Context, req *secretmanagerpb.UpdateSecretRequest) (*secretmanagerpb.Secret, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	secret, ok := f.secrets[req.Secret.Name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Secret.Name)
+	}
+	if !reflect.DeepEqual(req.UpdateMask.GetPaths(), []string{"labels"}) {
+		return nil, status.Errorf(codes.InvalidArgument, "unexpected update mask %v", req.UpdateMask.GetPaths())
+	}
+	secret.Labels = req.Secret.Labels
+	return proto.Clone(secret).(*secretmanagerpb.Secret), nil
+}
+
+func (f *fakeSecretManager) DeleteSecret(ctx context.Context, req *secretmanagerpb.DeleteSecretRequest) (*emptypb.Empty, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.secrets[req.Name]; !ok {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Name)
+	}
+	delete(f.secrets, req.Name)
+	delete(f.versions, req.Name)
+	return &emptypb.Empty{}, nil
+}
+
+func (f *fakeSecretManager) AddSecretVersion(ctx context.Context, req *secretmanagerpb.AddSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.secrets[req.Parent]; !ok {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Parent)
+	}
+	data := req.Payload.GetData()
+	if sum := req.Payload.DataCrc32C; sum == nil || *sum != int64(crc32.Checksum(data, crc32c)) {
+		return nil, status.Error(codes.InvalidArgument, "payload checksum mismatch")
+	}
+	version := &secretmanagerpb.SecretVersion{
+		Name:       fmt.Sprintf("%s/versions/%d", req.Parent, len(f.versions[req.Parent])+1),
+		State:      secretmanagerpb.SecretVersion_ENABLED,
+		CreateTime: timestamppb.Now(),
+	}
+	f.versions[req.Parent] = append(f.versions[req.Parent], version)
+	f.payloads[version.Name] = data
+	return proto.Clone(version).(*secretmanagerpb.SecretVersion), nil
+}
+
+// version resolves a version name, latest is the newest version
+func (f *fakeSecretManager) version(name string) (*secretmanagerpb.SecretVersion, error) {
+	secret, number := path.Dir(path.Dir(name)), path.Base(name)
+	versions := f.versions[secret]
+	if number == "latest" && len(versions) > 0 {
+		return versions[len(versions)-1], nil
+	}
+	for _, version := range versions {
+		if version.Name == name {
+			return version, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "%s not found", name)
+}
+
+func (f *fakeSecretManager) GetSecretVersion(ctx context.Context, req *secretmanagerpb.GetSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	version, err := f.version(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(version).(*secretmanagerpb.SecretVersion), nil
+}
+
+func (f *fakeSecretManager) AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accesses++
+	if f.throttle > 0 {
+		f.throttle--
+		return nil, status.Error(codes.ResourceExhausted, "quota exceeded")
+	}
+	version, err := f.version(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if version.State != secretmanagerpb.SecretVersion_ENABLED {
+		return nil, status.Errorf(codes.FailedPrecondition, "%s is in state %s", version.Name, version.State)
+	}
+	data := f.payloads[version.Name]
+	sum := int64(crc32.Checksum(data, crc32c))
+	if f.corrupt {
+		sum++
+	}
+	return &secretmanagerpb.AccessSecretVersionResponse{
+		Name:    version.Name,
+		Payload: &secretmanagerpb.SecretPayload{Data: data, DataCrc32C: &sum},
+	}, nil
+}
+
+// ListSecretVersions lists newest first and supports the one filter the provider sends
+func (f *fakeSecretManager) ListSecretVersions(ctx context.Context, req *secretmanagerpb.ListSecretVersionsRequest) (*secretmanagerpb.ListSecretVersionsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.secrets[req.Parent]; !ok {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Parent)
+	}
+	if req.Filter != "" && req.Filter != "NOT state:DESTROYED" {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported filter %q", req.Filter)
+	}
+	resp := &secretmanagerpb.ListSecretVersionsResponse{}
+	versions := f.versions[req.Parent]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if req.Filter != "" && versions[i].State == secretmanagerpb.SecretVersion_DESTROYED {
+			continue
+		}
+		if req.PageSize > 0 && len(resp.Versions) == int(req.PageSize) {
+			break
+		}
+		resp.Versions = append(resp.Versions, proto.Clone(versions[i]).(*secretmanagerpb.SecretVersion))
+	}
+	return resp, nil
+}
+
+func (f *fakeSecretManager) DisableSecretVersion(ctx context.Context, req *secretmanagerpb.DisableSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	version, err := f.version(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	version.State = secretmanagerpb.SecretVersion_DISABLED
+	return proto.Clone(version).(*secretmanagerpb.SecretVersion), nil
+}
+
+// setState changes the state of a version directly, e.g. to destroy it
+func (f *fakeSecretManager) setState(name string, state secretmanagerpb.SecretVersion_State) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	version, _ := f.version(name)
+	version.State = state
+}
+
+// newTestProvider serves a fake Secret Manager over an in-process connection
+func newTestProvider(t *testing.T, cfg providers.Config) (*GCPSecretProvider, *fakeSecretManager) {
+	t.Helper()
+	fake := &fakeSecretManager{
+		secrets:  make(map[string]*secretmanagerpb.Secret),
+		versions: make(map[string][]*secretmanagerpb.SecretVersion),
+		payloads: make(map[string][]byte),
+	}
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	secretmanagerpb.RegisterSecretManagerServiceServer(server, fake)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := secretmanager.NewClient(context.Background(), option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	setCallOptions(client)
+
+	if cfg == nil {
+		cfg = providers.Config{}
+	}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcp := p.(*GCPSecretProvider)
+	gcp.client = client
+	return gcp, fake
+}
+
+func TestProvider(t *testing.T) {
+	p, _ := newTestProvider(t, nil)
+	providertest.Run(t, p, "proj")
+}
+
+func TestThrottling(t *testing.T) {
+	p, fake := newTestProvider(t, nil)
+	providertest.Put(t, p, "proj", providertest.NewSecret("db", "v", nil))
+
+	// the client must not retry on its own, batch.Retry retries throttled calls
+	fake.mu.Lock()
+	fake.throttle, fake.accesses = 1, 0
+	fake.mu.Unlock()
+	_, err := p.GetSecret(context.Background(), "proj", "db", "")
+	var throttled *providers.ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("got %v, want a ThrottledError", err)
+	}
+	if fake.accesses != 1 {
+		t.Errorf("sent %d requests, want 1", fake.accesses)
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	p, _ := newTestProvider(t, nil)
+	ctx := context.Background()
+
+	props := providertest.Put(t, p, "proj", providertest.NewSecret("db-password", "one", map[string]string{"team": "a"}))
+	if props.Version != "1" || props.Created == nil {
+		t.Errorf("put returned %+v, want version 1 with a creation time", props)
+	}
+	providertest.Put(t, p, "proj", providertest.NewSecret("api-key", "k", nil))
+
+	items, err := p.ListSecrets(ctx, "projects/proj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Name+"@"+item.Version)
+	}
+	if want := []string{"api-key@1", "db-password@1"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("listed %v, want %v", names, want)
+	}
+
+	got, err := p.GetSecret(ctx, "proj", "db-password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "one" || got.Version != "1" || !got.Enabled || got.Tags["team"] != "a" {
+		t.Errorf("got %+v", got)
+	}
+
+	if err := p.DeleteSecret(ctx, "proj", "db-password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetSecret(ctx, "proj", "db-password", ""); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v after delete, want ErrNotFound", err)
+	}
+	if err := p.DeleteSecret(ctx, "proj", "db-password"); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v deleting twice, want ErrNotFound", err)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	p, fake := newTestProvider(t, nil)
+	ctx := context.Background()
+
+	providertest.Put(t, p, "proj", providertest.NewSecret("db", "one", nil))
+	providertest.Put(t, p, "proj", providertest.NewSecret("db", "two", nil))
+	providertest.Put(t, p, "proj", providertest.NewSecret("db", "three", nil))
+	fake.setState("projects/proj/secrets/db/versions/2", secretmanagerpb.SecretVersion_DESTROYED)
+
+	versions, err := p.ListVersions(ctx, "proj", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbers []string
+	for _, v := range versions {
+		numbers = append(numbers, v.Version)
+	}
+	if want := []string{"1", "3"}; !reflect.DeepEqual(numbers, want) {
+		t.Errorf("versions %v, want the ones not destroyed oldest first %v", numbers, want)
+	}
+	old, err := p.GetSecret(ctx, "proj", "db", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.Value != "one" || old.Version != "1" {
+		t.Errorf("version 1 = %+v", old)
+	}
+	if _, err := p.GetSecret(ctx, "proj", "db", "2"); !errors.Is(err, providers.ErrDisabled) {
+		t.Errorf("got %v reading a destroyed version, want ErrDisabled", err)
+	}
+	if _, err := p.GetSecret(ctx, "proj", "db", "9"); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v reading a missing version, want ErrNotFound", err)
+	}
+
+	// the latest version that is not destroyed is the current one
+	fake.setState("projects/proj/secrets/db/versions/3", secretmanagerpb.SecretVersion_DESTROYED)
+	meta, err := p.GetMetadata(ctx, "proj", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Version != "1" {
+		t.Errorf("current version %s, want 1", meta.Version)
+	}
+}
+
+func TestEnableDisable(t *testing.T) {
+	p, _ := newTestProvider(t, nil)
+	ctx := context.Background()
+
+	providertest.Put(t, p, "proj", providertest.NewSecret("db", "one", nil))
+	disabled := providertest.NewSecret("db", "two", nil)
+	disabled.Enabled = false
+	if props := providertest.Put(t, p, "proj", disabled); props.Version != "2" {
+		t.Errorf("disabled put returned version %s, want 2", props.Version)
+	}
+
+	if _, err := p.GetSecret(ctx, "proj", "db", ""); !errors.Is(err, providers.ErrDisabled) {
+		t.Errorf("got %v reading the disabled latest version, want ErrDisabled", err)
+	}
+	meta, err := p.GetMetadata(ctx, "proj", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Version != "2" || meta.Enabled {
+		t.Errorf("metadata %+v, want the disabled version 2", meta)
+	}
+	versions, err := p.ListVersions(ctx, "proj", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || !versions[0].Enabled || versions[1].Enabled {
+		t.Errorf("versions %+v, want 1 enabled and 2 disabled", versions)
+	}
+
+	providertest.Put(t, p, "proj", providertest.NewSecret("db", "three", nil))
+	got, err := p.GetSecret(ctx, "proj", "db", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "three" || !got.Enabled {
+		t.Errorf("got %+v, want the enabled version 3", got)
+	}
+}
+
+func TestReplication(t *testing.T) {
+	tests := []struct {
+		name      string
+		locations string
+		want      *secretmanagerpb.Replication
+	}{
+		{
+			name: "automatic",
+			want: &secretmanagerpb.Replication{Replication: &secretmanagerpb.Replication_Automatic_{Automatic: &secretmanagerpb.Replication_Automatic{}}},
+		},
+		{
+			name:      "user managed",
+			locations: "us-east1, europe-west1",
+			want: &secretmanagerpb.Replication{Replication: &secretmanagerpb.Replication_UserManaged_{UserManaged: &secretmanagerpb.Replication_UserManaged{
+				Replicas: []*secretmanagerpb.Replication_UserManaged_Replica{{Location: "us-east1"}, {Location: "europe-west1"}},
+			}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, fake := newTestProvider(t, providers.Config{"replication_locations": tt.locations})
+			providertest.Put(t, p, "proj", providertest.NewSecret("db", "one", nil))
+			if got := fake.secrets["projects/proj/secrets/db"].Replication; !proto.Equal(got, tt.want) {
+				t.Errorf("replication %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabels(t *testing.T) {
+	p, fake := newTestProvider(t, nil)
+	ctx := context.Background()
+
+	providertest.Put(t, p, "proj", providertest.NewSecret("db", "one", map[string]string{"team": "a", "env": "dev"}))
+	providertest.Put(t, p, "proj", providertest.NewSecret("db", "two", map[string]string{"env": "prod"}))
+	if got, want := fake.secrets["projects/proj/secrets/db"].Labels, map[string]string{"env": "prod"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("labels %v, want them replaced by %v", got, want)
+	}
+	providertest.Put(t, p, "proj", providertest.NewSecret("db", "three", nil))
+	meta, err := p.GetMetadata(ctx, "proj", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Tags != nil {
+		t.Errorf("tags %v, want none", meta.Tags)
+	}
+
+	_, err = p.PutSecret(ctx, "proj", providertest.NewSecret("other", "v", map[string]string{"Team": "a"}))
+	if err == nil || !strings.Contains(err.Error(), "Team") {
+		t.Errorf("got %v, want an error naming the invalid tag", err)
+	}
+	if _, ok := fake.secrets["projects/proj/secrets/other"]; ok {
+		t.Error("a secret with invalid labels was created")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	p, fake := newTestProvider(t, nil)
+	providertest.Put(t, p, "proj", providertest.NewSecret("db", "one", nil))
+	fake.corrupt = true
+	if _, err := p.GetSecret(context.Background(), "proj", "db", ""); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("got %v, want a checksum error", err)
+	}
+}
+
+func TestWrapError(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want error
+	}{
+		{codes.NotFound, providers.ErrNotFound},
+		{codes.FailedPrecondition, providers.ErrDisabled},
+	}
+	for _, tt := range tests {
+		if err := wrapError("db", status.Error(tt.code, "x")); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.code, err, tt.want)
+		}
+	}
+	var throttled *providers.ThrottledError
+	if err := wrapError("db", status.Error(codes.ResourceExhausted, "x")); !errors.As(err, &throttled) {
+		t.Errorf("ResourceExhausted: got %v, want a ThrottledError", err)
+	}
+}
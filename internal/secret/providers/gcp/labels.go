@@ -0,0 +1,105 @@
+package gcp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxLabelLength is the maximum length of label keys and values in characters
+const maxLabelLength = 63
+
+// labelKeyPrefix is prepended to keys that do not start with a lowercase letter
+const labelKeyPrefix = "x_"
+
+// validateLabels checks tags against the Secret Manager label rules: keys start with a lowercase
+// letter, keys and values are at most 63 lowercase letters, digits, _ and -
+func validateLabels(tags map[string]string) error {
+	var invalid []string
+	for k, v := range tags {
+		if labelKey(k) != k || labelValue(v) != v {
+			invalid = append(invalid, k)
+		}
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return fmt.Errorf("tags %s are not valid GCP labels, keys and values are at most 63 lowercase letters, digits, _ and -, keys start with a letter", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// TransformTags rewrites tags into valid labels: characters are lowercased, anything else than
+// letters, digits, _ and - is replaced with _ and keys and values are cut to 63 characters.
+// Tags whose key collides with another tag after rewriting are dropped.
+func (p *GCPSecretProvider) TransformTags(tags map[string]string) (map[string]string, []string) {
+	if len(tags) == 0 {
+		return tags, nil
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	// valid keys first so they keep their value on collisions
+	sort.Slice(keys, func(i, j int) bool {
+		vi, vj := labelKey(keys[i]) == keys[i], labelKey(keys[j]) == keys[j]
+		if vi != vj {
+			return vi
+		}
+		return keys[i] < keys[j]
+	})
+	out := make(map[string]string, len(tags))
+	from := make(map[string]string, len(tags))
+	var notes []string
+	for _, k := range keys {
+		key, value := labelKey(k), labelValue(tags[k])
+		if other, ok := from[key]; ok {
+			notes = append(notes, fmt.Sprintf("tag %s dropped, it collides with tag %s as label %s", k, other, key))
+			continue
+		}
+		out[key], from[key] = value, k
+		switch {
+		case key != k && value != tags[k]:
+			notes = append(notes, fmt.Sprintf("tag %s -> label %s (value rewritten)", k, key))
+		case key != k:
+			notes = append(notes, fmt.Sprintf("tag %s -> label %s", k, key))
+		case value != tags[k]:
+			notes = append(notes, fmt.Sprintf("tag %s value rewritten for label rules", k))
+		}
+	}
+	sort.Strings(notes)
+	return out, notes
+}
+
+func labelKey(k string) string {
+	key := labelValue(k)
+	if r, _ := utf8.DecodeRuneInString(key); !unicode.IsLower(r) && !isOtherLetter(r) {
+		key = truncate(labelKeyPrefix + key)
+	}
+	return key
+}
+
+func labelValue(v string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(v) {
+		if unicode.IsLower(r) || isOtherLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return truncate(b.String())
+}
+
+// isOtherLetter reports letters without case, which labels allow as international characters
+func isOtherLetter(r rune) bool {
+	return unicode.Is(unicode.Lo, r)
+}
+
+func truncate(s string) string {
+	if utf8.RuneCountInString(s) <= maxLabelLength {
+		return s
+	}
+	return string([]rune(s)[:maxLabelLength])
+}
@@ -0,0 +1,132 @@
+package gcp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    map[string]string
+		invalid string
+	}{
+		{name: "none"},
+		{name: "valid", tags: map[string]string{"team": "a-1", "cost_center": "", "équipe": "données"}},
+		{name: "upper case key", tags: map[string]string{"Team": "a"}, invalid: "Team"},
+		{name: "upper case value", tags: map[string]string{"team": "A"}, invalid: "team"},
+		{name: "digit first", tags: map[string]string{"1team": "a"}, invalid: "1team"},
+		{name: "dot", tags: map[string]string{"app.kubernetes.io": "x"}, invalid: "app.kubernetes.io"},
+		{name: "long value", tags: map[string]string{"team": strings.Repeat("a", 64)}, invalid: "team"},
+		{name: "sorted", tags: map[string]string{"b.x": "1", "a.x": "1", "ok": "1"}, invalid: "a.x, b.x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLabels(tt.tags)
+			if tt.invalid == "" {
+				if err != nil {
+					t.Errorf("got %v, want valid labels", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "tags "+tt.invalid+" are not valid") {
+				t.Errorf("got %v, want %s reported as invalid", err, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestTransformTags(t *testing.T) {
+	long := strings.Repeat("a", 70)
+	tests := []struct {
+		name  string
+		tags  map[string]string
+		want  map[string]string
+		notes []string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "valid",
+			tags: map[string]string{"team": "a"},
+			want: map[string]string{"team": "a"},
+		},
+		{
+			name:  "key rewritten",
+			tags:  map[string]string{"Cost.Center": "x"},
+			want:  map[string]string{"cost_center": "x"},
+			notes: []string{"tag Cost.Center -> label cost_center"},
+		},
+		{
+			name:  "key and value rewritten",
+			tags:  map[string]string{"Owner": "Jane Doe"},
+			want:  map[string]string{"owner": "jane_doe"},
+			notes: []string{"tag Owner -> label owner (value rewritten)"},
+		},
+		{
+			name:  "value rewritten",
+			tags:  map[string]string{"url": "https://x.io"},
+			want:  map[string]string{"url": "https___x_io"},
+			notes: []string{"tag url value rewritten for label rules"},
+		},
+		{
+			name:  "prefixed key",
+			tags:  map[string]string{"1st": "a", "_x": "b"},
+			want:  map[string]string{"x_1st": "a", "x__x": "b"},
+			notes: []string{"tag 1st -> label x_1st", "tag _x -> label x__x"},
+		},
+		{
+			name:  "truncated",
+			tags:  map[string]string{long: long},
+			want:  map[string]string{long[:63]: long[:63]},
+			notes: []string{"tag " + long + " -> label " + long[:63] + " (value rewritten)"},
+		},
+		{
+			name:  "truncated after prefix",
+			tags:  map[string]string{"9" + long: "v"},
+			want:  map[string]string{"x_9" + long[:60]: "v"},
+			notes: []string{"tag 9" + long + " -> label x_9" + long[:60]},
+		},
+		{
+			name:  "collision keeps the valid key",
+			tags:  map[string]string{"Team": "upper", "team": "lower"},
+			want:  map[string]string{"team": "lower"},
+			notes: []string{"tag Team dropped, it collides with tag team as label team"},
+		},
+		{
+			name: "collision between rewritten keys keeps the first in order",
+			tags: map[string]string{"TEAM": "1", "Team": "2", "team.": "3"},
+			want: map[string]string{"team": "1", "team_": "3"},
+			notes: []string{
+				"tag TEAM -> label team",
+				"tag Team dropped, it collides with tag TEAM as label team",
+				"tag team. -> label team_",
+			},
+		},
+		{
+			name:  "collision after truncation",
+			tags:  map[string]string{long + "x": "1", long + "y": "2"},
+			want:  map[string]string{long[:63]: "1"},
+			notes: []string{"tag " + long + "x -> label " + long[:63], "tag " + long + "y dropped, it collides with tag " + long + "x as label " + long[:63]},
+		},
+	}
+	p := &GCPSecretProvider{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, notes := p.TransformTags(tt.tags)
+			if len(got) != 0 || len(tt.want) != 0 {
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("labels %v, want %v", got, tt.want)
+				}
+			}
+			if !reflect.DeepEqual(notes, tt.notes) {
+				t.Errorf("notes %q, want %q", notes, tt.notes)
+			}
+			if err := validateLabels(got); err != nil {
+				t.Errorf("transformed labels are invalid: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,515 @@
+package k8
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
+)
+
+const contentTypeJSON = "application/json"
+
+// valueKey is the data key holding the value of secrets stored in a Secret of their own
+const valueKey = "value"
+
+// metadataAnnotation holds the content type, tags, expiry and timestamps of the secrets in a
+// Secret as a JSON object keyed by secret name, Kubernetes has no fields for them
+const metadataAnnotation = "hazyctl.io/metadata"
+
+// managedByLabel marks the Secrets created by hazyctl, only they are listed
+const (
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "hazyctl"
+)
+
+// K8SecretProvider implements providers.SecretProvider on top of Kubernetes Secrets.
+// Stores are namespaces of a kubeconfig context written as [<context>:]<namespace>, where every
+// secret is an Opaque Secret of its own: a single "value" key becomes the secret value, Secrets
+// with other keys are read and written as a JSON object. A store written as
+// [<context>:]<namespace>/<secret> keeps every secret as a key of that one Secret instead.
+type K8SecretProvider struct {
+	loadingRules *clientcmd.ClientConfigLoadingRules
+	// context is used by stores without one, empty uses the current context of the kubeconfig
+	context string
+
+	mu       sync.Mutex
+	clusters map[string]*cluster
+	// locks serializes the writes to aggregated Secrets, conflicts with other processes are retried
+	locks map[string]*sync.Mutex
+}
+
+type cluster struct {
+	client kubernetes.Interface
+	// namespace is the namespace of the context, used by stores without one
+	namespace string
+}
+
+// target is a parsed store together with the Secrets client of its namespace
+type target struct {
+	store
+	secrets typedcorev1.SecretInterface
+}
+
+// metadata is what the metadata annotation keeps for a single secret
+type metadata struct {
+	ContentType string            `json:"contentType,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Expires     *time.Time        `json:"expires,omitempty"`
+	NotBefore   *time.Time        `json:"notBefore,omitempty"`
+	Created     *time.Time        `json:"created,omitempty"`
+	Updated     *time.Time        `json:"updated,omitempty"`
+}
+
+func init() {
+	providers.Register("k8", New)
+}
+
+// New creates a Kubernetes Secrets provider from the k8 config section: kubeconfig, which
+// defaults to KUBECONFIG or ~/.kube/config, and context
+func New(cfg providers.Config) (providers.SecretProvider, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = cfg.Get("kubeconfig", "")
+	return &K8SecretProvider{
+		loadingRules: rules,
+		context:      cfg.Get("context", ""),
+		clusters:     make(map[string]*cluster),
+		locks:        make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// Capabilities reports what the provider can store: tags, content type and expiry are kept in
+// an annotation, Kubernetes has neither versions nor a disabled state
+func (p *K8SecretProvider) Capabilities() providers.Capabilities {
+	return providers.Capabilities{Tags: true, ContentType: true, Expiry: true}
+}
+
+// ValidateName checks the rules of Secret data keys, which every secret name must follow
+func (p *K8SecretProvider) ValidateName(name string) error {
+	if errs := validation.IsConfigMapKey(name); len(errs) > 0 {
+		return fmt.Errorf("invalid Kubernetes secret name %q: %s", name, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// ValidateStoreName also checks the rules of Secret names for stores keeping every secret in a
+// Secret of its own, so names such as DbPassword are rejected when planning
+func (p *K8SecretProvider) ValidateStoreName(store, name string) error {
+	if err := p.ValidateName(name); err != nil {
+		return err
+	}
+	st, err := parseStore(store)
+	if err != nil {
+		return err
+	}
+	return st.validateName(name)
+}
+
+func (p *K8SecretProvider) target(name string) (*target, error) {
+	st, err := parseStore(name)
+	if err != nil {
+		return nil, err
+	}
+	if st.context == "" {
+		st.context = p.context
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.clusters[st.context]
+	if !ok {
+		config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(p.loadingRules, &clientcmd.ConfigOverrides{CurrentContext: st.context})
+		restConfig, err := config.ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+		}
+		namespace, _, err := config.Namespace()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+		}
+		client, err := newClient(restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+		}
+		c = &cluster{client: client, namespace: namespace}
+		p.clusters[st.context] = c
+	}
+	if st.namespace == "" {
+		st.namespace = c.namespace
+	}
+	return &target{store: st, secrets: c.client.CoreV1().Secrets(st.namespace)}, nil
+}
+
+// newClient returns a client that leaves throttled requests to batch.Retry, client-go retries
+// responses with a Retry-After header on its own, which would multiply both retry loops
+func newClient(config *rest.Config) (kubernetes.Interface, error) {
+	config = rest.CopyConfig(config)
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper { return throttleTransport{rt} })
+	return kubernetes.NewForConfig(config)
+}
+
+// throttleTransport drops the Retry-After header of throttled responses so client-go returns
+// them at once, the Status in the body still carries the delay for wrapError
+type throttleTransport struct {
+	http.RoundTripper
+}
+
+func (t throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		resp.Header.Del("Retry-After")
+	}
+	return resp, err
+}
+
+// lock serializes writes to the aggregated Secret of t, it returns a no-op for other stores
+func (p *K8SecretProvider) lock(t *target) func() {
+	if t.aggregate == "" {
+		return func() {}
+	}
+	key := t.context + ":" + t.namespace + "/" + t.aggregate
+	p.mu.Lock()
+	l, ok := p.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		p.locks[key] = l
+	}
+	p.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// secretName returns the Kubernetes Secret holding a secret
+func (t *target) secretName(name string) string {
+	if t.aggregate != "" {
+		return t.aggregate
+	}
+	return name
+}
+
+// ListSecrets returns the Opaque Secrets of the namespace created by hazyctl, or the keys of the
+// aggregated Secret
+func (p *K8SecretProvider) ListSecrets(ctx context.Context, store string) ([]providers.SecretProperties, error) {
+	t, err := p.target(store)
+	if err != nil {
+		return nil, err
+	}
+	if t.aggregate != "" {
+		sec, err := t.secrets.Get(ctx, t.aggregate, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, wrapError(t.aggregate, err)
+		}
+		items := make([]providers.SecretProperties, 0, len(sec.Data))
+		for key := range sec.Data {
+			secret, err := t.read(sec, key)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, secret.SecretProperties)
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+		return items, nil
+	}
+
+	// only Secrets created by hazyctl, so a prune never deletes Secrets managed by something else
+	var items []providers.SecretProperties
+	opts := metav1.ListOptions{Limit: 500, LabelSelector: managedByLabel + "=" + managedByValue}
+	for {
+		list, err := t.secrets.List(ctx, opts)
+		if err != nil {
+			return nil, wrapError("", err)
+		}
+		for i := range list.Items {
+			if !isOpaque(&list.Items[i]) {
+				continue
+			}
+			secret, err := t.read(&list.Items[i], list.Items[i].Name)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, secret.SecretProperties)
+		}
+		if list.Continue == "" {
+			return items, nil
+		}
+		opts.Continue = list.Continue
+	}
+}
+
+// GetSecret reads a secret, the only version is the resource version of its Secret
+func (p *K8SecretProvider) GetSecret(ctx context.Context, store, name, version string) (*providers.Secret, error) {
+	t, err := p.target(store)
+	if err != nil {
+		return nil, err
+	}
+	sec, err := t.secrets.Get(ctx, t.secretName(name), metav1.GetOptions{})
+	if err != nil {
+		return nil, wrapError(name, err)
+	}
+	if version != "" && version != sec.ResourceVersion {
+		return nil, fmt.Errorf("%w: %s version %s, Kubernetes only keeps the current version", providers.ErrNotFound, name, version)
+	}
+	return t.read(sec, name)
+}
+
+// read extracts a secret from the Secret holding it
+func (t *target) read(sec *corev1.Secret, name string) (*providers.Secret, error) {
+	meta := readMetadata(sec)[name]
+	var value string
+	if t.aggregate != "" {
+		data, ok := sec.Data[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+		}
+		value = string(data)
+	} else {
+		var contentType string
+		var err error
+		if value, contentType, err = decodeValue(sec.Data); err != nil {
+			return nil, fmt.Errorf("secret %s: %w", name, err)
+		}
+		if meta.ContentType == "" {
+			meta.ContentType = contentType
+		}
+	}
+	if meta.Created == nil && !sec.CreationTimestamp.IsZero() {
+		created := sec.CreationTimestamp.Time
+		meta.Created = &created
+	}
+	return &providers.Secret{
+		SecretProperties: providers.SecretProperties{
+			Name:        name,
+			Version:     sec.ResourceVersion,
+			ContentType: meta.ContentType,
+			Tags:        meta.Tags,
+			Enabled:     true,
+			Expires:     meta.Expires,
+			NotBefore:   meta.NotBefore,
+			Created:     meta.Created,
+			Updated:     meta.Updated,
+		},
+		Value: value,
+	}, nil
+}
+
+// PutSecret writes a secret, creating its Secret when it does not exist yet. Writes to a Secret
+// changed concurrently by another client are retried.
+func (p *K8SecretProvider) PutSecret(ctx context.Context, store string, secret providers.Secret) (*providers.SecretProperties, error) {
+	if err := p.ValidateName(secret.Name); err != nil {
+		return nil, err
+	}
+	t, err := p.target(store)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.validateName(secret.Name); err != nil {
+		return nil, err
+	}
+	defer p.lock(t)()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	meta := metadata{
+		ContentType: secret.ContentType,
+		Tags:        secret.Tags,
+		Expires:     secret.Expires,
+		NotBefore:   secret.NotBefore,
+		Created:     &now,
+		Updated:     &now,
+	}
+	var written *corev1.Secret
+	conflict := func(err error) bool { return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) }
+	err = retry.OnError(retry.DefaultRetry, conflict, func() error {
+		name := t.secretName(secret.Name)
+		sec, err := t.secrets.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			sec = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: t.namespace, Labels: map[string]string{managedByLabel: managedByValue}},
+				Type:       corev1.SecretTypeOpaque,
+			}
+			if err := t.write(sec, secret, meta); err != nil {
+				return err
+			}
+			written, err = t.secrets.Create(ctx, sec, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if !isOpaque(sec) {
+			return fmt.Errorf("Kubernetes Secret %s has type %s, only Opaque Secrets are written", name, sec.Type)
+		}
+		if err := t.write(sec, secret, meta); err != nil {
+			return err
+		}
+		written, err = t.secrets.Update(ctx, sec, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, wrapError(secret.Name, err)
+	}
+	out, err := t.read(written, secret.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &out.SecretProperties, nil
+}
+
+// write stores a secret and its metadata in sec, keeping the creation time of an existing secret
+func (t *target) write(sec *corev1.Secret, secret providers.Secret, meta metadata) error {
+	all := readMetadata(sec)
+	if previous, ok := all[secret.Name]; ok && previous.Created != nil {
+		meta.Created = previous.Created
+	}
+	if t.aggregate != "" {
+		if sec.Data == nil {
+			sec.Data = make(map[string][]byte)
+		}
+		sec.Data[secret.Name] = []byte(secret.Value)
+	} else {
+		sec.Data = encodeValue(secret)
+		sec.StringData = nil
+	}
+	all[secret.Name] = meta
+	return writeMetadata(sec, all)
+}
+
+// DeleteSecret deletes the Secret of a secret, or removes its key from the aggregated Secret
+func (p *K8SecretProvider) DeleteSecret(ctx context.Context, store, name string) error {
+	t, err := p.target(store)
+	if err != nil {
+		return err
+	}
+	if t.aggregate == "" {
+		return wrapError(name, t.secrets.Delete(ctx, name, metav1.DeleteOptions{}))
+	}
+	defer p.lock(t)()
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		sec, err := t.secrets.Get(ctx, t.aggregate, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if _, ok := sec.Data[name]; !ok {
+			return fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+		}
+		delete(sec.Data, name)
+		all := readMetadata(sec)
+		delete(all, name)
+		if err := writeMetadata(sec, all); err != nil {
+			return err
+		}
+		_, err = t.secrets.Update(ctx, sec, metav1.UpdateOptions{})
+		return err
+	})
+	return wrapError(name, err)
+}
+
+// ListVersions returns the current version, Kubernetes keeps no history
+func (p *K8SecretProvider) ListVersions(ctx context.Context, store, name string) ([]providers.SecretProperties, error) {
+	props, err := p.GetMetadata(ctx, store, name)
+	if err != nil {
+		return nil, err
+	}
+	return []providers.SecretProperties{*props}, nil
+}
+
+func (p *K8SecretProvider) GetMetadata(ctx context.Context, store, name string) (*providers.SecretProperties, error) {
+	secret, err := p.GetSecret(ctx, store, name, "")
+	if err != nil {
+		return nil, err
+	}
+	return &secret.SecretProperties, nil
+}
+
+func isOpaque(sec *corev1.Secret) bool {
+	return sec.Type == corev1.SecretTypeOpaque || sec.Type == ""
+}
+
+// readMetadata returns the metadata annotation of sec, an unreadable annotation is ignored
+func readMetadata(sec *corev1.Secret) map[string]metadata {
+	all := make(map[string]metadata)
+	if raw, ok := sec.Annotations[metadataAnnotation]; ok {
+		_ = json.Unmarshal([]byte(raw), &all)
+	}
+	return all
+}
+
+func writeMetadata(sec *corev1.Secret, all map[string]metadata) error {
+	encoded, err := json.Marshal(all)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	if sec.Annotations == nil {
+		sec.Annotations = make(map[string]string)
+	}
+	sec.Annotations[metadataAnnotation] = string(encoded)
+	return nil
+}
+
+// decodeValue turns Secret data into a secret value, Secrets with other keys than "value"
+// become a JSON object
+func decodeValue(data map[string][]byte) (value, contentType string, err error) {
+	if v, ok := data[valueKey]; ok && len(data) == 1 {
+		return string(v), "", nil
+	}
+	object := make(map[string]string, len(data))
+	for k, v := range data {
+		object[k] = string(v)
+	}
+	encoded, err := json.Marshal(object)
+	if err != nil {
+		return "", "", err
+	}
+	return string(encoded), contentTypeJSON, nil
+}
+
+// encodeValue turns a secret value into Secret data, JSON objects of strings are written as their keys
+func encodeValue(secret providers.Secret) map[string][]byte {
+	if secret.ContentType == contentTypeJSON {
+		var object map[string]string
+		if err := json.Unmarshal([]byte(secret.Value), &object); err == nil && len(object) > 0 {
+			data := make(map[string][]byte, len(object))
+			for k, v := range object {
+				data[k] = []byte(v)
+			}
+			return data
+		}
+	}
+	return map[string][]byte{valueKey: []byte(secret.Value)}
+}
+
+// wrapError maps Kubernetes API errors to provider errors
+func wrapError(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case errors.Is(err, providers.ErrNotFound):
+		return err
+	case apierrors.IsNotFound(err):
+		return fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+	case apierrors.IsTooManyRequests(err):
+		delay, _ := apierrors.SuggestsClientDelay(err)
+		return &providers.ThrottledError{RetryAfter: time.Duration(delay) * time.Second, Err: fmt.Errorf("secret %s: %w", name, err)}
+	}
+	if name == "" {
+		return err
+	}
+	return fmt.Errorf("secret %s: %w", name, err)
+}
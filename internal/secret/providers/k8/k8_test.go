@@ -0,0 +1,378 @@
+package k8
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/providers/providertest"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTestProvider returns a provider whose default context talks to a fake clientset holding objects
+func newTestProvider(t *testing.T, objects ...runtime.Object) (*K8SecretProvider, *fake.Clientset) {
+	t.Helper()
+	p, err := New(providers.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleClientset(objects...)
+	k := p.(*K8SecretProvider)
+	k.clusters[""] = &cluster{client: client, namespace: "default"}
+	return k, client
+}
+
+func getSecret(t *testing.T, client *fake.Clientset, namespace, name string) *corev1.Secret {
+	t.Helper()
+	sec, err := client.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sec
+}
+
+func TestParseStore(t *testing.T) {
+	tests := []struct {
+		in   string
+		want store
+		err  bool
+	}{
+		{in: "apps", want: store{namespace: "apps"}},
+		{in: "", want: store{}},
+		{in: "prod:apps", want: store{context: "prod", namespace: "apps"}},
+		{in: "prod:", want: store{context: "prod"}},
+		{in: "apps/app-secrets", want: store{namespace: "apps", aggregate: "app-secrets"}},
+		{in: "arn:aws:eks:eu-west-1:123:cluster/prod:apps/bundle", want: store{context: "arn:aws:eks:eu-west-1:123:cluster/prod", namespace: "apps", aggregate: "bundle"}},
+		{in: "arn:aws:eks:eu-west-1:123:cluster/prod:apps", want: store{context: "arn:aws:eks:eu-west-1:123:cluster/prod", namespace: "apps"}},
+		{in: "arn:aws:eks:eu-west-1:123:cluster/prod", want: store{context: "arn:aws:eks:eu-west-1:123:cluster/prod"}},
+		{in: "Apps", err: true},
+		{in: "prod:apps_1", err: true},
+		{in: "apps/", err: true},
+		{in: "apps/Bundle", err: true},
+		{in: "apps/a/b", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseStore(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("parseStore(%q) error = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseStore(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeDecodeValue(t *testing.T) {
+	tests := []struct {
+		name        string
+		secret      providers.Secret
+		data        map[string][]byte
+		value       string
+		contentType string
+	}{
+		{
+			name:   "plain",
+			secret: providers.Secret{Value: "s3cret"},
+			data:   map[string][]byte{"value": []byte("s3cret")},
+			value:  "s3cret",
+		},
+		{
+			name:        "json object",
+			secret:      providers.Secret{SecretProperties: providers.SecretProperties{ContentType: contentTypeJSON}, Value: `{"password":"p","user":"u"}`},
+			data:        map[string][]byte{"password": []byte("p"), "user": []byte("u")},
+			value:       `{"password":"p","user":"u"}`,
+			contentType: contentTypeJSON,
+		},
+		{
+			name:        "json that is not an object of strings",
+			secret:      providers.Secret{SecretProperties: providers.SecretProperties{ContentType: contentTypeJSON}, Value: `{"port":5432}`},
+			data:        map[string][]byte{"value": []byte(`{"port":5432}`)},
+			value:       `{"port":5432}`,
+			contentType: "",
+		},
+		{
+			name:   "json object without the content type",
+			secret: providers.Secret{Value: `{"user":"u"}`},
+			data:   map[string][]byte{"value": []byte(`{"user":"u"}`)},
+			value:  `{"user":"u"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeValue(tt.secret)
+			if !reflect.DeepEqual(data, tt.data) {
+				t.Errorf("encodeValue = %q, want %q", data, tt.data)
+			}
+			value, contentType, err := decodeValue(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != tt.value || contentType != tt.contentType {
+				t.Errorf("decodeValue = %q, %q, want %q, %q", value, contentType, tt.value, tt.contentType)
+			}
+		})
+	}
+
+	// Secrets written by other tools with several keys read as a JSON object
+	value, contentType, err := decodeValue(map[string][]byte{"value": []byte("v"), "other": []byte("o")})
+	if err != nil || value != `{"other":"o","value":"v"}` || contentType != contentTypeJSON {
+		t.Errorf("decodeValue of two keys = %q, %q, %v", value, contentType, err)
+	}
+}
+
+func TestValidateStoreName(t *testing.T) {
+	p, _ := newTestProvider(t)
+	tests := []struct {
+		store, name string
+		valid       bool
+	}{
+		{"apps", "db-password", true},
+		{"apps", "db.password", true},
+		{"apps", "DbPassword", false},
+		{"apps", "db_password", false},
+		{"apps/bundle", "DbPassword", true},
+		{"apps/bundle", "DB_PASSWORD", true},
+		{"apps/bundle", "db password", false},
+		{"Apps", "db", false},
+	}
+	for _, tt := range tests {
+		err := providers.ValidateName(p, tt.store, tt.name)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateName(%q, %q) = %v, want valid %v", tt.store, tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestProvider(t *testing.T) {
+	for _, store := range []string{"apps", "apps/bundle"} {
+		t.Run(store, func(t *testing.T) {
+			p, _ := newTestProvider(t)
+			providertest.Run(t, p, store)
+		})
+	}
+}
+
+func TestPerSecretStore(t *testing.T) {
+	unmanaged := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "apps"},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"value": []byte("x")},
+	}
+	tls := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "apps", Labels: map[string]string{managedByLabel: managedByValue}},
+		Type:       corev1.SecretTypeTLS,
+	}
+	p, client := newTestProvider(t, unmanaged, tls)
+	ctx := context.Background()
+
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	secret := providers.Secret{
+		SecretProperties: providers.SecretProperties{
+			Name:        "db-password",
+			ContentType: "text/plain",
+			Tags:        map[string]string{"team": "a"},
+			Enabled:     true,
+			Expires:     &expires,
+		},
+		Value: "s3cret",
+	}
+	if _, err := p.PutSecret(ctx, "apps", secret); err != nil {
+		t.Fatal(err)
+	}
+	sec := getSecret(t, client, "apps", "db-password")
+	if sec.Labels[managedByLabel] != managedByValue || sec.Type != corev1.SecretTypeOpaque || string(sec.Data["value"]) != "s3cret" {
+		t.Errorf("created Secret %+v", sec)
+	}
+	if _, err := p.PutSecret(ctx, "apps", providers.Secret{SecretProperties: providers.SecretProperties{Name: "DbPassword"}}); err == nil {
+		t.Error("an invalid Secret name was written")
+	}
+
+	got, err := p.GetSecret(ctx, "apps", "db-password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "s3cret" || got.ContentType != "text/plain" || got.Tags["team"] != "a" || got.Expires == nil || !got.Expires.Equal(expires) || got.Created == nil {
+		t.Errorf("got %+v", got)
+	}
+
+	items, err := p.ListSecrets(ctx, "apps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "db-password" {
+		t.Errorf("listed %+v, want only the Opaque Secret created by hazyctl", items)
+	}
+
+	// unmanaged Secrets are still readable by name
+	if got, err := p.GetSecret(ctx, "apps", "other", ""); err != nil || got.Value != "x" {
+		t.Errorf("got %+v, %v for an unmanaged Secret", got, err)
+	}
+	if _, err := p.PutSecret(ctx, "apps", providers.Secret{SecretProperties: providers.SecretProperties{Name: "tls"}, Value: "v"}); err == nil {
+		t.Error("a TLS Secret was overwritten")
+	}
+
+	if err := p.DeleteSecret(ctx, "apps", "db-password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetSecret(ctx, "apps", "db-password", ""); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v after delete, want ErrNotFound", err)
+	}
+}
+
+func TestAggregatedStore(t *testing.T) {
+	p, client := newTestProvider(t)
+	ctx := context.Background()
+
+	if items, err := p.ListSecrets(ctx, "apps/bundle"); err != nil || len(items) != 0 {
+		t.Fatalf("listed %v, %v before the Secret exists", items, err)
+	}
+	for name, value := range map[string]string{"DB_PASSWORD": "p", "API_KEY": "k"} {
+		secret := providers.Secret{SecretProperties: providers.SecretProperties{Name: name, Tags: map[string]string{"key": name}, Enabled: true}, Value: value}
+		if _, err := p.PutSecret(ctx, "apps/bundle", secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sec := getSecret(t, client, "apps", "bundle")
+	if len(sec.Data) != 2 || string(sec.Data["DB_PASSWORD"]) != "p" || sec.Labels[managedByLabel] != managedByValue {
+		t.Errorf("aggregated Secret %+v", sec)
+	}
+
+	items, err := p.ListSecrets(ctx, "apps/bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Name+"="+item.Tags["key"])
+	}
+	if want := []string{"API_KEY=API_KEY", "DB_PASSWORD=DB_PASSWORD"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("listed %v, want %v", names, want)
+	}
+	if err := p.DeleteSecret(ctx, "apps/bundle", "API_KEY"); err != nil {
+		t.Fatal(err)
+	}
+	sec = getSecret(t, client, "apps", "bundle")
+	if _, ok := sec.Data["API_KEY"]; ok || strings.Contains(sec.Annotations[metadataAnnotation], "API_KEY") {
+		t.Errorf("deleted key left in %+v", sec)
+	}
+	if _, err := p.GetSecret(ctx, "apps/bundle", "API_KEY", ""); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v after delete, want ErrNotFound", err)
+	}
+	if err := p.DeleteSecret(ctx, "apps/bundle", "API_KEY"); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v deleting twice, want ErrNotFound", err)
+	}
+}
+
+func TestDefaultNamespace(t *testing.T) {
+	p, client := newTestProvider(t)
+	if _, err := p.PutSecret(context.Background(), "", providers.Secret{SecretProperties: providers.SecretProperties{Name: "db"}, Value: "v"}); err != nil {
+		t.Fatal(err)
+	}
+	getSecret(t, client, "default", "db")
+}
+
+func TestConflictRetry(t *testing.T) {
+	existing := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "bundle", Namespace: "apps", Labels: map[string]string{managedByLabel: managedByValue}},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"A": []byte("a")},
+	}
+	p, client := newTestProvider(t, existing)
+	updates := 0
+	client.PrependReactor("update", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		updates++
+		if updates == 1 {
+			// another client wrote the Secret in between: add its key and fail this update
+			sec := existing.DeepCopy()
+			sec.Data["C"] = []byte("c")
+			if err := client.Tracker().Update(schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, sec, "apps"); err != nil {
+				t.Fatal(err)
+			}
+			return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "secrets"}, "bundle", errors.New("modified"))
+		}
+		return false, nil, nil
+	})
+
+	if _, err := p.PutSecret(context.Background(), "apps/bundle", providers.Secret{SecretProperties: providers.SecretProperties{Name: "B"}, Value: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if updates != 2 {
+		t.Errorf("%d updates, want the conflicting one retried once", updates)
+	}
+	sec := getSecret(t, client, "apps", "bundle")
+	for key, want := range map[string]string{"A": "a", "B": "b", "C": "c"} {
+		if got := string(sec.Data[key]); got != want {
+			t.Errorf("%s = %q, want %q kept across the retry", key, got, want)
+		}
+	}
+}
+
+func TestThrottling(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		status := apierrors.NewTooManyRequests("slow down", 3).Status()
+		status.Kind, status.APIVersion = "Status", "v1"
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(status)
+	}))
+	defer server.Close()
+	client, err := newClient(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := newTestProvider(t)
+	p.clusters[""].client = client
+
+	// client-go must not retry on its own, batch.Retry retries throttled calls
+	_, err = p.GetSecret(context.Background(), "apps", "db", "")
+	var throttled *providers.ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("got %v, want a ThrottledError", err)
+	}
+	if throttled.RetryAfter != 3*time.Second {
+		t.Errorf("RetryAfter = %v, want 3s", throttled.RetryAfter)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestConcurrentAggregatedWrites(t *testing.T) {
+	p, client := newTestProvider(t)
+	ctx := context.Background()
+	names := []string{"A", "B", "C", "D", "E", "F", "G", "H"}
+	errs := make(chan error, len(names))
+	for _, name := range names {
+		go func(name string) {
+			_, err := p.PutSecret(ctx, "apps/bundle", providers.Secret{SecretProperties: providers.SecretProperties{Name: name}, Value: name})
+			errs <- err
+		}(name)
+	}
+	for range names {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sec := getSecret(t, client, "apps", "bundle"); len(sec.Data) != len(names) {
+		t.Errorf("%d keys written, want %d", len(sec.Data), len(names))
+	}
+}
@@ -0,0 +1,63 @@
+package k8
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// store is a namespace of a kubeconfig context, optionally narrowed to a single Secret whose
+// keys hold the secrets
+type store struct {
+	context   string
+	namespace string
+	// aggregate is the Secret holding every secret of the store as a key, empty stores every
+	// secret in a Secret of its own
+	aggregate string
+}
+
+// arnFields is the number of colon separated fields of an ARN, arn:partition:service:region:account:resource
+const arnFields = 6
+
+// parseStore parses [<context>:]<namespace>[/<secret>]. Contexts may contain colons, e.g. EKS
+// ARNs, so the namespace follows the last one, or the ARN when the context is one. An empty
+// namespace uses the one of the context.
+func parseStore(s string) (store, error) {
+	var st store
+	rest := s
+	if strings.HasPrefix(s, "arn:") {
+		// the resource of an ARN contains a slash, e.g. cluster/prod, which is not a namespace
+		fields := strings.SplitN(s, ":", arnFields+1)
+		st.context, rest = strings.Join(fields[:min(len(fields), arnFields)], ":"), ""
+		if len(fields) > arnFields {
+			rest = fields[arnFields]
+		}
+	} else if i := strings.LastIndex(s, ":"); i >= 0 {
+		st.context, rest = s[:i], s[i+1:]
+	}
+	st.namespace, st.aggregate, _ = strings.Cut(rest, "/")
+	if st.namespace != "" {
+		if errs := validation.IsDNS1123Label(st.namespace); len(errs) > 0 {
+			return store{}, fmt.Errorf("invalid namespace %q in store %q: %s", st.namespace, s, strings.Join(errs, ", "))
+		}
+	}
+	if strings.Contains(rest, "/") {
+		if errs := validation.IsDNS1123Subdomain(st.aggregate); len(errs) > 0 {
+			return store{}, fmt.Errorf("invalid Secret name %q in store %q: %s", st.aggregate, s, strings.Join(errs, ", "))
+		}
+	}
+	return st, nil
+}
+
+// validateName checks that a secret stored in a Secret of its own is a valid Secret name,
+// aggregated secrets are keys and only follow the data key rules
+func (st store) validateName(name string) error {
+	if st.aggregate != "" {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid Kubernetes Secret name %q: %s; rename it, e.g. to lower case, or use a <namespace>/<secret> store to keep it as a key", name, strings.Join(errs, ", "))
+	}
+	return nil
+}
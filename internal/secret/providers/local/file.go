@@ -0,0 +1,128 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/hazyforge/hazyctl/internal/secret/crypt"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/pkg/utils"
+)
+
+// lockRetryDelay is how often a held lock is tried again
+const lockRetryDelay = 50 * time.Millisecond
+
+// storeFile is the decrypted content of a store file
+type storeFile struct {
+	// Secrets holds the versions of every secret, oldest first
+	Secrets map[string][]providers.Secret `json:"secrets"`
+}
+
+// withLock runs fn holding the lock file next to path, shared for reads and exclusive for writes,
+// so several hazyctl processes can use a store at the same time
+func withLock(ctx context.Context, path string, exclusive bool, fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create store directory: %w", err)
+	}
+	lock := flock.New(path + ".lock")
+	try := lock.TryRLockContext
+	if exclusive {
+		try = lock.TryLockContext
+	}
+	if _, err := try(ctx, lockRetryDelay); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	defer lock.Unlock()
+	return fn()
+}
+
+// read decrypts the store file at path, a missing file is an empty store
+func (p *LocalSecretProvider) read(path string) (*storeFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &storeFile{Secrets: make(map[string][]providers.Secret)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+	plaintext, err := crypt.Decrypt(data, []string{p.identity})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store %s: %w", path, err)
+	}
+	var f storeFile
+	if err := json.Unmarshal(plaintext, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse store %s: %w", path, err)
+	}
+	if f.Secrets == nil {
+		f.Secrets = make(map[string][]providers.Secret)
+	}
+	return &f, nil
+}
+
+// write encrypts f into path, replacing the file atomically so readers never see a partial store
+func (p *LocalSecretProvider) write(path string, f *storeFile) error {
+	recipients, err := p.recipients()
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %w", err)
+	}
+	var buf bytes.Buffer
+	w, err := crypt.Encrypt(&buf, crypt.Options{Recipients: recipients})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return fmt.Errorf("failed to encrypt store: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt store: %w", err)
+	}
+
+	if err := utils.WritePrivateFile(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	return nil
+}
+
+// view runs fn on the decrypted store under a shared lock
+func (p *LocalSecretProvider) view(ctx context.Context, store string, fn func(*storeFile) error) error {
+	path, err := p.path(store)
+	if err != nil {
+		return err
+	}
+	return withLock(ctx, path, false, func() error {
+		f, err := p.read(path)
+		if err != nil {
+			return err
+		}
+		return fn(f)
+	})
+}
+
+// update runs fn on the decrypted store under an exclusive lock and writes it back when fn succeeds
+func (p *LocalSecretProvider) update(ctx context.Context, store string, fn func(*storeFile) error) error {
+	path, err := p.path(store)
+	if err != nil {
+		return err
+	}
+	return withLock(ctx, path, true, func() error {
+		f, err := p.read(path)
+		if err != nil {
+			return err
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+		return p.write(path, f)
+	})
+}
@@ -0,0 +1,58 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"filippo.io/age"
+	"github.com/hazyforge/hazyctl/pkg/utils"
+)
+
+// ensureIdentity generates the identity file when it does not exist yet, in the format of age-keygen
+func (p *LocalSecretProvider) ensureIdentity(ctx context.Context) error {
+	// two processes creating their first store must not generate different keys
+	return withLock(ctx, p.identity, true, func() error {
+		if _, err := os.Stat(p.identity); err == nil {
+			return nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read identity: %w", err)
+		}
+		identity, err := age.GenerateX25519Identity()
+		if err != nil {
+			return fmt.Errorf("failed to generate identity: %w", err)
+		}
+		content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n",
+			time.Now().Format(time.RFC3339), identity.Recipient(), identity)
+		// withLock created the directory, the rename never leaves a partial identity behind
+		if err := utils.WritePrivateFile(p.identity, []byte(content)); err != nil {
+			return fmt.Errorf("failed to write identity: %w", err)
+		}
+		return nil
+	})
+}
+
+// recipients returns the public keys of the identity and the configured recipients, every store is
+// encrypted for all of them
+func (p *LocalSecretProvider) recipients() ([]string, error) {
+	file, err := os.Open(p.identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open identity: %w", err)
+	}
+	defer file.Close()
+	identities, err := age.ParseIdentities(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity %s: %w", p.identity, err)
+	}
+	recipients := append([]string(nil), p.extraRecipients...)
+	for _, identity := range identities {
+		x25519, ok := identity.(*age.X25519Identity)
+		if !ok {
+			return nil, fmt.Errorf("identity %s is not an X25519 identity", p.identity)
+		}
+		recipients = append(recipients, x25519.Recipient().String())
+	}
+	return recipients, nil
+}
@@ -0,0 +1,192 @@
+package local
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"filippo.io/age"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/pkg/utils"
+)
+
+// storeExt is the extension of store files
+const storeExt = ".age"
+
+// LocalSecretProvider implements providers.SecretProvider on top of age encrypted files, for
+// offline work and air-gapped transfers. A store is a file below the stores directory, or any
+// file when the store is a path ending in .age. Every secret keeps all its versions together
+// with their tags and metadata, like Key Vault.
+type LocalSecretProvider struct {
+	dir string
+	// identity is the age identity file decrypting the stores, generated on first write
+	identity string
+	// extraRecipients can decrypt the stores as well, e.g. the key of an air-gapped machine
+	extraRecipients []string
+}
+
+func init() {
+	providers.Register("local", New)
+}
+
+// New creates a local provider from the local config section: dir, which defaults to
+// ~/.local/share/hazyctl/stores, identity and recipients, a comma separated list of age public keys
+func New(cfg providers.Config) (providers.SecretProvider, error) {
+	configDir, err := utils.ConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find config directory: %w", err)
+	}
+	p := &LocalSecretProvider{
+		dir:      cfg.Get("dir", filepath.Join(configDir, "stores")),
+		identity: cfg.Get("identity", filepath.Join(configDir, "local-identity.txt")),
+	}
+	for _, recipient := range strings.Split(cfg.Get("recipients", ""), ",") {
+		if recipient = strings.TrimSpace(recipient); recipient != "" {
+			if _, err := age.ParseX25519Recipient(recipient); err != nil {
+				return nil, fmt.Errorf("invalid recipient %q: %w", recipient, err)
+			}
+			p.extraRecipients = append(p.extraRecipients, recipient)
+		}
+	}
+	return p, nil
+}
+
+// ValidateName rejects empty names and names with control characters
+func (p *LocalSecretProvider) ValidateName(name string) error {
+	if name == "" || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return fmt.Errorf("invalid secret name %q, names must not be empty or contain control characters", name)
+	}
+	return nil
+}
+
+// path returns the file of a store
+func (p *LocalSecretProvider) path(store string) (string, error) {
+	if strings.HasSuffix(store, storeExt) {
+		return filepath.Abs(store)
+	}
+	if store == "" || store == "." || store == ".." || strings.ContainsAny(store, `/\`) {
+		return "", fmt.Errorf("invalid local store %q, use a name or the path of a %s file", store, storeExt)
+	}
+	return filepath.Join(p.dir, store+storeExt), nil
+}
+
+func (p *LocalSecretProvider) ListSecrets(ctx context.Context, store string) ([]providers.SecretProperties, error) {
+	var items []providers.SecretProperties
+	err := p.view(ctx, store, func(f *storeFile) error {
+		for _, versions := range f.Secrets {
+			items = append(items, versions[len(versions)-1].SecretProperties)
+		}
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, err
+}
+
+// GetSecret reads a version, an empty version reads the current one which fails when it is disabled
+func (p *LocalSecretProvider) GetSecret(ctx context.Context, store, name, version string) (*providers.Secret, error) {
+	var secret *providers.Secret
+	err := p.view(ctx, store, func(f *storeFile) error {
+		versions, ok := f.Secrets[name]
+		if !ok {
+			return fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+		}
+		if version == "" {
+			current := versions[len(versions)-1]
+			if !current.Enabled {
+				return fmt.Errorf("%w: %s", providers.ErrDisabled, name)
+			}
+			secret = &current
+			return nil
+		}
+		for i := range versions {
+			if versions[i].Version == version {
+				secret = &versions[i]
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s version %s", providers.ErrNotFound, name, version)
+	})
+	return secret, err
+}
+
+// PutSecret adds a new version that becomes the current one
+func (p *LocalSecretProvider) PutSecret(ctx context.Context, store string, secret providers.Secret) (*providers.SecretProperties, error) {
+	if err := p.ValidateName(secret.Name); err != nil {
+		return nil, err
+	}
+	if err := p.ensureIdentity(ctx); err != nil {
+		return nil, err
+	}
+	version, err := newVersion()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	secret.Version, secret.Created, secret.Updated, secret.Managed = version, &now, &now, false
+	err = p.update(ctx, store, func(f *storeFile) error {
+		f.Secrets[secret.Name] = append(f.Secrets[secret.Name], secret)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &secret.SecretProperties, nil
+}
+
+// DeleteSecret removes a secret and all its versions
+func (p *LocalSecretProvider) DeleteSecret(ctx context.Context, store, name string) error {
+	if err := p.ensureIdentity(ctx); err != nil {
+		return err
+	}
+	return p.update(ctx, store, func(f *storeFile) error {
+		if _, ok := f.Secrets[name]; !ok {
+			return fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+		}
+		delete(f.Secrets, name)
+		return nil
+	})
+}
+
+// ListVersions returns every version of a secret, oldest first
+func (p *LocalSecretProvider) ListVersions(ctx context.Context, store, name string) ([]providers.SecretProperties, error) {
+	var items []providers.SecretProperties
+	err := p.view(ctx, store, func(f *storeFile) error {
+		versions, ok := f.Secrets[name]
+		if !ok {
+			return fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+		}
+		for _, version := range versions {
+			items = append(items, version.SecretProperties)
+		}
+		return nil
+	})
+	return items, err
+}
+
+func (p *LocalSecretProvider) GetMetadata(ctx context.Context, store, name string) (*providers.SecretProperties, error) {
+	var props *providers.SecretProperties
+	err := p.view(ctx, store, func(f *storeFile) error {
+		versions, ok := f.Secrets[name]
+		if !ok {
+			return fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+		}
+		props = &versions[len(versions)-1].SecretProperties
+		return nil
+	})
+	return props, err
+}
+
+// newVersion returns a random 32 character hex version id like the ones of Key Vault
+func newVersion() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate version: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,284 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/hazyforge/hazyctl/internal/secret/crypt"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/providers/providertest"
+)
+
+// newTestProvider returns a provider keeping its stores and identity in dir
+func newTestProvider(t *testing.T, dir string, cfg providers.Config) *LocalSecretProvider {
+	t.Helper()
+	t.Setenv("HOME", dir)
+	if cfg == nil {
+		cfg = providers.Config{}
+	}
+	cfg["dir"] = filepath.Join(dir, "stores")
+	cfg["identity"] = filepath.Join(dir, "identity.txt")
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.(*LocalSecretProvider)
+}
+
+func TestProvider(t *testing.T) {
+	p := newTestProvider(t, t.TempDir(), nil)
+	providertest.Run(t, p, "dev")
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	p := newTestProvider(t, dir, nil)
+	ctx := context.Background()
+
+	if items, err := p.ListSecrets(ctx, "dev"); err != nil || len(items) != 0 {
+		t.Fatalf("listed %v, %v from a missing store, want it empty", items, err)
+	}
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	providertest.Put(t, p, "dev", providers.Secret{
+		SecretProperties: providers.SecretProperties{
+			Name:        "db-password",
+			ContentType: "text/plain",
+			Tags:        map[string]string{"team": "a"},
+			Enabled:     true,
+			Expires:     &expires,
+			Managed:     true,
+		},
+		Value: "s3cret",
+	})
+	providertest.Put(t, p, "dev", providers.Secret{SecretProperties: providers.SecretProperties{Name: "api-key", Enabled: true}, Value: "k"})
+
+	got, err := p.GetSecret(ctx, "dev", "db-password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "s3cret" || got.ContentType != "text/plain" || got.Tags["team"] != "a" || !got.Expires.Equal(expires) || got.Created == nil || got.Managed {
+		t.Errorf("got %+v", got)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "stores", "dev"+storeExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("s3cret")) || bytes.Contains(data, []byte("db-password")) {
+		t.Error("the store file is not encrypted")
+	}
+	info, err := os.Stat(p.identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("identity mode %v, want 0600", info.Mode().Perm())
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+
+	items, err := p.ListSecrets(ctx, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Name != "api-key" || items[1].Name != "db-password" {
+		t.Errorf("listed %+v, want both secrets sorted by name", items)
+	}
+
+	if err := p.DeleteSecret(ctx, "dev", "db-password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetSecret(ctx, "dev", "db-password", ""); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v after delete, want ErrNotFound", err)
+	}
+	if err := p.DeleteSecret(ctx, "dev", "db-password"); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v deleting twice, want ErrNotFound", err)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	p := newTestProvider(t, t.TempDir(), nil)
+	ctx := context.Background()
+
+	first := providertest.Put(t, p, "dev", providers.Secret{SecretProperties: providers.SecretProperties{Name: "db", Enabled: true, Tags: map[string]string{"rev": "one"}}, Value: "1"})
+	second := providertest.Put(t, p, "dev", providers.Secret{SecretProperties: providers.SecretProperties{Name: "db", Enabled: true, Tags: map[string]string{"rev": "two"}}, Value: "2"})
+	third := providertest.Put(t, p, "dev", providers.Secret{SecretProperties: providers.SecretProperties{Name: "db", Tags: map[string]string{"rev": "three"}}, Value: "3"})
+	if len(first.Version) != 32 || first.Version == second.Version || second.Version == third.Version {
+		t.Fatalf("versions %q, %q, %q, want distinct 32 character ids", first.Version, second.Version, third.Version)
+	}
+
+	versions, err := p.ListVersions(ctx, "dev", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids, revs []string
+	for _, v := range versions {
+		ids = append(ids, v.Version)
+		revs = append(revs, v.Tags["rev"])
+	}
+	if want := []string{first.Version, second.Version, third.Version}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("versions %v, want %v oldest first", ids, want)
+	}
+	if want := []string{"one", "two", "three"}; !reflect.DeepEqual(revs, want) {
+		t.Errorf("version tags %v, want %v", revs, want)
+	}
+
+	if _, err := p.GetSecret(ctx, "dev", "db", ""); !errors.Is(err, providers.ErrDisabled) {
+		t.Errorf("got %v reading a disabled current version, want ErrDisabled", err)
+	}
+	for version, want := range map[string]string{first.Version: "1", second.Version: "2", third.Version: "3"} {
+		got, err := p.GetSecret(ctx, "dev", "db", version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Value != want || got.Version != version {
+			t.Errorf("version %s = %q, want %q", version, got.Value, want)
+		}
+	}
+	if _, err := p.GetSecret(ctx, "dev", "db", "0123"); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v for an unknown version, want ErrNotFound", err)
+	}
+
+	meta, err := p.GetMetadata(ctx, "dev", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Version != third.Version || meta.Enabled || meta.Tags["rev"] != "three" {
+		t.Errorf("metadata %+v, want the current version", meta)
+	}
+	if _, err := p.ListVersions(ctx, "dev", "missing"); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v listing versions of a missing secret, want ErrNotFound", err)
+	}
+}
+
+func TestStorePath(t *testing.T) {
+	dir := t.TempDir()
+	p := newTestProvider(t, dir, nil)
+	for _, store := range []string{"", ".", "..", "a/b", `a\b`} {
+		if _, err := p.ListSecrets(context.Background(), store); err == nil {
+			t.Errorf("store %q was accepted", store)
+		}
+	}
+
+	file := filepath.Join(dir, "transfer", "export"+storeExt)
+	providertest.Put(t, p, file, providers.Secret{SecretProperties: providers.SecretProperties{Name: "db", Enabled: true}, Value: "v"})
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("store at a path was not written: %v", err)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	p := &LocalSecretProvider{}
+	for name, valid := range map[string]bool{
+		"db-password":   true,
+		"Team/DB pass:": true,
+		"":              false,
+		"db\npassword":  false,
+	} {
+		if err := p.ValidateName(name); (err == nil) != valid {
+			t.Errorf("ValidateName(%q) = %v, want valid %v", name, err, valid)
+		}
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	if _, err := New(providers.Config{"recipients": "not-a-key"}); err == nil {
+		t.Error("an invalid recipient was accepted")
+	}
+
+	dir := t.TempDir()
+	extra, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newTestProvider(t, dir, providers.Config{"recipients": " " + extra.Recipient().String() + ", "})
+	providertest.Put(t, p, "dev", providers.Secret{SecretProperties: providers.SecretProperties{Name: "db", Enabled: true}, Value: "v"})
+
+	// the extra recipient decrypts the store without the generated identity
+	extraFile := filepath.Join(dir, "extra.txt")
+	if err := os.WriteFile(extraFile, []byte(extra.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "stores", "dev"+storeExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := crypt.Decrypt(data, []string{extraFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(plaintext, []byte(`"db"`)) {
+		t.Errorf("decrypted %s, want the store", plaintext)
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+	// two providers stand in for two hazyctl processes sharing the identity and the store
+	writers := []*LocalSecretProvider{newTestProvider(t, dir, nil), newTestProvider(t, dir, nil)}
+	const perWriter = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(writers)*perWriter)
+	for w, p := range writers {
+		for i := 0; i < perWriter; i++ {
+			wg.Add(1)
+			go func(p *LocalSecretProvider, name string) {
+				defer wg.Done()
+				secret := providers.Secret{SecretProperties: providers.SecretProperties{Name: name, Enabled: true}, Value: name}
+				_, err := p.PutSecret(context.Background(), "shared", secret)
+				errs <- err
+			}(p, fmt.Sprintf("writer%d-%d", w, i))
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// every write is kept and both writers read the store, so they agreed on one identity
+	for _, p := range writers {
+		items, err := p.ListSecrets(context.Background(), "shared")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != len(writers)*perWriter {
+			t.Errorf("%d secrets in the store, want %d", len(items), len(writers)*perWriter)
+		}
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	p := newTestProvider(t, t.TempDir(), nil)
+	path, err := p.path("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = withLock(context.Background(), path, false, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		_, err := p.PutSecret(ctx, "dev", providers.Secret{SecretProperties: providers.SecretProperties{Name: "db", Enabled: true}, Value: "v"})
+		if err == nil {
+			return errors.New("wrote the store while it was read")
+		}
+		// readers share the lock
+		_, err = p.ListSecrets(context.Background(), "dev")
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -1,14 +1,86 @@
 package providers
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
 
-// SecretProvider defines what a provider must implement
+// ErrNotFound is returned when a secret does not exist in a store
+var ErrNotFound = errors.New("secret not found")
+
+// ErrDisabled is returned when a provider refuses to read the value of a disabled secret version
+var ErrDisabled = errors.New("secret is disabled")
+
+// ThrottledError is returned when a provider rejects a request because of rate limiting
+type ThrottledError struct {
+	// RetryAfter is the delay requested by the provider, zero when none was given
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("throttled: %v", e.Err)
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// SecretProperties describes a single secret version without its value
+type SecretProperties struct {
+	Name        string            `json:"name"`
+	Version     string            `json:"version,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Enabled     bool              `json:"enabled"`
+	Expires     *time.Time        `json:"expires,omitempty"`
+	NotBefore   *time.Time        `json:"notBefore,omitempty"`
+	Created     *time.Time        `json:"created,omitempty"`
+	Updated     *time.Time        `json:"updated,omitempty"`
+	Managed     bool              `json:"managed,omitempty"`
+	// Labels are the provider's labels of a version, e.g. the AWS staging labels
+	Labels []string `json:"labels,omitempty"`
+}
+
+// Secret is a secret version together with its value
+type Secret struct {
+	SecretProperties
+	Value string `json:"value"`
+}
+
+// SecretProvider defines what a provider must implement.
+// A store is the provider specific container of secrets, e.g. a Key Vault name.
 type SecretProvider interface {
-	MigrateAll(sourceVaultURL, destinationVaultURL string) error
+	// ListSecrets returns the current version of every secret in the store
+	ListSecrets(ctx context.Context, store string) ([]SecretProperties, error)
+	// GetSecret returns a secret value, an empty version means the current one
+	GetSecret(ctx context.Context, store, name, version string) (*Secret, error)
+	// PutSecret writes a new version of a secret
+	PutSecret(ctx context.Context, store string, secret Secret) (*SecretProperties, error)
+	// DeleteSecret removes a secret and all its versions
+	DeleteSecret(ctx context.Context, store, name string) error
+	// ListVersions returns every version of a secret
+	ListVersions(ctx context.Context, store, name string) ([]SecretProperties, error)
+	// GetMetadata returns the properties of the current version of a secret
+	GetMetadata(ctx context.Context, store, name string) (*SecretProperties, error)
+}
+
+// Config holds provider specific settings such as credentials or endpoints
+type Config map[string]string
+
+// Get returns the value for key or def when it is not set
+func (c Config) Get(key, def string) string {
+	if v, ok := c[key]; ok && v != "" {
+		return v
+	}
+	return def
 }
 
 // Constructor type for dynamic provider creation
-type ProviderConstructor func() (SecretProvider, error)
+type ProviderConstructor func(cfg Config) (SecretProvider, error)
 
 // Global registry of providers
 var registry = make(map[string]ProviderConstructor)
@@ -19,10 +91,20 @@ func Register(name string, constructor ProviderConstructor) {
 }
 
 // GetProvider returns a provider by name
-func GetProvider(name string) (SecretProvider, error) {
+func GetProvider(name string, cfg Config) (SecretProvider, error) {
 	constructor, exists := registry[name]
 	if !exists {
-		return nil, fmt.Errorf("provider %s not found", name)
+		return nil, fmt.Errorf("provider %s not found, available providers: %v", name, Names())
+	}
+	return constructor(cfg)
+}
+
+// Names returns the sorted names of all registered providers
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
 	}
-	return constructor()
+	sort.Strings(names)
+	return names
 }
@@ -0,0 +1,110 @@
+package providertest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+// Memory keeps every version of the secrets of each store in memory.
+// Like Key Vault, the value of a disabled version cannot be read.
+type Memory struct {
+	mu     sync.Mutex
+	stores map[string]map[string][]providers.Secret
+}
+
+// NewMemory returns an empty in-memory provider
+func NewMemory() *Memory {
+	return &Memory{stores: make(map[string]map[string][]providers.Secret)}
+}
+
+func (m *Memory) store(name string) map[string][]providers.Secret {
+	if m.stores[name] == nil {
+		m.stores[name] = make(map[string][]providers.Secret)
+	}
+	return m.stores[name]
+}
+
+func (m *Memory) ListSecrets(ctx context.Context, store string) ([]providers.SecretProperties, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var items []providers.SecretProperties
+	for _, versions := range m.store(store) {
+		items = append(items, versions[len(versions)-1].SecretProperties)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+func (m *Memory) GetSecret(ctx context.Context, store, name, version string) (*providers.Secret, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	versions := m.store(store)[name]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if version == "" || versions[i].Version == version {
+			if !versions[i].Enabled {
+				return nil, fmt.Errorf("%w: %s", providers.ErrDisabled, name)
+			}
+			secret := versions[i]
+			return &secret, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+}
+
+func (m *Memory) PutSecret(ctx context.Context, store string, secret providers.Secret) (*providers.SecretProperties, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	secrets := m.store(store)
+	secret.Version = fmt.Sprint(len(secrets[secret.Name]) + 1)
+	secrets[secret.Name] = append(secrets[secret.Name], secret)
+	return &secret.SecretProperties, nil
+}
+
+func (m *Memory) DeleteSecret(ctx context.Context, store, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.store(store)[name]; !ok {
+		return fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+	}
+	delete(m.store(store), name)
+	return nil
+}
+
+func (m *Memory) ListVersions(ctx context.Context, store, name string) ([]providers.SecretProperties, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var items []providers.SecretProperties
+	for _, secret := range m.store(store)[name] {
+		items = append(items, secret.SecretProperties)
+	}
+	return items, nil
+}
+
+func (m *Memory) GetMetadata(ctx context.Context, store, name string) (*providers.SecretProperties, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	versions, ok := m.store(store)[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+	}
+	props := versions[len(versions)-1].SecretProperties
+	return &props, nil
+}
+
+// Versions returns every version of a secret, oldest first
+func (m *Memory) Versions(store, name string) []providers.Secret {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]providers.Secret(nil), m.store(store)[name]...)
+}
+
+// Restore puts back the versions of a deleted secret, like recovering a soft-deleted secret
+func (m *Memory) Restore(store, name string, versions []providers.Secret) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store(store)[name] = versions
+}
@@ -0,0 +1,105 @@
+// Package providertest holds the helpers and the behaviour every secret provider test shares
+package providertest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+// Put writes a secret and fails the test when it cannot
+func Put(t *testing.T, p providers.SecretProvider, store string, secret providers.Secret) *providers.SecretProperties {
+	t.Helper()
+	props, err := p.PutSecret(context.Background(), store, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return props
+}
+
+// NewSecret returns an enabled secret with tags
+func NewSecret(name, value string, tags map[string]string) providers.Secret {
+	return providers.Secret{SecretProperties: providers.SecretProperties{Name: name, Enabled: true, Tags: tags}, Value: value}
+}
+
+// Run checks the behaviour every provider shares on an empty store: reads of missing secrets,
+// writes, versions as far as the provider keeps them, listing and deletes
+func Run(t *testing.T, p providers.SecretProvider, store string) {
+	t.Helper()
+	ctx := context.Background()
+	caps := providers.CapabilitiesOf(p)
+	const name = "conformance"
+
+	if _, err := p.GetSecret(ctx, store, name, ""); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v reading a missing secret, want ErrNotFound", err)
+	}
+	if _, err := p.GetMetadata(ctx, store, name); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v reading the metadata of a missing secret, want ErrNotFound", err)
+	}
+
+	var tags map[string]string
+	if caps.Tags {
+		tags = map[string]string{"rev": "one"}
+	}
+	first := Put(t, p, store, NewSecret(name, "one", tags))
+	if caps.Tags {
+		tags = map[string]string{"rev": "two"}
+	}
+	second := Put(t, p, store, NewSecret(name, "two", tags))
+
+	got, err := p.GetSecret(ctx, store, name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "two" || !got.Enabled || !reflect.DeepEqual(got.Tags, tags) {
+		t.Errorf("got %+v, want the second write", got)
+	}
+	meta, err := p.GetMetadata(ctx, store, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Name != name || meta.Version != second.Version || !reflect.DeepEqual(meta.Tags, tags) {
+		t.Errorf("metadata %+v, want the current version %s", meta, second.Version)
+	}
+
+	if caps.Versions {
+		if first.Version == "" || first.Version == second.Version {
+			t.Fatalf("versions %q and %q, want two distinct ids", first.Version, second.Version)
+		}
+		old, err := p.GetSecret(ctx, store, name, first.Version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if old.Value != "one" || old.Version != first.Version {
+			t.Errorf("version %s = %q, want one", first.Version, old.Value)
+		}
+		versions, err := p.ListVersions(ctx, store, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(versions) != 2 {
+			t.Errorf("listed %d versions, want 2", len(versions))
+		}
+	}
+
+	items, err := p.ListSecrets(ctx, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != name {
+		t.Errorf("listed %+v, want only %s", items, name)
+	}
+
+	if err := p.DeleteSecret(ctx, store, name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetSecret(ctx, store, name, ""); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v after delete, want ErrNotFound", err)
+	}
+	if items, err := p.ListSecrets(ctx, store); err != nil || len(items) != 0 {
+		t.Errorf("listed %+v, %v after delete, want nothing", items, err)
+	}
+}
@@ -0,0 +1,64 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// StoreRef addresses a store of a provider, written as <provider>://<store>
+type StoreRef struct {
+	Provider string
+	Store    string
+}
+
+// ParseStoreRef parses a store address such as azure://my-vault
+func ParseStoreRef(uri string) (StoreRef, error) {
+	provider, store, ok := strings.Cut(uri, "://")
+	if !ok || provider == "" || store == "" {
+		return StoreRef{}, fmt.Errorf("invalid store address %q, expected <provider>://<store>", uri)
+	}
+	return StoreRef{Provider: provider, Store: store}, nil
+}
+
+func (r StoreRef) String() string {
+	return r.Provider + "://" + r.Store
+}
+
+// Open creates the provider addressed by the reference using its config section
+func (r StoreRef) Open() (SecretProvider, error) {
+	return GetProvider(r.Provider, LoadConfig(r.Provider))
+}
+
+// Opener creates every provider once and shares it between goroutines, so a batch reading
+// many stores of one provider authenticates once. The zero value is ready to use.
+type Opener struct {
+	mu     sync.Mutex
+	opened map[string]*opened
+}
+
+type opened struct {
+	once     sync.Once
+	provider SecretProvider
+	err      error
+}
+
+// Open returns the provider of ref, creating it on first use
+func (o *Opener) Open(ref StoreRef) (SecretProvider, error) {
+	o.mu.Lock()
+	if o.opened == nil {
+		o.opened = make(map[string]*opened)
+	}
+	result, ok := o.opened[ref.Provider]
+	if !ok {
+		result = &opened{}
+		o.opened[ref.Provider] = result
+	}
+	o.mu.Unlock()
+	result.once.Do(func() {
+		if result.provider, result.err = ref.Open(); result.err != nil {
+			result.err = fmt.Errorf("failed to create %s provider: %w", ref.Provider, result.err)
+		}
+	})
+	return result.provider, result.err
+}
@@ -0,0 +1,66 @@
+package vault
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+// defaultServiceAccountToken is where Kubernetes mounts the token of the pod's service account
+const defaultServiceAccountToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// login authenticates the client with the method selected by auth:
+//
+//	token       token, or VAULT_TOKEN
+//	approle     role_id and secret_id, or VAULT_ROLE_ID and VAULT_SECRET_ID
+//	kubernetes  role and the service account token read from token_path
+//
+// auth_mount overrides the mount path of the approle and kubernetes methods.
+func login(client *api.Client, cfg providers.Config) error {
+	method := cfg.Get("auth", "token")
+	var data map[string]interface{}
+	switch method {
+	case "token":
+		if token := cfg.Get("token", ""); token != "" {
+			client.SetToken(token)
+		}
+		if client.Token() == "" {
+			return fmt.Errorf("no Vault token, set vault.token or VAULT_TOKEN")
+		}
+		return nil
+	case "approle":
+		data = map[string]interface{}{
+			"role_id":   cfg.Get("role_id", os.Getenv("VAULT_ROLE_ID")),
+			"secret_id": cfg.Get("secret_id", os.Getenv("VAULT_SECRET_ID")),
+		}
+		if data["role_id"] == "" {
+			return fmt.Errorf("no AppRole role id, set vault.role_id or VAULT_ROLE_ID")
+		}
+	case "kubernetes":
+		jwt, err := os.ReadFile(cfg.Get("token_path", defaultServiceAccountToken))
+		if err != nil {
+			return fmt.Errorf("failed to read service account token: %w", err)
+		}
+		data = map[string]interface{}{"role": cfg.Get("role", ""), "jwt": strings.TrimSpace(string(jwt))}
+		if data["role"] == "" {
+			return fmt.Errorf("no Kubernetes auth role, set vault.role")
+		}
+	default:
+		return fmt.Errorf("unknown Vault auth method %q, expected token, approle or kubernetes", method)
+	}
+
+	// a stale VAULT_TOKEN would otherwise be sent with the login request
+	client.ClearToken()
+	secret, err := client.Logical().Write("auth/"+cfg.Get("auth_mount", method)+"/login", data)
+	if err != nil {
+		return fmt.Errorf("failed to log in to Vault with %s: %w", method, err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return fmt.Errorf("failed to log in to Vault with %s: no token returned", method)
+	}
+	client.SetToken(secret.Auth.ClientToken)
+	return nil
+}
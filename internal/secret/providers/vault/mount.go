@@ -0,0 +1,73 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// mount is a KV mount together with the folder a store addresses below it
+type mount struct {
+	root    string
+	prefix  string
+	version int
+}
+
+// mount resolves a store to its KV mount, detecting the KV version like the vault CLI does
+func (p *VaultSecretProvider) mount(ctx context.Context, store string) (*mount, error) {
+	store = strings.Trim(store, "/")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if m, ok := p.mounts[store]; ok {
+		return m, nil
+	}
+
+	mountPath, _, _ := strings.Cut(store, "/")
+	m := &mount{root: mountPath, version: p.kvVersion}
+	if m.version == 0 {
+		m.version = 2
+		secret, err := p.client.Logical().ReadWithContext(ctx, "sys/internal/ui/mounts/"+store)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up the KV mount of %s, set kv_version to skip the lookup: %w", store, wrapError(err))
+		}
+		if secret != nil && secret.Data != nil {
+			if detected, _ := secret.Data["path"].(string); detected != "" {
+				m.root = strings.Trim(detected, "/")
+			}
+			// KV v1 mounts have no version option
+			if options, _ := secret.Data["options"].(map[string]interface{}); options["version"] != "2" {
+				m.version = 1
+			}
+		}
+	}
+	if !strings.HasPrefix(store+"/", m.root+"/") {
+		return nil, fmt.Errorf("store %s is not below its mount %s", store, m.root)
+	}
+	m.prefix = strings.Trim(strings.TrimPrefix(store, m.root), "/")
+	p.mounts[store] = m
+	return m, nil
+}
+
+// apiPath returns the path of a secret below an endpoint of the mount, e.g. data or metadata
+func (m *mount) apiPath(endpoint, name string) string {
+	return path.Join(m.root, endpoint, m.prefix, name)
+}
+
+func (m *mount) dataPath(name string) string {
+	if m.version == 1 {
+		return path.Join(m.root, m.prefix, name)
+	}
+	return m.apiPath("data", name)
+}
+
+func (m *mount) metadataPath(name string) string {
+	return m.apiPath("metadata", name)
+}
+
+func (m *mount) listPath(dir string) string {
+	if m.version == 1 {
+		return path.Join(m.root, m.prefix, dir)
+	}
+	return m.metadataPath(dir)
+}
@@ -0,0 +1,479 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+)
+
+// contentTypeKey stores the content type of a secret in the custom metadata of KV v2
+const contentTypeKey = "hazyctl-content-type"
+
+// contentTypeJSON marks values holding every key of a KV secret as a JSON object
+const contentTypeJSON = "application/json"
+
+// valueKey is the data key holding the value of single value secrets
+const valueKey = "value"
+
+// VaultSecretProvider implements providers.SecretProvider on top of HashiCorp Vault KV v1 and v2.
+// Stores are KV mount paths, optionally followed by a folder, e.g. "secret" or "secret/team-a".
+// A secret is a KV path below the store: a single "value" key becomes the secret value,
+// secrets with other keys are read and written as a JSON object. KV v2 custom_metadata
+// holds the tags, it belongs to the secret rather than to a single version.
+type VaultSecretProvider struct {
+	client *api.Client
+	// kvVersion forces the KV version of every mount, 0 detects it per mount
+	kvVersion int
+
+	mu     sync.Mutex
+	mounts map[string]*mount
+}
+
+func init() {
+	providers.Register("vault", New)
+}
+
+// New creates a HashiCorp Vault provider. The address, token and namespace default to
+// VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE; auth selects token, approle or kubernetes login.
+func New(cfg providers.Config) (providers.SecretProvider, error) {
+	config := api.DefaultConfig()
+	if config.Error != nil {
+		return nil, fmt.Errorf("failed to read Vault environment: %w", config.Error)
+	}
+	config.CheckRetry = checkRetry
+	client, err := api.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vault client: %w", err)
+	}
+	if address := cfg.Get("address", ""); address != "" {
+		if err := client.SetAddress(address); err != nil {
+			return nil, fmt.Errorf("invalid Vault address: %w", err)
+		}
+	}
+	if namespace := cfg.Get("namespace", ""); namespace != "" {
+		client.SetNamespace(namespace)
+	}
+
+	p := &VaultSecretProvider{client: client, mounts: make(map[string]*mount)}
+	switch version := cfg.Get("kv_version", ""); version {
+	case "":
+	case "1", "2":
+		p.kvVersion = int(version[0] - '0')
+	default:
+		return nil, fmt.Errorf("invalid kv_version %q, expected 1 or 2", version)
+	}
+	if err := login(client, cfg); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Capabilities reports KV v2 support unless kv_version is set to 1, which stores neither metadata nor versions.
+// Mounts detected as KV v1 refuse secrets carrying metadata in PutSecret instead of dropping it.
+func (p *VaultSecretProvider) Capabilities() providers.Capabilities {
+	if p.kvVersion == 1 {
+		return providers.Capabilities{}
+	}
+	// disabled versions are soft-deleted KV v2 versions
+	return providers.Capabilities{Tags: true, ContentType: true, Disable: true, Versions: true}
+}
+
+// ValidateName rejects names that are not clean relative KV paths
+func (p *VaultSecretProvider) ValidateName(name string) error {
+	if name == "" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || path.Clean(name) != name || name == ".." || strings.HasPrefix(name, "../") {
+		return fmt.Errorf("invalid Vault secret name %q, names are relative KV paths such as app/db-password", name)
+	}
+	return nil
+}
+
+func (p *VaultSecretProvider) ListSecrets(ctx context.Context, store string) ([]providers.SecretProperties, error) {
+	m, err := p.mount(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	names, err := p.list(ctx, m, "")
+	if err != nil {
+		return nil, err
+	}
+	items := make([]providers.SecretProperties, 0, len(names))
+	for _, name := range names {
+		if m.version == 1 {
+			items = append(items, providers.SecretProperties{Name: name, Enabled: true})
+			continue
+		}
+		meta, err := p.metadata(ctx, m, name)
+		if errors.Is(err, providers.ErrNotFound) {
+			// deleted since it was listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, meta.properties(name, meta.CurrentVersion))
+	}
+	return items, nil
+}
+
+// list walks the folders below dir and returns every secret name relative to the store
+func (p *VaultSecretProvider) list(ctx context.Context, m *mount, dir string) ([]string, error) {
+	secret, err := p.client.Logical().ListWithContext(ctx, m.listPath(dir))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if secret == nil {
+		return nil, nil
+	}
+	keys, _ := secret.Data["keys"].([]interface{})
+	var names []string
+	for _, key := range keys {
+		name, _ := key.(string)
+		if folder, ok := strings.CutSuffix(name, "/"); ok {
+			nested, err := p.list(ctx, m, path.Join(dir, folder))
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, nested...)
+			continue
+		}
+		names = append(names, path.Join(dir, name))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (p *VaultSecretProvider) GetSecret(ctx context.Context, store, name, version string) (*providers.Secret, error) {
+	m, err := p.mount(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	var params map[string][]string
+	if version != "" {
+		if m.version == 1 {
+			return nil, fmt.Errorf("%w: %s version %s, KV v1 keeps no versions", providers.ErrNotFound, name, version)
+		}
+		params = map[string][]string{"version": {version}}
+	}
+	secret, err := p.client.Logical().ReadWithDataWithContext(ctx, m.dataPath(name), params)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+	}
+	if m.version == 1 {
+		value, contentType, err := decodeValue(secret.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+		}
+		return &providers.Secret{
+			SecretProperties: providers.SecretProperties{Name: name, ContentType: contentType, Enabled: true},
+			Value:            value,
+		}, nil
+	}
+
+	var read struct {
+		Data     map[string]interface{} `json:"data"`
+		Metadata versionMetadata        `json:"metadata"`
+	}
+	if err := convert(secret.Data, &read); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	if read.Metadata.deleted() {
+		return nil, fmt.Errorf("%w: %s version %d", providers.ErrDisabled, name, read.Metadata.Version)
+	}
+	if read.Data == nil {
+		return nil, fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+	}
+	value, contentType, err := decodeValue(read.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	meta := secretMetadata{CustomMetadata: read.Metadata.CustomMetadata, Versions: map[string]versionMetadata{}}
+	meta.Versions[fmt.Sprint(read.Metadata.Version)] = read.Metadata
+	props := meta.properties(name, read.Metadata.Version)
+	if props.ContentType == "" {
+		props.ContentType = contentType
+	}
+	return &providers.Secret{SecretProperties: props, Value: value}, nil
+}
+
+func (p *VaultSecretProvider) PutSecret(ctx context.Context, store string, secret providers.Secret) (*providers.SecretProperties, error) {
+	if err := p.ValidateName(secret.Name); err != nil {
+		return nil, err
+	}
+	m, err := p.mount(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	data := encodeValue(secret)
+	if m.version == 1 {
+		// Capabilities promised KV v2 unless kv_version is 1, so the metadata was not dropped up front
+		if lost := v1Unsupported(secret); len(lost) > 0 {
+			return nil, fmt.Errorf("%s is a KV v1 mount, which cannot store the %s of %s, set kv_version to 1 to write it without them", m.root, strings.Join(lost, " and "), secret.Name)
+		}
+		if _, err := p.client.Logical().WriteWithContext(ctx, m.dataPath(secret.Name), data); err != nil {
+			return nil, wrapError(err)
+		}
+		return &providers.SecretProperties{Name: secret.Name, Enabled: true}, nil
+	}
+
+	written, err := p.client.Logical().WriteWithContext(ctx, m.dataPath(secret.Name), map[string]interface{}{"data": data})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	var created versionMetadata
+	if written != nil {
+		if err := convert(written.Data, &created); err != nil {
+			return nil, fmt.Errorf("failed to decode write response: %w", err)
+		}
+	}
+
+	custom := make(map[string]string, len(secret.Tags)+1)
+	for k, v := range secret.Tags {
+		custom[k] = v
+	}
+	if secret.ContentType != "" && secret.ContentType != contentTypeJSON {
+		custom[contentTypeKey] = secret.ContentType
+	}
+	if _, err := p.client.Logical().WriteWithContext(ctx, m.metadataPath(secret.Name), map[string]interface{}{"custom_metadata": custom}); err != nil {
+		return nil, fmt.Errorf("failed to write custom metadata: %w", wrapError(err))
+	}
+	if !secret.Enabled && created.Version > 0 {
+		// a disabled version is a soft-deleted one, it can be brought back with undelete
+		if _, err := p.client.Logical().WriteWithContext(ctx, m.apiPath("delete", secret.Name), map[string]interface{}{"versions": []int{created.Version}}); err != nil {
+			return nil, fmt.Errorf("failed to disable version %d: %w", created.Version, wrapError(err))
+		}
+	}
+
+	props := secret.SecretProperties
+	props.Version = fmt.Sprint(created.Version)
+	props.Created, props.Updated = created.created(), created.created()
+	return &props, nil
+}
+
+// v1Unsupported lists the metadata of secret that a KV v1 mount cannot store
+func v1Unsupported(secret providers.Secret) []string {
+	var lost []string
+	if len(secret.Tags) > 0 {
+		lost = append(lost, "tags")
+	}
+	if secret.ContentType != "" && secret.ContentType != contentTypeJSON {
+		lost = append(lost, "content type")
+	}
+	if !secret.Enabled {
+		lost = append(lost, "disabled state")
+	}
+	return lost
+}
+
+// DeleteSecret removes a secret with all its versions and metadata
+func (p *VaultSecretProvider) DeleteSecret(ctx context.Context, store, name string) error {
+	m, err := p.mount(ctx, store)
+	if err != nil {
+		return err
+	}
+	target := m.dataPath(name)
+	if m.version == 2 {
+		target = m.metadataPath(name)
+	}
+	if _, err := p.client.Logical().DeleteWithContext(ctx, target); err != nil {
+		return wrapError(err)
+	}
+	return nil
+}
+
+func (p *VaultSecretProvider) ListVersions(ctx context.Context, store, name string) ([]providers.SecretProperties, error) {
+	m, err := p.mount(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	if m.version == 1 {
+		props, err := p.GetMetadata(ctx, store, name)
+		if err != nil {
+			return nil, err
+		}
+		return []providers.SecretProperties{*props}, nil
+	}
+	meta, err := p.metadata(ctx, m, name)
+	if err != nil {
+		return nil, err
+	}
+	var versions []providers.SecretProperties
+	for _, v := range meta.Versions {
+		if v.Destroyed {
+			continue
+		}
+		versions = append(versions, meta.properties(name, v.Version))
+	}
+	sort.Slice(versions, func(i, j int) bool { return versionNumber(versions[i].Version) < versionNumber(versions[j].Version) })
+	return versions, nil
+}
+
+func (p *VaultSecretProvider) GetMetadata(ctx context.Context, store, name string) (*providers.SecretProperties, error) {
+	m, err := p.mount(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	if m.version == 1 {
+		secret, err := p.GetSecret(ctx, store, name, "")
+		if err != nil {
+			return nil, err
+		}
+		return &secret.SecretProperties, nil
+	}
+	meta, err := p.metadata(ctx, m, name)
+	if err != nil {
+		return nil, err
+	}
+	props := meta.properties(name, meta.CurrentVersion)
+	return &props, nil
+}
+
+// secretMetadata is the KV v2 metadata of a secret
+type secretMetadata struct {
+	CurrentVersion int                        `json:"current_version"`
+	CreatedTime    string                     `json:"created_time"`
+	UpdatedTime    string                     `json:"updated_time"`
+	CustomMetadata map[string]string          `json:"custom_metadata"`
+	Versions       map[string]versionMetadata `json:"versions"`
+}
+
+// versionMetadata is the KV v2 metadata of a single version
+type versionMetadata struct {
+	Version        int               `json:"version"`
+	CreatedTime    string            `json:"created_time"`
+	DeletionTime   string            `json:"deletion_time"`
+	Destroyed      bool              `json:"destroyed"`
+	CustomMetadata map[string]string `json:"custom_metadata"`
+}
+
+func (v versionMetadata) deleted() bool {
+	return v.Destroyed || v.DeletionTime != ""
+}
+
+func (v versionMetadata) created() *time.Time {
+	return parseTime(v.CreatedTime)
+}
+
+func (p *VaultSecretProvider) metadata(ctx context.Context, m *mount, name string) (*secretMetadata, error) {
+	secret, err := p.client.Logical().ReadWithContext(ctx, m.metadataPath(name))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("%w: %s", providers.ErrNotFound, name)
+	}
+	var meta secretMetadata
+	if err := convert(secret.Data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata of %s: %w", name, err)
+	}
+	return &meta, nil
+}
+
+// properties describes a version of the secret, custom metadata other than the content type become tags
+func (meta *secretMetadata) properties(name string, version int) providers.SecretProperties {
+	v := meta.Versions[fmt.Sprint(version)]
+	props := providers.SecretProperties{
+		Name:    name,
+		Version: fmt.Sprint(version),
+		Enabled: !v.deleted(),
+		Created: v.created(),
+		Updated: parseTime(meta.UpdatedTime),
+	}
+	if props.Updated == nil {
+		props.Updated = props.Created
+	}
+	for k, value := range meta.CustomMetadata {
+		if k == contentTypeKey {
+			props.ContentType = value
+			continue
+		}
+		if props.Tags == nil {
+			props.Tags = make(map[string]string, len(meta.CustomMetadata))
+		}
+		props.Tags[k] = value
+	}
+	return props
+}
+
+// decodeValue turns KV data into a secret value, data with keys other than "value" becomes a JSON object
+func decodeValue(data map[string]interface{}) (value, contentType string, err error) {
+	if len(data) == 1 {
+		if value, ok := data[valueKey].(string); ok {
+			return value, "", nil
+		}
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", "", err
+	}
+	return string(encoded), contentTypeJSON, nil
+}
+
+// encodeValue turns a secret value into KV data, JSON objects are written as their keys
+func encodeValue(secret providers.Secret) map[string]interface{} {
+	if secret.ContentType == contentTypeJSON {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(secret.Value), &data); err == nil && data != nil {
+			return data
+		}
+	}
+	return map[string]interface{}{valueKey: secret.Value}
+}
+
+// convert decodes the loosely typed data of a Vault response into out
+func convert(data map[string]interface{}, out interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, out)
+}
+
+func parseTime(s string) *time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil || t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func versionNumber(version string) int {
+	var n int
+	fmt.Sscan(version, &n)
+	return n
+}
+
+// checkRetry is the client retry policy without throttling responses, they are left to
+// batch.Retry, which honours --max-retries, instead of multiplying both retry loops
+func checkRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if err == nil && resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		return false, nil
+	}
+	return api.DefaultRetryPolicy(ctx, resp, err)
+}
+
+// wrapError maps Vault errors to provider errors
+func wrapError(err error) error {
+	var respErr *api.ResponseError
+	if !errors.As(err, &respErr) {
+		return err
+	}
+	switch respErr.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %v", providers.ErrNotFound, err)
+	case http.StatusTooManyRequests:
+		return &providers.ThrottledError{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,619 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/providers/providertest"
+)
+
+// fakeVault serves the parts of the Vault HTTP API the provider uses: a KV v1 mount at kv/,
+// a KV v2 mount at secret/ and the AppRole and Kubernetes logins
+type fakeVault struct {
+	t *testing.T
+	// token is required on every request but logins
+	token string
+	// namespace, when set, is required on every request
+	namespace string
+
+	mu sync.Mutex
+	// throttle answers that many requests with 429 Too Many Requests
+	throttle int
+	requests int
+	v1       map[string]map[string]interface{}
+	v2       map[string]*fakeSecret
+	logins   map[string]map[string]interface{}
+}
+
+// fakeSecret is a KV v2 secret
+type fakeSecret struct {
+	versions []*fakeVersion
+	custom   map[string]string
+}
+
+type fakeVersion struct {
+	data    map[string]interface{}
+	created string
+	deleted string
+}
+
+func (v *fakeVersion) metadata(n int) map[string]interface{} {
+	return map[string]interface{}{"version": n, "created_time": v.created, "deletion_time": v.deleted, "destroyed": false}
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	f := &fakeVault{
+		t:      t,
+		token:  "root",
+		v1:     make(map[string]map[string]interface{}),
+		v2:     make(map[string]*fakeSecret),
+		logins: make(map[string]map[string]interface{}),
+	}
+	server := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakeVault) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+	if f.throttle > 0 {
+		f.throttle--
+		f.reply(w, http.StatusTooManyRequests, map[string]interface{}{"errors": []string{"rate limit quota exceeded"}})
+		return
+	}
+	if got := r.Header.Get("X-Vault-Namespace"); got != f.namespace {
+		f.reply(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"wrong namespace " + got}})
+		return
+	}
+	p := strings.TrimPrefix(r.URL.Path, "/v1/")
+	var body map[string]interface{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	if method, ok := strings.CutPrefix(p, "auth/"); ok {
+		method = strings.TrimSuffix(method, "/login")
+		f.logins[method] = body
+		f.reply(w, http.StatusOK, map[string]interface{}{"auth": map[string]interface{}{"client_token": method + "-token"}})
+		return
+	}
+	if r.Header.Get("X-Vault-Token") != f.token {
+		f.reply(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+	list := r.URL.Query().Get("list") == "true"
+	if list {
+		// like Vault, list the folder whether or not the path ends in a slash
+		p = strings.TrimSuffix(p, "/") + "/"
+	}
+
+	switch {
+	case strings.HasPrefix(p, "sys/internal/ui/mounts/"):
+		store := strings.TrimPrefix(p, "sys/internal/ui/mounts/")
+		if strings.HasPrefix(store, "kv") {
+			f.reply(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"path": "kv/", "type": "kv", "options": nil}})
+			return
+		}
+		f.reply(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"path": "secret/", "type": "kv", "options": map[string]string{"version": "2"}}})
+	case strings.HasPrefix(p, "kv/"):
+		f.serveV1(w, r, strings.TrimPrefix(p, "kv/"), list, body)
+	case strings.HasPrefix(p, "secret/data/"):
+		f.serveData(w, r, strings.TrimPrefix(p, "secret/data/"), body)
+	case strings.HasPrefix(p, "secret/metadata/"):
+		f.serveMetadata(w, r, strings.TrimPrefix(p, "secret/metadata/"), list, body)
+	case strings.HasPrefix(p, "secret/delete/"):
+		secret := f.v2[strings.TrimPrefix(p, "secret/delete/")]
+		versions, _ := body["versions"].([]interface{})
+		for _, v := range versions {
+			secret.versions[int(v.(float64))-1].deleted = time.Now().UTC().Format(time.RFC3339Nano)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeVault) serveV1(w http.ResponseWriter, r *http.Request, name string, list bool, body map[string]interface{}) {
+	switch {
+	case list:
+		f.replyList(w, keysOf(f.v1), name)
+	case r.Method == http.MethodGet:
+		data, ok := f.v1[name]
+		if !ok {
+			f.reply(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+		f.reply(w, http.StatusOK, map[string]interface{}{"data": data})
+	case r.Method == http.MethodPut || r.Method == http.MethodPost:
+		f.v1[name] = body
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		delete(f.v1, name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeVault) serveData(w http.ResponseWriter, r *http.Request, name string, body map[string]interface{}) {
+	secret := f.v2[name]
+	if r.Method == http.MethodGet {
+		if secret == nil {
+			f.reply(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+		n := len(secret.versions)
+		if v := r.URL.Query().Get("version"); v != "" {
+			n, _ = strconv.Atoi(v)
+		}
+		if n < 1 || n > len(secret.versions) {
+			f.reply(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+		version := secret.versions[n-1]
+		meta := version.metadata(n)
+		meta["custom_metadata"] = secret.custom
+		if version.deleted != "" {
+			// Vault answers reads of deleted versions with 404 and their metadata
+			f.reply(w, http.StatusNotFound, map[string]interface{}{"data": map[string]interface{}{"data": nil, "metadata": meta}})
+			return
+		}
+		f.reply(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"data": version.data, "metadata": meta}})
+		return
+	}
+	if secret == nil {
+		secret = &fakeSecret{}
+		f.v2[name] = secret
+	}
+	data, _ := body["data"].(map[string]interface{})
+	version := &fakeVersion{data: data, created: time.Now().UTC().Format(time.RFC3339Nano)}
+	secret.versions = append(secret.versions, version)
+	f.reply(w, http.StatusOK, map[string]interface{}{"data": version.metadata(len(secret.versions))})
+}
+
+func (f *fakeVault) serveMetadata(w http.ResponseWriter, r *http.Request, name string, list bool, body map[string]interface{}) {
+	if list {
+		f.replyList(w, keysOf(f.v2), name)
+		return
+	}
+	secret := f.v2[name]
+	switch r.Method {
+	case http.MethodGet:
+		if secret == nil {
+			f.reply(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+		versions := make(map[string]interface{}, len(secret.versions))
+		for i, v := range secret.versions {
+			versions[strconv.Itoa(i+1)] = v.metadata(i + 1)
+		}
+		last := secret.versions[len(secret.versions)-1]
+		f.reply(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+			"current_version": len(secret.versions),
+			"created_time":    secret.versions[0].created,
+			"updated_time":    last.created,
+			"custom_metadata": secret.custom,
+			"versions":        versions,
+		}})
+	case http.MethodPut, http.MethodPost:
+		custom := make(map[string]string)
+		for k, v := range body["custom_metadata"].(map[string]interface{}) {
+			custom[k] = v.(string)
+		}
+		secret.custom = custom
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		delete(f.v2, name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// replyList answers a LIST of dir with its direct children, folders end in a slash
+func (f *fakeVault) replyList(w http.ResponseWriter, names []string, dir string) {
+	prefix := strings.Trim(dir, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	seen := make(map[string]bool)
+	var keys []string
+	for _, name := range names {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		if first, _, nested := strings.Cut(rest, "/"); nested {
+			rest = first + "/"
+		}
+		if !seen[rest] {
+			seen[rest] = true
+			keys = append(keys, rest)
+		}
+	}
+	if len(keys) == 0 {
+		f.reply(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+		return
+	}
+	sort.Strings(keys)
+	f.reply(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"keys": keys}})
+}
+
+func (f *fakeVault) reply(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func keysOf[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func newTestProvider(t *testing.T, server *httptest.Server, cfg providers.Config) *VaultSecretProvider {
+	t.Helper()
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("VAULT_NAMESPACE", "")
+	if cfg == nil {
+		cfg = providers.Config{}
+	}
+	cfg["address"] = server.URL
+	if cfg["auth"] == "" && cfg["token"] == "" {
+		cfg["token"] = "root"
+	}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.(*VaultSecretProvider)
+}
+
+func names(items []providers.SecretProperties) []string {
+	var out []string
+	for _, item := range items {
+		out = append(out, item.Name)
+	}
+	return out
+}
+
+func TestProvider(t *testing.T) {
+	_, server := newFakeVault(t)
+	providertest.Run(t, newTestProvider(t, server, nil), "secret")
+}
+
+func TestThrottling(t *testing.T) {
+	f, server := newFakeVault(t)
+	p := newTestProvider(t, server, nil)
+	providertest.Put(t, p, "secret", providertest.NewSecret("db", "v", nil))
+
+	// the client must not retry on its own, batch.Retry retries throttled calls
+	f.mu.Lock()
+	f.throttle, f.requests = 1, 0
+	f.mu.Unlock()
+	_, err := p.GetSecret(context.Background(), "secret", "db", "")
+	var throttled *providers.ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("got %v, want a ThrottledError", err)
+	}
+	if f.requests != 1 {
+		t.Errorf("sent %d requests, want 1", f.requests)
+	}
+}
+
+func TestKV2(t *testing.T) {
+	_, server := newFakeVault(t)
+	p := newTestProvider(t, server, nil)
+	ctx := context.Background()
+
+	db := providertest.NewSecret("app/db-password", "one", nil)
+	db.ContentType = "text/plain"
+	db.Tags = map[string]string{"owner": "team-a"}
+	props := providertest.Put(t, p, "secret", db)
+	if props.Version != "1" || props.Created == nil {
+		t.Errorf("put returned %+v, want version 1 with a creation time", props)
+	}
+	providertest.Put(t, p, "secret", providertest.NewSecret("api-key", "k", nil))
+
+	items, err := p.ListSecrets(ctx, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(items), []string{"api-key", "app/db-password"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listed %v, want %v", got, want)
+	}
+	items, err = p.ListSecrets(ctx, "secret/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(items), []string{"db-password"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listed %v below the folder, want %v", got, want)
+	}
+
+	got, err := p.GetSecret(ctx, "secret", "app/db-password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "one" || got.ContentType != "text/plain" || !reflect.DeepEqual(got.Tags, db.Tags) || !got.Enabled {
+		t.Errorf("got %+v, want the value with its content type and tags", got)
+	}
+	if _, ok := got.Tags[contentTypeKey]; ok {
+		t.Errorf("the content type custom metadata leaked into the tags: %v", got.Tags)
+	}
+
+	if err := p.DeleteSecret(ctx, "secret", "api-key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetSecret(ctx, "secret", "api-key", ""); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v after delete, want ErrNotFound", err)
+	}
+	if _, err := p.GetMetadata(ctx, "secret", "api-key"); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v for the metadata after delete, want ErrNotFound", err)
+	}
+}
+
+func TestKV2Versions(t *testing.T) {
+	_, server := newFakeVault(t)
+	p := newTestProvider(t, server, nil)
+	ctx := context.Background()
+
+	for _, value := range []string{"one", "two"} {
+		secret := providertest.NewSecret("db", value, nil)
+		secret.Tags = map[string]string{"rev": value}
+		providertest.Put(t, p, "secret", secret)
+	}
+	disabled := providertest.NewSecret("db", "three", nil)
+	disabled.Enabled = false
+	disabled.Tags = map[string]string{"rev": "three"}
+	providertest.Put(t, p, "secret", disabled)
+
+	versions, err := p.ListVersions(ctx, "secret", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range versions {
+		got = append(got, v.Version+":"+strconv.FormatBool(v.Enabled))
+	}
+	if want := []string{"1:true", "2:true", "3:false"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("versions %v, want %v", got, want)
+	}
+
+	old, err := p.GetSecret(ctx, "secret", "db", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.Value != "one" || old.Version != "1" {
+		t.Errorf("version 1 = %+v", old)
+	}
+	// custom_metadata belongs to the secret, so every version reports the latest tags
+	if old.Tags["rev"] != "three" {
+		t.Errorf("version 1 tags = %v, want the custom metadata of the secret", old.Tags)
+	}
+	if _, err := p.GetSecret(ctx, "secret", "db", ""); !errors.Is(err, providers.ErrDisabled) {
+		t.Errorf("got %v for the disabled current version, want ErrDisabled", err)
+	}
+	meta, err := p.GetMetadata(ctx, "secret", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Version != "3" || meta.Enabled {
+		t.Errorf("metadata %+v, want the disabled version 3", meta)
+	}
+}
+
+func TestKV2JSONValue(t *testing.T) {
+	f, server := newFakeVault(t)
+	p := newTestProvider(t, server, nil)
+	ctx := context.Background()
+
+	secret := providertest.NewSecret("config", `{"password":"p","user":"u"}`, nil)
+	secret.ContentType = contentTypeJSON
+	providertest.Put(t, p, "secret", secret)
+	if data := f.v2["config"].versions[0].data; data["user"] != "u" || data["password"] != "p" {
+		t.Errorf("stored %v, want the JSON keys", data)
+	}
+	if _, ok := f.v2["config"].custom[contentTypeKey]; ok {
+		t.Error("the JSON content type was stored as custom metadata")
+	}
+	got, err := p.GetSecret(ctx, "secret", "config", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != secret.Value || got.ContentType != contentTypeJSON {
+		t.Errorf("got %+v, want the keys as a JSON object", got)
+	}
+}
+
+func TestKV1(t *testing.T) {
+	f, server := newFakeVault(t)
+	p := newTestProvider(t, server, nil)
+	ctx := context.Background()
+
+	for _, name := range []string{"db", "team/api"} {
+		providertest.Put(t, p, "kv", providertest.NewSecret(name, name+"-value", nil))
+	}
+	if f.v1["db"]["value"] != "db-value" {
+		t.Errorf("stored %v, want a value key", f.v1["db"])
+	}
+	items, err := p.ListSecrets(ctx, "kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(items), []string{"db", "team/api"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listed %v, want %v", got, want)
+	}
+	got, err := p.GetSecret(ctx, "kv", "team/api", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "team/api-value" || !got.Enabled {
+		t.Errorf("got %+v", got)
+	}
+	if _, err := p.GetSecret(ctx, "kv", "db", "1"); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v reading a version, want ErrNotFound", err)
+	}
+	versions, err := p.ListVersions(ctx, "kv", "db")
+	if err != nil || len(versions) != 1 {
+		t.Errorf("got %v, %v, want the single current version", versions, err)
+	}
+	if err := p.DeleteSecret(ctx, "kv", "db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetSecret(ctx, "kv", "db", ""); !errors.Is(err, providers.ErrNotFound) {
+		t.Errorf("got %v after delete, want ErrNotFound", err)
+	}
+}
+
+func TestKV1RefusesMetadata(t *testing.T) {
+	f, server := newFakeVault(t)
+	p := newTestProvider(t, server, nil)
+
+	secret := providertest.NewSecret("db", "v", nil)
+	secret.Tags = map[string]string{"owner": "a"}
+	secret.Enabled = false
+	_, err := p.PutSecret(context.Background(), "kv", secret)
+	if err == nil || !strings.Contains(err.Error(), "tags and disabled state") {
+		t.Errorf("got %v, want an error naming the tags and disabled state", err)
+	}
+	if _, ok := f.v1["db"]; ok {
+		t.Error("the secret was written without its metadata")
+	}
+}
+
+func TestKVVersionConfig(t *testing.T) {
+	_, server := newFakeVault(t)
+	p := newTestProvider(t, server, providers.Config{"kv_version": "1"})
+	if caps := p.Capabilities(); caps.Tags || caps.Versions {
+		t.Errorf("kv_version 1 reports %+v", caps)
+	}
+	// the lookup is skipped, so the mount is the first path segment
+	m, err := p.mount(context.Background(), "kv/team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.version != 1 || m.root != "kv" || m.prefix != "team" {
+		t.Errorf("mount %+v", m)
+	}
+	if _, err := New(providers.Config{"address": server.URL, "token": "root", "kv_version": "3"}); err == nil {
+		t.Error("kv_version 3 was accepted")
+	}
+}
+
+func TestAuth(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("jwt\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		cfg   providers.Config
+		token string
+		login map[string]interface{}
+	}{
+		{
+			name:  "token",
+			cfg:   providers.Config{"token": "root"},
+			token: "root",
+		},
+		{
+			name:  "approle",
+			cfg:   providers.Config{"auth": "approle", "role_id": "role", "secret_id": "id"},
+			token: "approle-token",
+			login: map[string]interface{}{"role_id": "role", "secret_id": "id"},
+		},
+		{
+			name:  "kubernetes",
+			cfg:   providers.Config{"auth": "kubernetes", "role": "app", "token_path": tokenPath, "auth_mount": "k8s"},
+			token: "k8s-token",
+			login: map[string]interface{}{"role": "app", "jwt": "jwt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, server := newFakeVault(t)
+			f.token = tt.token
+			p := newTestProvider(t, server, tt.cfg)
+			providertest.Put(t, p, "secret", providertest.NewSecret("db", "v", nil))
+			if tt.login == nil {
+				if len(f.logins) != 0 {
+					t.Errorf("logged in with %v, want the token as is", f.logins)
+				}
+				return
+			}
+			mount := tt.cfg.Get("auth_mount", tt.cfg["auth"])
+			if got := f.logins[mount]; !reflect.DeepEqual(got, tt.login) {
+				t.Errorf("login to %s sent %v, want %v", mount, got, tt.login)
+			}
+		})
+	}
+}
+
+func TestAuthErrors(t *testing.T) {
+	_, server := newFakeVault(t)
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("VAULT_ROLE_ID", "")
+	for name, cfg := range map[string]providers.Config{
+		"no token":   {},
+		"no role id": {"auth": "approle"},
+		"no role":    {"auth": "kubernetes", "token_path": os.DevNull},
+		"unknown":    {"auth": "ldap"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg["address"] = server.URL
+			if _, err := New(cfg); err == nil {
+				t.Error("login succeeded")
+			}
+		})
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	f, server := newFakeVault(t)
+	f.namespace = "team-a"
+	p := newTestProvider(t, server, providers.Config{"namespace": "team-a"})
+	ctx := context.Background()
+	providertest.Put(t, p, "secret", providertest.NewSecret("db", "v", nil))
+	if _, err := p.GetSecret(ctx, "secret", "db", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	other := newTestProvider(t, server, nil)
+	if _, err := other.GetSecret(ctx, "secret", "db", ""); err == nil {
+		t.Error("a request outside the namespace succeeded")
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	p := &VaultSecretProvider{}
+	for name, valid := range map[string]bool{
+		"db":         true,
+		"app/db":     true,
+		"":           false,
+		"/db":        false,
+		"db/":        false,
+		"app//db":    false,
+		"../db":      false,
+		"app/../db":  false,
+		"app/./db":   false,
+		"..":         false,
+		"app/db-key": true,
+	} {
+		if err := p.ValidateName(name); (err == nil) != valid {
+			t.Errorf("ValidateName(%q) = %v, want valid %v", name, err, valid)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Package redact keeps secret values out of console output and error messages.
+// Every value read from a provider is registered, and everything printed by the
+// secret commands is scrubbed of registered values before it is written.
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// minLength is the shortest value that is scrubbed wherever it appears in text, shorter values
+// would mask parts of unrelated words and are only scrubbed where they stand as a whole word
+const minLength = 4
+
+var (
+	mu   sync.RWMutex
+	show bool
+	// values maps every registered value and its JSON escaped forms to the masked value
+	values = make(map[string]string)
+	// short holds the registered values shorter than minLength
+	short    = make(map[string]bool)
+	replacer *strings.Replacer
+	words    *regexp.Regexp
+)
+
+// hashKey keys the hashes of values. It is random per process so a printed hash cannot be
+// brute-forced offline and is only good for telling values of the same run apart.
+var hashKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("failed to generate the redaction key: %v", err))
+	}
+	return key
+}()
+
+// SetShowValues disables masking, for the explicit --show-values opt-in
+func SetShowValues(enabled bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	show = enabled
+}
+
+// ShowValues reports whether values are printed in clear text
+func ShowValues() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return show
+}
+
+// Register remembers secret values so they are scrubbed from any later output,
+// together with the forms they take inside JSON strings
+func Register(secrets ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, value := range secrets {
+		switch {
+		case value == "" || short[value]:
+		case len(value) < minLength:
+			short[value] = true
+			words = nil
+		default:
+			if _, ok := values[value]; ok {
+				continue
+			}
+			masked := mask(value)
+			for _, form := range jsonForms(value) {
+				values[form] = masked
+			}
+			replacer = nil
+		}
+	}
+}
+
+// jsonForms returns value and the escaped forms encoding/json writes it in, with and without HTML escaping
+func jsonForms(value string) []string {
+	forms := []string{value}
+	for _, escapeHTML := range []bool{true, false} {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(escapeHTML)
+		if err := encoder.Encode(value); err != nil {
+			continue
+		}
+		quoted := strings.TrimSuffix(buf.String(), "\n")
+		if form := quoted[1 : len(quoted)-1]; form != value && form != forms[len(forms)-1] {
+			forms = append(forms, form)
+		}
+	}
+	return forms
+}
+
+// Value returns the masked form of a secret value: its length and a short hash prefix
+// that is enough to tell values apart without revealing them
+func Value(value string) string {
+	if ShowValues() {
+		return value
+	}
+	return mask(value)
+}
+
+func mask(value string) string {
+	if value == "" {
+		return "[empty]"
+	}
+	return fmt.Sprintf("[redacted len=%d %s]", len(value), Hash(value))
+}
+
+// Hash returns a short keyed hash of a value, enough to tell values apart within a run without
+// revealing them. The key changes with every process, so hashes of different runs never match.
+func Hash(value string) string {
+	h := hmac.New(sha256.New, hashKey)
+	h.Write([]byte(value))
+	return "hmac:" + hex.EncodeToString(h.Sum(nil)[:4])
+}
+
+// String replaces every registered value in s with its masked form
+func String(s string) string {
+	mu.Lock()
+	defer mu.Unlock()
+	if show {
+		return s
+	}
+	if len(values) > 0 {
+		if replacer == nil {
+			// longest first so a value containing another one is masked as a whole
+			sorted := make([]string, 0, len(values))
+			for value := range values {
+				sorted = append(sorted, value)
+			}
+			sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+			pairs := make([]string, 0, 2*len(sorted))
+			for _, value := range sorted {
+				pairs = append(pairs, value, values[value])
+			}
+			replacer = strings.NewReplacer(pairs...)
+		}
+		s = replacer.Replace(s)
+	}
+	if len(short) > 0 {
+		if words == nil {
+			// a match spans the letters and digits around a value, it is only masked when there are none.
+			// Values already masked above are matched as a whole so their length and hash are kept.
+			quoted := make([]string, 0, len(short))
+			for value := range short {
+				quoted = append(quoted, regexp.QuoteMeta(value))
+			}
+			sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+			words = regexp.MustCompile(`\[redacted len=\d+ hmac:[0-9a-f]+\]|[\pL\pN]*(?:` + strings.Join(quoted, "|") + `)[\pL\pN]*`)
+		}
+		s = words.ReplaceAllStringFunc(s, func(match string) string {
+			if short[match] {
+				return mask(match)
+			}
+			return match
+		})
+	}
+	return s
+}
+
+// Error returns err with every registered value scrubbed from its message
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	message := err.Error()
+	if scrubbed := String(message); scrubbed != message {
+		return errors.New(scrubbed)
+	}
+	return err
+}
+
+type writer struct {
+	w io.Writer
+}
+
+// Writer returns an io.Writer that scrubs registered values before writing to w.
+// Values are only found within a single Write call, as written by fmt and encoding/json.
+func Writer(w io.Writer) io.Writer {
+	return &writer{w: w}
+}
+
+func (rw *writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Stdout is the scrubbed standard output every secret command prints to
+var Stdout = Writer(os.Stdout)
+
+// Printf formats to the scrubbed standard output
+func Printf(format string, args ...any) {
+	fmt.Fprintf(Stdout, format, args...)
+}
+
+// Println prints to the scrubbed standard output
+func Println(args ...any) {
+	fmt.Fprintln(Stdout, args...)
+}
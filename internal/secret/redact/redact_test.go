@@ -0,0 +1,92 @@
+package redact
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// reset forgets every registered value so tests do not depend on each other
+func reset(t *testing.T) {
+	t.Helper()
+	mu.Lock()
+	values, short, replacer, words, show = make(map[string]string), make(map[string]bool), nil, nil, false
+	mu.Unlock()
+}
+
+func TestString(t *testing.T) {
+	reset(t)
+	Register("s3cret-value", `pa"ss<word>`, "ab1", "")
+
+	quoted, _ := json.Marshal(`pa"ss<word>`)
+	tests := []struct {
+		name   string
+		text   string
+		hidden string
+		kept   string
+	}{
+		{"value", "auth failed for s3cret-value", "s3cret-value", "auth failed for"},
+		{"json escaped", `{"value":` + string(quoted) + `}`, strings.Trim(string(quoted), `"`), `{"value":"`},
+		{"json without html escaping", `{"value":"pa\"ss<word>"}`, `ss<word>`, `{"value":"`},
+		{"short value as a word", "invalid token ab1: rejected", "ab1", "invalid token"},
+		{"short value inside a word", "cab12 and ab1x", "", "cab12 and ab1x"},
+		{"short value after a masked value", "ab1=s3cret-value", "ab1", "=[redacted len=12 hmac:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := String(tt.text)
+			if tt.hidden != "" && strings.Contains(got, tt.hidden) {
+				t.Errorf("%q still contains %q", got, tt.hidden)
+			}
+			if !strings.Contains(got, tt.kept) {
+				t.Errorf("%q lost %q", got, tt.kept)
+			}
+		})
+	}
+}
+
+func TestStringMasksLongestValue(t *testing.T) {
+	reset(t)
+	Register("token", "token-suffix")
+	if got, want := String("token-suffix"), mask("token-suffix"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestShowValues(t *testing.T) {
+	reset(t)
+	Register("s3cret-value", "ab1")
+	SetShowValues(true)
+	defer SetShowValues(false)
+	if got := String("ab1 s3cret-value"); got != "ab1 s3cret-value" {
+		t.Errorf("got %q with values shown", got)
+	}
+	if got := Value("ab1"); got != "ab1" {
+		t.Errorf("got %q with values shown", got)
+	}
+}
+
+func TestHash(t *testing.T) {
+	if Hash("a") != Hash("a") {
+		t.Error("hash of the same value differs within a run")
+	}
+	if Hash("a") == Hash("b") {
+		t.Error("different values share a hash")
+	}
+	if !strings.HasPrefix(Hash("a"), "hmac:") {
+		t.Errorf("got %q, want an hmac", Hash("a"))
+	}
+}
+
+func TestError(t *testing.T) {
+	reset(t)
+	original := errors.New("nothing to hide")
+	if err := Error(original); err != original {
+		t.Errorf("got %v, want the error unchanged", err)
+	}
+	Register("pin")
+	if err := Error(errors.New("wrong pin")); strings.Contains(err.Error(), "pin") {
+		t.Errorf("got %v, want the pin masked", err)
+	}
+}
@@ -0,0 +1,202 @@
+// Package render renders Go text/templates that reference secrets. The template is
+// executed until every reference it reaches is resolved: each pass collects the
+// references it has no value for yet, which are then read in one de-duplicated batch.
+// A template without conditions on secret values needs one such batch, references
+// below {{if}} or {{range}} on a secret value are only found once that value is
+// resolved and take another pass each.
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/hazyforge/hazyctl/internal/secret/batch"
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/redact"
+)
+
+// Ref references a secret version, written as [<provider>://]<store>/<name>
+type Ref struct {
+	Provider string
+	Store    string
+	Name     string
+	// Version is empty for the current version
+	Version string
+}
+
+// ParseRef parses a reference, using defaultProvider when it names none
+func ParseRef(s, defaultProvider string) (Ref, error) {
+	ref := Ref{Provider: defaultProvider}
+	rest := s
+	if provider, after, ok := strings.Cut(s, "://"); ok {
+		ref.Provider, rest = provider, after
+	}
+	store, name, ok := strings.Cut(rest, "/")
+	if !ok || ref.Provider == "" || store == "" || name == "" {
+		return Ref{}, fmt.Errorf("invalid secret reference %q, expected [<provider>://]<store>/<name>", s)
+	}
+	ref.Store, ref.Name = store, name
+	return ref, nil
+}
+
+func (r Ref) String() string {
+	s := r.Provider + "://" + r.Store + "/" + r.Name
+	if r.Version != "" {
+		s += "@" + r.Version
+	}
+	return s
+}
+
+// Options controls how references are resolved
+type Options struct {
+	// DefaultProvider is used for references without a provider
+	DefaultProvider string
+	Concurrency     int
+	Retry           batch.RetryPolicy
+}
+
+// Render parses text as a template named name and writes it to w with every secret
+// reference resolved. Nothing is written when a reference is invalid or cannot be
+// resolved, the error then lists every such reference reached by the template.
+func Render(ctx context.Context, name, text string, w io.Writer, opts Options) error {
+	values := make(map[Ref]string)
+	// providers are opened once for all passes
+	var opener providers.Opener
+	var pending map[Ref]bool
+	var invalid map[string]error
+	tmpl, err := parse(name, text, opts, func(s, version string) (string, error) {
+		ref, err := ParseRef(s, opts.DefaultProvider)
+		if err != nil {
+			invalid[s] = err
+			return "", nil
+		}
+		ref.Version = version
+		value, ok := values[ref]
+		if !ok {
+			// stands in until the reference is resolved for the next pass
+			pending[ref] = true
+		}
+		return value, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		pending, invalid = make(map[Ref]bool), make(map[string]error)
+		// render into memory first so a failing template leaves no partial output
+		var out bytes.Buffer
+		execErr := tmpl.Execute(&out, nil)
+
+		var failed []error
+		for _, s := range sortedKeys(invalid) {
+			failed = append(failed, invalid[s])
+		}
+		resolved, errs := resolve(ctx, &opener, pending, opts)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if failed = append(failed, errs...); len(failed) > 0 {
+			return fmt.Errorf("%d unresolved secret references:\n%w", len(failed), errors.Join(failed...))
+		}
+		if len(pending) == 0 {
+			if execErr != nil {
+				return fmt.Errorf("failed to render template: %w", execErr)
+			}
+			_, err := w.Write(out.Bytes())
+			return err
+		}
+		// placeholder values may have failed the pass, it is repeated with the resolved values
+		for ref, value := range resolved {
+			values[ref] = value
+		}
+	}
+}
+
+func parse(name, text string, opts Options, lookup func(ref, version string) (string, error)) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"secret": func(s string) (string, error) {
+			return lookup(s, "")
+		},
+		"secretVersion": lookup,
+		"base64": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"jsonEscape": jsonEscape,
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonEscape escapes s for use inside a JSON string literal
+func jsonEscape(s string) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(s); err != nil {
+		return "", err
+	}
+	quoted := strings.TrimSuffix(buf.String(), "\n")
+	return quoted[1 : len(quoted)-1], nil
+}
+
+// resolve reads every referenced secret once. It returns the values read and an error
+// for every reference that could not be read.
+func resolve(ctx context.Context, opener *providers.Opener, refs map[Ref]bool, opts Options) (map[Ref]string, []error) {
+	list := make([]Ref, 0, len(refs))
+	for ref := range refs {
+		list = append(list, ref)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].String() < list[j].String() })
+
+	values := make([]string, len(list))
+	errs := make([]error, len(list))
+	batch.ForEach(ctx, len(list), opts.Concurrency, func(ctx context.Context, i int) {
+		ref := list[i]
+		provider, err := opener.Open(providers.StoreRef{Provider: ref.Provider, Store: ref.Store})
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %w", ref, err)
+			return
+		}
+		secret, err := batch.Retry(ctx, opts.Retry, func() (*providers.Secret, error) {
+			return provider.GetSecret(ctx, ref.Store, ref.Name, ref.Version)
+		})
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %w", ref, err)
+			return
+		}
+		redact.Register(secret.Value)
+		values[i] = secret.Value
+	})
+
+	resolved := make(map[Ref]string, len(list))
+	var failed []error
+	for i, ref := range list {
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+			continue
+		}
+		resolved[ref] = values[i]
+	}
+	return resolved, failed
+}
@@ -0,0 +1,65 @@
+package render
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hazyforge/hazyctl/internal/secret/providers"
+	"github.com/hazyforge/hazyctl/internal/secret/providers/providertest"
+)
+
+// store registers a memory provider named rendertest holding the given secrets of store s
+func store(t *testing.T, secrets map[string]string) {
+	t.Helper()
+	p := providertest.NewMemory()
+	for name, value := range secrets {
+		providertest.Put(t, p, "s", providertest.NewSecret(name, value, nil))
+	}
+	providers.Register("rendertest", func(providers.Config) (providers.SecretProvider, error) { return p, nil })
+}
+
+func TestRender(t *testing.T) {
+	store(t, map[string]string{"db": `pa"ss`, "mode": "tls", "cert": "PEM", "tls-key": "KEY"})
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  []string
+	}{
+		{"value", `{{ secret "s/db" }}`, `pa"ss`, nil},
+		{"provider in reference", `{{ secret "rendertest://s/db" | jsonEscape }}`, `pa\"ss`, nil},
+		{"base64", `{{ secret "s/mode" | base64 }}`, "dGxz", nil},
+		{"condition on a value", `{{ if eq (secret "s/mode") "tls" }}{{ secret "s/cert" }}{{ end }}`, "PEM", nil},
+		{"reference built from a value", `{{ with secret "s/mode" }}{{ secret (printf "s/%s-key" .) }}{{ end }}`, "KEY", nil},
+		{"every bad reference", `{{ secret "nostore" }}{{ secret "s/missing" }}{{ secret "s/db" }}{{ secret "/x" }}`, "", []string{`"nostore"`, "rendertest://s/missing", `"/x"`, "3 unresolved"}},
+		{"template error", `{{ .missing.field }}`, "", []string{"failed to render template"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			err := render(tt.template, &out)
+			if tt.wantErr == nil {
+				if err != nil || out.String() != tt.want {
+					t.Errorf("got %q, %v, want %q", out.String(), err, tt.want)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("rendered %q, want an error", out.String())
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %s", err, want)
+				}
+			}
+			if out.Len() > 0 {
+				t.Errorf("wrote %q on error", out.String())
+			}
+		})
+	}
+}
+
+func render(text string, out *strings.Builder) error {
+	return Render(context.Background(), "test", text, out, Options{DefaultProvider: "rendertest"})
+}
@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"path/filepath"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// ConfigDir returns the directory holding the config file and local data, ~/.local/share/hazyctl
+func ConfigDir() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "hazyctl"), nil
+}
@@ -0,0 +1,21 @@
+package utils
+
+import "fmt"
+
+// ExitError makes the command exit with Code. Err is printed when set,
+// a nil Err exits silently because the command already reported the outcome.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}